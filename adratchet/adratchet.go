@@ -3,6 +3,11 @@
 // This package provides a State type that maintains send and receive states, allowing for encrypted communication with
 // forward secrecy and break-in recovery. It uses ephemeral Ristretto255 keys for the asymmetric ratchet Newplex for the
 // symmetric.
+//
+// The asymmetric ratchet only uses Ristretto255, but every shared secret is mixed into the protocol passed to
+// NewInitiator and NewResponder. If that protocol was established with a post-quantum or hybrid key exchange (e.g., a
+// handshake in hybrid mode), messages remain confidential against an attacker who can break Ristretto255 but not that
+// key exchange, although break-in recovery then only holds against classical attackers.
 package adratchet
 
 import (
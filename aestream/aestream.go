@@ -2,34 +2,98 @@
 //
 // A stream of data is broken up into a sequence of blocks.
 //
-// The writer encodes each block's length as a 2-byte big endian integer, seals that header, seals the block, and
+// The writer encodes each block's length as a 2-byte big endian integer, masks that header, seals the block, and
 // writes both to the wrapped writer. An empty block is used to mark the end of the stream when the writer is closed. A
 // block may be at most 2^16-1 bytes long (65,535 bytes).
 //
-// The reader reads the sealed header, opens it, decodes it into a block length, reads an encrypted block of that
+// The reader reads the masked header, unmasks it, decodes it into a block length, reads an encrypted block of that
 // length and its authentication tag, then opens the sealed block. When it encounters the empty block, it returns EOF.
 // If the stream terminates before that, an invalid ciphertext error is returned.
+//
+// Streams may instead use typed headers (see Writer.SetTypedHeaders), in which each header begins with a single byte
+// giving the type of the block or record which follows it. Typed headers are a distinct format, which both the writer
+// and the reader must enable, and are required for key update records and records. Streams without typed headers use
+// the original format.
+//
+// With typed headers, the writer may also write key update records, either explicitly via Writer.UpdateKey or
+// automatically after a configured amount of data (see Writer.SetKeyUpdateInterval). A key update record has its own
+// type and contains 32 bytes of fresh randomness, after which both the writer and the reader mix in the randomness and
+// ratchet their protocols. Readers handle key update records transparently. Because the randomness is sealed with the
+// current key, key updates only bound the amount of data sealed with any one key; they do not restore confidentiality
+// after the protocol's state has been compromised.
+//
+// With typed headers, the writer may also write records via Writer.WriteRecord, which bind cleartext associated data
+// (e.g., a record type or channel ID) to an encrypted block. A record has its own type and is followed by a sealed
+// record header containing the lengths of the associated data and the block, the associated data in cleartext, which is
+// mixed into the protocol, and the sealed block. Readers return the associated data and record boundaries via
+// Reader.ReadRecord.
+//
+// To hide the exact lengths of blocks and records, the writer may pad them according to a padding.Policy (see
+// Writer.SetPadding). Padded blocks are sealed with different labels than unpadded blocks, and readers of padded
+// streams must enable padding via Reader.SetPadding.
+//
+// By default, each write is sealed in its own block, which adds 18 bytes of overhead to every write (19 with typed
+// headers). To coalesce small
+// writes, the writer may buffer data (see Writer.SetBuffering) until a full block is available, Writer.Flush is called,
+// or a maximum delay has passed since the first unsent byte was written.
+//
+// Because each block is chained to the one before it, a Writer's streams are sealed and opened on a single core. To
+// encrypt large payloads on multiple cores, a ParallelWriter seals each block with a key derived from its index
+// instead, in batches on multiple goroutines, and a ParallelReader opens the resulting stream the same way.
 package aestream
 
 import (
+	"crypto/rand"
 	"encoding/binary"
 	"errors"
 	"io"
 	"slices"
+	"sync"
+	"time"
 
 	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/padding"
 )
 
 // MaxBlockSize is the maximum size of an aestream block, in bytes. Writes larger than this broken up into blocks of
 // this size.
 const MaxBlockSize = 1<<16 - 1
 
-// Writer encrypts written data in blocks, ensuring both confidentiality and authenticity.
+// ErrRecordTooLarge is returned when a record's associated data or block is longer than MaxBlockSize, or when a
+// record's block is longer than MaxBlockSize-1 and the writer pads blocks.
+var ErrRecordTooLarge = errors.New("newplex/aestream: record too large")
+
+// ErrClosed is returned when writing to a Writer which has been closed.
+var ErrClosed = errors.New("newplex/aestream: writer closed")
+
+// ErrUntyped is returned when writing a record or a key update record to a Writer which does not use typed headers.
+var ErrUntyped = errors.New("newplex/aestream: typed headers required")
+
+// Writer encrypts written data in blocks, ensuring both confidentiality and authenticity. Its methods may be called
+// concurrently. Once the underlying io.Writer returns an error, the stream is unusable, and all further writes return
+// that error.
 type Writer struct {
+	mu     sync.Mutex
 	p      *newplex.Protocol
 	w      io.Writer
 	buf    []byte
 	closed bool
+	typed  bool  // true if headers include a type
+	err    error // the first error from the underlying io.Writer, if any
+
+	// The buffer size and maximum delay, if buffering, the buffered data, and the timer which flushes it.
+	bufSize  int
+	maxDelay time.Duration
+	pending  []byte
+	timer    *time.Timer
+
+	// The padding policy, if any, and a buffer for padded blocks.
+	padding padding.Policy
+	padBuf  []byte
+
+	// The key update interval and the amount of data written since the last key update.
+	updateBytes, sinceBytes   int64
+	updateBlocks, sinceBlocks int64
 }
 
 // NewWriter wraps the given newplex.Protocol and io.Writer with a streaming authenticated encryption writer.
@@ -37,7 +101,7 @@ type Writer struct {
 // The returned io.WriteCloser MUST be closed for the encrypted stream to be valid. The provided newplex.Protocol MUST
 // NOT be used while the writer is open.
 //
-// For maximum throughput and transmission efficiency, the use of a bufio.Writer wrapper is strongly recommended.
+// For maximum throughput and transmission efficiency, enabling buffering via SetBuffering is strongly recommended.
 // Unbuffered writes will result in blocks the length of each write, rather than blocks of the maximum size.
 func NewWriter(p *newplex.Protocol, w io.Writer) *Writer {
 	return &Writer{
@@ -48,52 +112,409 @@ func NewWriter(p *newplex.Protocol, w io.Writer) *Writer {
 	}
 }
 
+// Write seals the given data in blocks and writes them to the underlying io.Writer. If the writer buffers data, data
+// which does not fill a block is buffered until more data is written, Flush is called, or the maximum delay passes.
 func (s *Writer) Write(p []byte) (n int, err error) {
-	if len(p) == 0 {
-		return 0, nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(p)
+}
+
+// ReadFrom reads data from r until EOF or an error and writes it to the stream as if by Write, returning the number of
+// bytes read. Data is read directly into a block-sized buffer which is either sealed or, if the writer buffers data,
+// kept as the buffered data, so no intermediate buffer or copy is needed. The writer is not locked while reading from
+// r, so buffered data is still flushed after the maximum delay.
+func (s *Writer) ReadFrom(r io.Reader) (n int64, err error) {
+	buf := make([]byte, MaxBlockSize)
+	for {
+		m, rerr := r.Read(buf)
+		n += int64(m)
+		if m > 0 {
+			if buf, err = s.writeFrom(buf, m); err != nil {
+				return n, err
+			}
+		}
+
+		if errors.Is(rerr, io.EOF) {
+			return n, nil
+		} else if rerr != nil {
+			return n, rerr
+		}
 	}
+}
 
-	total := len(p)
-	for len(p) > 0 {
-		blockLen := min(len(p), MaxBlockSize)
-		err = s.sealAndWrite(p[:blockLen])
-		if err != nil {
-			return total - len(p), err
+// writeFrom writes the first n bytes of the given buffer as if by Write and returns a buffer of MaxBlockSize bytes for
+// the caller to read into next. If the bytes would be buffered in their entirety, the given buffer becomes the buffered
+// data instead of being copied into it, and the previous buffer is returned.
+func (s *Writer) writeFrom(buf []byte, n int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.check() != nil || s.bufSize == 0 || len(s.pending) > 0 || n >= s.blockSize() {
+		_, err := s.write(buf[:n])
+		return buf, err
+	}
+
+	buf, s.pending = s.pending[:cap(s.pending)], buf[:n]
+	s.startTimer()
+	if len(buf) < MaxBlockSize {
+		buf = make([]byte, MaxBlockSize)
+	}
+	return buf, nil
+}
+
+// write seals the given data in blocks, buffering any data which does not fill a block if the writer buffers data.
+func (s *Writer) write(p []byte) (n int, err error) {
+	if err := s.check(); err != nil {
+		return 0, err
+	}
+
+	// Top off the buffered data and seal it once it fills a block.
+	size := s.blockSize()
+	if len(s.pending) > 0 {
+		n = min(len(p), size-len(s.pending))
+		s.pending = append(s.pending, p[:n]...)
+		p = p[n:]
+		if len(s.pending) < size && s.bufSize > 0 {
+			return n, nil
 		}
+
+		if err := s.flush(); err != nil {
+			return n, err
+		}
+	}
+
+	// Seal full blocks, and any remaining data if not buffering, directly from p.
+	for len(p) >= size || s.bufSize == 0 && len(p) > 0 {
+		blockLen := min(len(p), size)
+		if err := s.writeBlock(p[:blockLen]); err != nil {
+			return n, err
+		}
+		n += blockLen
 		p = p[blockLen:]
 	}
 
-	return total, nil
+	// Buffer any remaining data and start the clock on it.
+	if len(p) > 0 {
+		s.pending = append(s.pending, p...)
+		s.startTimer()
+		n += len(p)
+	}
+
+	return n, nil
+}
+
+// check returns the writer's first error, if any, or ErrClosed if the writer has been closed.
+func (s *Writer) check() error {
+	if s.err != nil {
+		return s.err
+	}
+
+	if s.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// writeBlock seals the given data in a block, padding it if the writer pads blocks, and writes it.
+func (s *Writer) writeBlock(p []byte) error {
+	if err := s.maybeUpdateKey(); err != nil {
+		return err
+	}
+
+	label, block := s.pad("block", p)
+	return s.sealAndWrite(label, block)
+}
+
+// WriteRecord writes a record containing the given associated data and block. The associated data is written in
+// cleartext but authenticated along with the block, and the reader returns both, and the record's boundaries, via
+// Reader.ReadRecord.
+//
+// Returns ErrUntyped if the writer does not use typed headers, or ErrRecordTooLarge if either the associated data or
+// the block is longer than MaxBlockSize, or if the block is longer than MaxBlockSize-1 and the writer pads blocks.
+//
+// Any buffered data is flushed before the record is written.
+func (s *Writer) WriteRecord(ad, p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.check(); err != nil {
+		return err
+	}
+
+	if !s.typed {
+		return ErrUntyped
+	}
+
+	if len(ad) > MaxBlockSize || len(p) > s.maxBlockSize() {
+		return ErrRecordTooLarge
+	}
+
+	if err := s.flush(); err != nil {
+		return err
+	}
+	label, p := s.pad("record", p)
+
+	if err := s.maybeUpdateKey(); err != nil {
+		return err
+	}
+
+	// Mask a record header and seal the lengths of the associated data and block.
+	record := s.header(recordType, recordHeaderSize, recordHeaderSize+len(ad)+len(p)+2*newplex.TagSize)
+
+	var recordHeader [recordHeaderSize]byte
+	binary.BigEndian.PutUint16(recordHeader[:], uint16(len(ad)))
+	binary.BigEndian.PutUint16(recordHeader[2:], uint16(len(p)))
+	record = s.p.Seal("record header", record, recordHeader[:])
+
+	// Append the associated data in cleartext and mix it in, then seal the block.
+	record = append(record, ad...)
+	s.p.Mix("associated data", ad)
+	record = s.p.Seal(label, record, p)
+	if _, err := s.w.Write(record); err != nil {
+		s.err = err
+		return err
+	}
+
+	// Ratchet for forward secrecy.
+	s.p.Ratchet("record")
+	s.sinceBytes += int64(len(p))
+	s.sinceBlocks++
+
+	return nil
+}
+
+// SetTypedHeaders configures the writer to begin each header with the type of the block or record which follows it,
+// which is required for WriteRecord, UpdateKey, and SetKeyUpdateInterval. Typed headers are disabled by default, and
+// streams with typed headers cannot be read by readers without them, so the reader MUST enable typed headers via
+// Reader.SetTypedHeaders. SetTypedHeaders should be called before any data is written.
+func (s *Writer) SetTypedHeaders(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.typed = enabled
+}
+
+// SetPadding configures the writer to pad each block and record according to the given policy before sealing it, or
+// disables padding if the policy is nil. Padding is disabled by default. The reader MUST enable padding via
+// Reader.SetPadding.
+//
+// Padded lengths are limited to MaxBlockSize, so a padded block holds at most MaxBlockSize-1 bytes of data.
+func (s *Writer) SetPadding(policy padding.Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.padding = policy
+}
+
+// SetBuffering configures the writer to buffer written data and seal it in blocks of up to size bytes (or the maximum
+// block size, if smaller), or disables buffering if size is zero. Buffering is disabled by default. A block is sealed
+// and written once it is full, when Flush, WriteRecord, or Close is called, or, if maxDelay is positive, once maxDelay
+// has passed since the first byte of data in it was written.
+//
+// Small sizes and delays favor latency, for interactive traffic, and large ones favor throughput, for bulk transfers.
+// SetBuffering should be called before any data is written.
+func (s *Writer) SetBuffering(size int, maxDelay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bufSize, s.maxDelay = size, maxDelay
+}
+
+// SetKeyUpdateInterval configures the writer to automatically write a key update record before writing a block once
+// at least the given number of bytes or blocks have been written since the last key update. A value of zero disables
+// the respective limit, and both are disabled by default. Key update records require typed headers (see
+// SetTypedHeaders); otherwise, writes return ErrUntyped once the interval is reached.
+//
+// Key updates bound the amount of data sealed with any one key. They do not provide post-compromise security: an
+// attacker who learns the protocol's state can open the key update records and follow the stream.
+func (s *Writer) SetKeyUpdateInterval(bytes, blocks int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateBytes, s.updateBlocks = bytes, blocks
+}
+
+// Flush seals any buffered data in a block and writes it to the underlying io.Writer.
+func (s *Writer) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	return s.flush()
+}
+
+// flush seals and writes the buffered data, if any.
+func (s *Writer) flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+
+	err := s.writeBlock(s.pending)
+	s.pending = s.pending[:0]
+	return err
+}
+
+// startTimer starts the timer which flushes the buffered data after the maximum delay, if any.
+func (s *Writer) startTimer() {
+	if s.maxDelay <= 0 {
+		return
+	}
+
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.maxDelay, s.flushDelayed)
+	} else {
+		s.timer.Reset(s.maxDelay)
+	}
+}
+
+// flushDelayed flushes the buffered data once the maximum delay has passed. Any error is returned by the next call to
+// the writer.
+func (s *Writer) flushDelayed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.check() == nil {
+		_ = s.flush()
+	}
+}
+
+// UpdateKey writes a key update record, which mixes 32 bytes of fresh randomness into the writer's protocol and the
+// reader's protocol, and ratchets both. The randomness is sealed with the current key, so a key update does not provide
+// post-compromise security; see SetKeyUpdateInterval.
+//
+// Returns ErrUntyped if the writer does not use typed headers.
+func (s *Writer) UpdateKey() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.check(); err != nil {
+		return err
+	}
+	return s.updateKey()
+}
+
+// updateKey writes a key update record.
+func (s *Writer) updateKey() error {
+	if !s.typed {
+		return ErrUntyped
+	}
+
+	var entropy [keyUpdateSize]byte
+	_, _ = rand.Read(entropy[:])
+
+	// Mask a key update header and seal the randomness with a different label than blocks.
+	record := s.header(keyUpdateType, keyUpdateSize, keyUpdateSize+newplex.TagSize)
+	record = s.p.Seal("key update", record, entropy[:])
+	s.p.Mix("key update", entropy[:])
+	s.p.Ratchet("key update")
+	s.sinceBytes, s.sinceBlocks = 0, 0
+
+	if _, err := s.w.Write(record); err != nil {
+		s.err = err
+		return err
+	}
+	return nil
 }
 
-// Close ends the stream with a terminal block, ensuring no further writes can be made to the stream.
+// maybeUpdateKey writes a key update record if the key update interval has been reached.
+func (s *Writer) maybeUpdateKey() error {
+	if s.updateBytes > 0 && s.sinceBytes >= s.updateBytes || s.updateBlocks > 0 && s.sinceBlocks >= s.updateBlocks {
+		return s.updateKey()
+	}
+	return nil
+}
+
+// Close flushes any buffered data and ends the stream with a terminal block, ensuring no further writes can be made to
+// the stream.
 func (s *Writer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if s.closed {
 		return nil
 	}
 	s.closed = true
 
+	if s.err != nil {
+		return s.err
+	}
+
+	if err := s.flush(); err != nil {
+		return err
+	}
+
 	// Encode and seal a header for a zero-length block.
-	if err := s.sealAndWrite(nil); err != nil {
+	if err := s.sealAndWrite("block", nil); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *Writer) sealAndWrite(p []byte) error {
+// maxBlockSize returns the maximum number of bytes of data in a block, which is reduced by one if the writer pads
+// blocks.
+func (s *Writer) maxBlockSize() int {
+	if s.padding != nil {
+		return MaxBlockSize - 1
+	}
+	return MaxBlockSize
+}
+
+// blockSize returns the maximum number of bytes of data in a block written by Write, which is limited by the buffer
+// size if the writer buffers data.
+func (s *Writer) blockSize() int {
+	if s.bufSize > 0 {
+		return min(s.bufSize, s.maxBlockSize())
+	}
+	return s.maxBlockSize()
+}
+
+// pad returns the label with which to seal the given block and the block, padded according to the padding policy, if
+// any.
+func (s *Writer) pad(label string, p []byte) (string, []byte) {
+	if s.padding == nil {
+		return label, p
+	}
+
+	n := min(max(s.padding(len(p)+1), len(p)+1), MaxBlockSize)
+	s.padBuf = padding.Pad(append(s.padBuf[:0], p...), n)
+	return "padded " + label, s.padBuf
+}
+
+// header masks a header with the given length, preceded by the given type if the writer uses typed headers, into the
+// write buffer, which is grown to fit the header and n more bytes, and returns it.
+func (s *Writer) header(typ byte, length, n int) []byte {
+	s.buf = slices.Grow(s.buf[:0], typedHeaderSize+n)
+	header := s.buf[:0]
+	if s.typed {
+		header = append(header, typ)
+	}
+	header = binary.BigEndian.AppendUint16(header, uint16(length))
+	return s.p.Mask("header", header[:0], header)
+}
+
+func (s *Writer) sealAndWrite(label string, p []byte) error {
 	// Encode a header with a 2-byte big endian block length and mask it.
-	s.buf = slices.Grow(s.buf[:0], headerSize+len(p)+newplex.TagSize)
-	header := binary.BigEndian.AppendUint16(s.buf[:0], uint16(len(p)))
-	block := s.p.Mask("header", header[:0], header)
+	block := s.header(blockType, len(p), len(p)+newplex.TagSize)
 
 	// Seal the block, append it to the header block, and send it.
-	block = s.p.Seal("block", block, p)
+	block = s.p.Seal(label, block, p)
 	if _, err := s.w.Write(block); err != nil {
+		s.err = err
 		return err
 	}
 
 	// Ratchet for forward secrecy.
 	s.p.Ratchet("block")
+	s.sinceBytes += int64(len(p))
+	s.sinceBlocks++
 
 	return nil
 }
@@ -103,6 +524,10 @@ type Reader struct {
 	p             *newplex.Protocol
 	r             io.Reader
 	buf, blockBuf []byte
+	ad            []byte // the associated data of the current block, if it is a record
+	pending       bool   // true if the current block has been read but not returned by ReadRecord
+	padded        bool   // true if blocks and records are padded
+	typed         bool   // true if headers include a type
 	eos           bool
 }
 
@@ -122,50 +547,211 @@ func NewReader(p *newplex.Protocol, r io.Reader) *Reader {
 	}
 }
 
+// SetPadding configures the reader to remove the padding from each block and record, which is required if the writer
+// pads blocks. See Writer.SetPadding.
+func (o *Reader) SetPadding(enabled bool) {
+	o.padded = enabled
+}
+
+// SetTypedHeaders configures the reader to decode the type at the beginning of each header, which is required if the
+// writer uses typed headers. See Writer.SetTypedHeaders.
+func (o *Reader) SetTypedHeaders(enabled bool) {
+	o.typed = enabled
+}
+
+// Read reads the blocks of the stream as a sequence of bytes. The associated data of any records is discarded.
 func (o *Reader) Read(p []byte) (n int, err error) {
 	if len(p) == 0 {
 		return 0, nil
 	}
 
+	// Read blocks until one has data.
+	for len(o.blockBuf) == 0 {
+		// If the stream is closed, return EOF.
+		if o.eos {
+			return 0, io.EOF
+		}
+
+		if err := o.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n = copy(p, o.blockBuf)
+	o.blockBuf = o.blockBuf[n:]
+	o.pending = len(o.blockBuf) > 0
+	return n, nil
+}
+
+// WriteTo writes the blocks of the stream to w as a sequence of bytes until the end of the stream or an error, returning
+// the number of bytes written. Each block is written directly from the buffer it was opened in, without an intermediate
+// buffer. The associated data of any records is discarded.
+func (o *Reader) WriteTo(w io.Writer) (n int64, err error) {
 	for {
-		// If a block is buffer, satisfy the read with that.
-		if len(o.blockBuf) > 0 {
-			n = min(len(o.blockBuf), len(p))
-			copy(p, o.blockBuf[:n])
-			o.blockBuf = o.blockBuf[n:]
-			return n, nil
+		// Read blocks until one has data.
+		for len(o.blockBuf) == 0 {
+			if o.eos {
+				return n, nil
+			}
+
+			if err := o.next(); err != nil {
+				return n, err
+			}
 		}
 
-		// If the stream is closed, return EOF.
+		m, err := w.Write(o.blockBuf)
+		n += int64(m)
+		o.blockBuf = o.blockBuf[m:]
+		o.pending = len(o.blockBuf) > 0
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// ReadRecord reads the next record written by Writer.WriteRecord, returning its associated data and block. Blocks
+// written by Writer.Write are returned as records with no associated data, and if a block has been partially read by
+// Read, the rest of it is returned. The returned slices are only valid until the next call to Read or ReadRecord.
+//
+// It returns io.EOF when the stream is fully read and authenticated.
+func (o *Reader) ReadRecord() (ad, p []byte, err error) {
+	if !o.pending {
 		if o.eos {
-			return 0, io.EOF
+			return nil, nil, io.EOF
+		}
+
+		if err := o.next(); err != nil {
+			return nil, nil, err
 		}
 
-		// Read and unmask the header and decode the block length.
-		header, err := o.read(headerSize)
+		if o.eos {
+			return nil, nil, io.EOF
+		}
+	}
+
+	ad, p = o.ad, o.blockBuf
+	o.ad, o.blockBuf, o.pending = nil, nil, false
+	return ad, p, nil
+}
+
+// next reads and opens the next block or record, handling any key update records which precede it.
+func (o *Reader) next() error {
+	for {
+		// Read and unmask the header and decode the type, if any, and block length.
+		size := headerSize
+		if o.typed {
+			size = typedHeaderSize
+		}
+		header, err := o.read(size)
 		if err != nil {
-			return 0, err
+			return err
 		}
 		header = o.p.Unmask("header", header[:0], header)
-		blockLen := int(binary.BigEndian.Uint16(header))
+		typ, blockLen := byte(blockType), int(binary.BigEndian.Uint16(header[size-headerSize:]))
+		if o.typed {
+			typ = header[0]
+		}
 
 		// Read and open the block.
 		block, err := o.read(blockLen + newplex.TagSize)
 		if err != nil {
-			return 0, err
+			return err
+		}
+
+		switch typ {
+		case blockType:
+		case recordType:
+			return o.record(blockLen, block)
+		case keyUpdateType:
+			if err := o.updateKey(blockLen, block); err != nil {
+				return err
+			}
+			continue
+		default:
+			return newplex.ErrInvalidCiphertext
 		}
-		block, err = o.p.Open("block", block[:0], block)
+
+		// The terminal block is never padded.
+		label := "block"
+		if o.padded && blockLen > 0 {
+			label = "padded block"
+		}
+
+		block, err = o.p.Open(label, block[:0], block)
 		if err != nil {
-			return 0, err
+			return err
 		}
-		o.eos = len(block) == 0
-		o.blockBuf = block
+
+		if label == "padded block" {
+			if block, err = padding.Unpad(block); err != nil {
+				return newplex.ErrInvalidCiphertext
+			}
+		}
+		o.eos = blockLen == 0
+		o.blockBuf, o.ad, o.pending = block, nil, !o.eos
 
 		// Ratchet for forward secrecy.
 		o.p.Ratchet("block")
+		return nil
 	}
 }
 
+// record opens a sealed record header with the given length, then reads and opens the rest of the record.
+func (o *Reader) record(n int, sealedHeader []byte) error {
+	if n != recordHeaderSize {
+		return newplex.ErrInvalidCiphertext
+	}
+
+	var recordHeader [recordHeaderSize]byte
+	if _, err := o.p.Open("record header", recordHeader[:0], sealedHeader); err != nil {
+		return err
+	}
+
+	// Read the associated data and mix it in, then open the block.
+	adLen, blockLen := int(binary.BigEndian.Uint16(recordHeader[:])), int(binary.BigEndian.Uint16(recordHeader[2:]))
+	record, err := o.read(adLen + blockLen + newplex.TagSize)
+	if err != nil {
+		return err
+	}
+	ad, block := record[:adLen], record[adLen:]
+	o.p.Mix("associated data", ad)
+	label := "record"
+	if o.padded {
+		label = "padded record"
+	}
+
+	block, err = o.p.Open(label, block[:0], block)
+	if err != nil {
+		return err
+	}
+
+	if o.padded {
+		if block, err = padding.Unpad(block); err != nil {
+			return newplex.ErrInvalidCiphertext
+		}
+	}
+	o.blockBuf, o.ad, o.pending = block, ad, true
+
+	// Ratchet for forward secrecy.
+	o.p.Ratchet("record")
+	return nil
+}
+
+// updateKey opens a key update record with the given length and mixes in the randomness.
+func (o *Reader) updateKey(n int, record []byte) error {
+	if n != keyUpdateSize {
+		return newplex.ErrInvalidCiphertext
+	}
+
+	entropy, err := o.p.Open("key update", record[:0], record)
+	if err != nil {
+		return err
+	}
+	o.p.Mix("key update", entropy)
+	o.p.Ratchet("key update")
+	return nil
+}
+
 func (o *Reader) read(n int) ([]byte, error) {
 	o.buf = slices.Grow(o.buf[:0], n)
 	data := o.buf[:n]
@@ -179,9 +765,23 @@ func (o *Reader) read(n int) ([]byte, error) {
 	return data, nil
 }
 
-const headerSize = 2
+const (
+	headerSize       = 2
+	typedHeaderSize  = headerSize + 1
+	recordHeaderSize = 4
+	keyUpdateSize    = 32
+)
+
+// The types of records, which are encoded in the first byte of each typed header.
+const (
+	blockType     = 0
+	keyUpdateType = 1
+	recordType    = 2
+)
 
 var (
 	_ io.WriteCloser = (*Writer)(nil)
+	_ io.ReaderFrom  = (*Writer)(nil)
 	_ io.Reader      = (*Reader)(nil)
+	_ io.WriterTo    = (*Reader)(nil)
 )
@@ -5,11 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/codahale/newplex"
 	"github.com/codahale/newplex/aestream"
 	"github.com/codahale/newplex/internal/testdata"
+	"github.com/codahale/newplex/padding"
 )
 
 func TestNewWriter(t *testing.T) {
@@ -105,6 +109,20 @@ func TestNewWriter(t *testing.T) {
 	})
 }
 
+// newTypedWriter returns a Writer which uses typed headers.
+func newTypedWriter(p *newplex.Protocol, w io.Writer) *aestream.Writer {
+	sw := aestream.NewWriter(p, w)
+	sw.SetTypedHeaders(true)
+	return sw
+}
+
+// newTypedReader returns a Reader which uses typed headers.
+func newTypedReader(p *newplex.Protocol, r io.Reader) *aestream.Reader {
+	sr := aestream.NewReader(p, r)
+	sr.SetTypedHeaders(true)
+	return sr
+}
+
 func TestWriter_Write(t *testing.T) {
 	t.Run("underlying writer error", func(t *testing.T) {
 		ew := &testdata.ErrWriter{Err: errors.New("write failed")}
@@ -117,6 +135,625 @@ func TestWriter_Write(t *testing.T) {
 	})
 }
 
+func TestWriter_SetTypedHeaders(t *testing.T) {
+	newProtocol := func() *newplex.Protocol {
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		return p
+	}
+
+	t.Run("untyped writer", func(t *testing.T) {
+		w := aestream.NewWriter(newProtocol(), io.Discard)
+
+		if err := w.UpdateKey(); !errors.Is(err, aestream.ErrUntyped) {
+			t.Errorf("UpdateKey() err = %v, want = %v", err, aestream.ErrUntyped)
+		}
+
+		if err := w.WriteRecord([]byte("ad"), []byte("record")); !errors.Is(err, aestream.ErrUntyped) {
+			t.Errorf("WriteRecord() err = %v, want = %v", err, aestream.ErrUntyped)
+		}
+
+		w.SetKeyUpdateInterval(0, 1)
+		if _, err := w.Write([]byte("first")); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write([]byte("second")); !errors.Is(err, aestream.ErrUntyped) {
+			t.Errorf("Write() err = %v, want = %v", err, aestream.ErrUntyped)
+		}
+	})
+
+	// Each block adds a 2-byte header, or a 3-byte header if typed, and a tag.
+	for _, typed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("typed=%v", typed), func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			w := aestream.NewWriter(newProtocol(), buf)
+			w.SetTypedHeaders(typed)
+			if _, err := w.Write([]byte("hello")); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			size := 2
+			if typed {
+				size = 3
+			}
+			if got, want := buf.Len(), 5+2*(size+newplex.TagSize); got != want {
+				t.Errorf("len(ciphertext) = %d, want = %d", got, want)
+			}
+
+			r := aestream.NewReader(newProtocol(), bytes.NewReader(buf.Bytes()))
+			r.SetTypedHeaders(typed)
+			if got, err := io.ReadAll(r); err != nil || string(got) != "hello" {
+				t.Errorf("ReadAll() = %q/%v, want = %q/nil", got, err, "hello")
+			}
+
+			// A reader which disagrees about typed headers rejects the stream.
+			r = aestream.NewReader(newProtocol(), bytes.NewReader(buf.Bytes()))
+			r.SetTypedHeaders(!typed)
+			if _, err := io.ReadAll(r); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+				t.Errorf("ReadAll() err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+			}
+		})
+	}
+}
+
+func TestWriter_UpdateKey(t *testing.T) {
+	drbg := testdata.New("newplex aestream key update")
+	message := drbg.Data(100_000)
+
+	// encrypt writes the message in 1000-byte writes, calling before before each write.
+	encrypt := func(before func(i int, w *aestream.Writer)) []byte {
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		buf := bytes.NewBuffer(nil)
+		w := newTypedWriter(p, buf)
+		for i := 0; i*1000 < len(message); i++ {
+			before(i, w)
+			if _, err := w.Write(message[i*1000 : (i+1)*1000]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	decrypt := func(ciphertext []byte) ([]byte, error) {
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		return io.ReadAll(newTypedReader(p, bytes.NewReader(ciphertext)))
+	}
+
+	// Each block and each key update record adds a 3-byte header and a tag; key update records have 32 bytes of data.
+	blocks := len(message)/1000 + 1
+	size := func(keyUpdates int) int {
+		return len(message) + blocks*(3+newplex.TagSize) + keyUpdates*(3+32+newplex.TagSize)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		before     func(i int, w *aestream.Writer)
+		keyUpdates int
+	}{
+		{"none", func(int, *aestream.Writer) {}, 0},
+		{"explicit", func(i int, w *aestream.Writer) {
+			if i%10 == 5 {
+				if err := w.UpdateKey(); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}, 10},
+		{"by bytes", func(i int, w *aestream.Writer) {
+			if i == 0 {
+				w.SetKeyUpdateInterval(4500, 0)
+			}
+		}, 19},
+		{"by blocks", func(i int, w *aestream.Writer) {
+			if i == 0 {
+				w.SetKeyUpdateInterval(0, 25)
+			}
+		}, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ciphertext := encrypt(tc.before)
+
+			if got, want := len(ciphertext), size(tc.keyUpdates); got != want {
+				t.Errorf("len(ciphertext) = %d, want = %d", got, want)
+			}
+
+			plaintext, err := decrypt(ciphertext)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(plaintext, message) {
+				t.Error("plaintext does not match message")
+			}
+		})
+	}
+
+	t.Run("tampered key update", func(t *testing.T) {
+		ciphertext := encrypt(func(i int, w *aestream.Writer) {
+			if i == 1 {
+				if err := w.UpdateKey(); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+
+		// Flip a bit in the key update's randomness, and then in its tag.
+		for _, pos := range []int{1000 + 3 + newplex.TagSize + 3 + 20, 1000 + 3 + newplex.TagSize + 3 + 40} {
+			tampered := bytes.Clone(ciphertext)
+			tampered[pos] ^= 1
+
+			if _, err := decrypt(tampered); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+				t.Errorf("byte %d: err = %v, want = %v", pos, err, newplex.ErrInvalidCiphertext)
+			}
+		}
+	})
+
+	t.Run("tampered type", func(t *testing.T) {
+		ciphertext := encrypt(func(int, *aestream.Writer) {})
+
+		// Flip bits in the first block's masked type, which the reader must reject rather than try other types.
+		for _, bit := range []byte{0x01, 0x02, 0x80} {
+			tampered := bytes.Clone(ciphertext)
+			tampered[0] ^= bit
+
+			if _, err := decrypt(tampered); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+				t.Errorf("bit %#x: err = %v, want = %v", bit, err, newplex.ErrInvalidCiphertext)
+			}
+		}
+	})
+
+	t.Run("truncated key update", func(t *testing.T) {
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		buf := bytes.NewBuffer(nil)
+		w := newTypedWriter(p, buf)
+		if _, err := w.Write([]byte("message")); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.UpdateKey(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, n := range []int{buf.Len(), buf.Len() - 20} {
+			if _, err := decrypt(buf.Bytes()[:n]); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+				t.Errorf("len = %d: err = %v, want = %v", n, err, newplex.ErrInvalidCiphertext)
+			}
+		}
+	})
+}
+
+func TestWriter_WriteRecord(t *testing.T) {
+	newProtocol := func() *newplex.Protocol {
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		return p
+	}
+
+	// encrypt writes a stream of records, plain blocks, and key updates.
+	encrypt := func() []byte {
+		buf := bytes.NewBuffer(nil)
+		w := newTypedWriter(newProtocol(), buf)
+		if err := w.WriteRecord([]byte("type=greeting"), []byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("plain")); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.UpdateKey(); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteRecord(nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteRecord([]byte("type=farewell"), []byte("goodbye")); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	ciphertext := encrypt()
+
+	t.Run("ReadRecord", func(t *testing.T) {
+		r := newTypedReader(newProtocol(), bytes.NewReader(ciphertext))
+		for _, want := range []struct{ ad, data string }{
+			{"type=greeting", "hello"},
+			{"", "plain"},
+			{"", ""},
+			{"type=farewell", "goodbye"},
+		} {
+			ad, data, err := r.ReadRecord()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(ad) != want.ad || string(data) != want.data {
+				t.Errorf("ReadRecord() = %q/%q, want = %q/%q", ad, data, want.ad, want.data)
+			}
+		}
+
+		if _, _, err := r.ReadRecord(); !errors.Is(err, io.EOF) {
+			t.Errorf("ReadRecord() err = %v, want = %v", err, io.EOF)
+		}
+	})
+
+	t.Run("Read", func(t *testing.T) {
+		got, err := io.ReadAll(newTypedReader(newProtocol(), bytes.NewReader(ciphertext)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := "helloplaingoodbye"; string(got) != want {
+			t.Errorf("ReadAll() = %q, want = %q", got, want)
+		}
+	})
+
+	t.Run("partial Read", func(t *testing.T) {
+		r := newTypedReader(newProtocol(), bytes.NewReader(ciphertext))
+		if _, err := r.Read(make([]byte, 2)); err != nil {
+			t.Fatal(err)
+		}
+
+		ad, data, err := r.ReadRecord()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := string(ad)+"/"+string(data), "type=greeting/llo"; got != want {
+			t.Errorf("ReadRecord() = %q, want = %q", got, want)
+		}
+	})
+
+	t.Run("cleartext associated data", func(t *testing.T) {
+		if !bytes.Contains(ciphertext, []byte("type=greeting")) {
+			t.Error("ciphertext does not contain associated data")
+		}
+
+		if bytes.Contains(ciphertext, []byte("hello")) {
+			t.Error("ciphertext contains plaintext")
+		}
+	})
+
+	t.Run("tampered associated data", func(t *testing.T) {
+		tampered := bytes.Clone(ciphertext)
+		tampered[bytes.Index(ciphertext, []byte("type=greeting"))] ^= 1
+
+		r := newTypedReader(newProtocol(), bytes.NewReader(tampered))
+		if _, _, err := r.ReadRecord(); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("ReadRecord() err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+		}
+	})
+
+	t.Run("tampered record header", func(t *testing.T) {
+		// Flip a bit in the first record's sealed lengths, and then in their tag.
+		for _, pos := range []int{3, 3 + 4 + 8} {
+			tampered := bytes.Clone(ciphertext)
+			tampered[pos] ^= 1
+
+			r := newTypedReader(newProtocol(), bytes.NewReader(tampered))
+			if _, _, err := r.ReadRecord(); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+				t.Errorf("byte %d: ReadRecord() err = %v, want = %v", pos, err, newplex.ErrInvalidCiphertext)
+			}
+		}
+	})
+
+	t.Run("truncated record", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		w := newTypedWriter(newProtocol(), buf)
+		if err := w.WriteRecord([]byte("ad"), []byte("data")); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, n := range []int{buf.Len(), buf.Len() - 10, 3 + 10} {
+			r := newTypedReader(newProtocol(), bytes.NewReader(buf.Bytes()[:n]))
+			if _, err := io.ReadAll(r); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+				t.Errorf("len = %d: err = %v, want = %v", n, err, newplex.ErrInvalidCiphertext)
+			}
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		w := newTypedWriter(newProtocol(), io.Discard)
+		large := make([]byte, aestream.MaxBlockSize+1)
+		for _, tc := range [][2][]byte{{large, nil}, {nil, large}} {
+			if err := w.WriteRecord(tc[0], tc[1]); !errors.Is(err, aestream.ErrRecordTooLarge) {
+				t.Errorf("WriteRecord(len(ad)=%d, len(data)=%d) err = %v, want = %v", len(tc[0]), len(tc[1]), err,
+					aestream.ErrRecordTooLarge)
+			}
+		}
+	})
+}
+
+func TestWriter_SetPadding(t *testing.T) {
+	newProtocol := func() *newplex.Protocol {
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		return p
+	}
+
+	buf := bytes.NewBuffer(nil)
+	w := newTypedWriter(newProtocol(), buf)
+	w.SetPadding(padding.PowerOfTwo)
+	for _, s := range []string{"a", "hello", "this is 16 bytes", "\x80\x00"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteRecord([]byte("ad"), []byte("record")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(make([]byte, aestream.MaxBlockSize)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := buf.Bytes()
+
+	t.Run("padded lengths", func(t *testing.T) {
+		// Each block is padded to the next power of two of its length plus one, up to MaxBlockSize. The maximum-sized
+		// write is split into two blocks, since a padded block holds at most MaxBlockSize-1 bytes.
+		blocks := []int{2, 8, 32, 4, aestream.MaxBlockSize, 2}
+		want := 3 + 4 + 2*newplex.TagSize + 2 + 8 + 3 + newplex.TagSize
+		for _, n := range blocks {
+			want += 3 + n + newplex.TagSize
+		}
+
+		if got := len(ciphertext); got != want {
+			t.Errorf("len(ciphertext) = %d, want = %d", got, want)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		r := newTypedReader(newProtocol(), bytes.NewReader(ciphertext))
+		r.SetPadding(true)
+		for _, want := range []struct{ ad, data string }{
+			{"", "a"},
+			{"", "hello"},
+			{"", "this is 16 bytes"},
+			{"", "\x80\x00"},
+			{"ad", "record"},
+			{"", string(make([]byte, aestream.MaxBlockSize-1))},
+			{"", "\x00"},
+		} {
+			ad, data, err := r.ReadRecord()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(ad) != want.ad || string(data) != want.data {
+				t.Errorf("ReadRecord() = %q/%d bytes, want = %q/%d bytes", ad, len(data), want.ad, len(want.data))
+			}
+		}
+
+		if _, _, err := r.ReadRecord(); !errors.Is(err, io.EOF) {
+			t.Errorf("ReadRecord() err = %v, want = %v", err, io.EOF)
+		}
+	})
+
+	t.Run("reader without padding", func(t *testing.T) {
+		r := newTypedReader(newProtocol(), bytes.NewReader(ciphertext))
+		if _, err := io.ReadAll(r); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("ReadAll() err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+		}
+	})
+
+	t.Run("record too large", func(t *testing.T) {
+		w := newTypedWriter(newProtocol(), io.Discard)
+		w.SetPadding(padding.Padme)
+		if err := w.WriteRecord(nil, make([]byte, aestream.MaxBlockSize)); !errors.Is(err, aestream.ErrRecordTooLarge) {
+			t.Errorf("WriteRecord() err = %v, want = %v", err, aestream.ErrRecordTooLarge)
+		}
+	})
+}
+
+func TestWriter_SetBuffering(t *testing.T) {
+	newProtocol := func() *newplex.Protocol {
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		return p
+	}
+
+	t.Run("coalesced writes", func(t *testing.T) {
+		message := testdata.New("newplex aestream buffering").Data(10_000)
+		buf := bytes.NewBuffer(nil)
+		w := aestream.NewWriter(newProtocol(), buf)
+		w.SetBuffering(4096, 0)
+		for chunk := range slices.Chunk(message, 10) {
+			if _, err := w.Write(chunk); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// The writes are sealed in blocks of 4096, 4096, and 1808 bytes, followed by the terminal block.
+		if got, want := buf.Len(), len(message)+4*(2+newplex.TagSize); got != want {
+			t.Errorf("len(ciphertext) = %d, want = %d", got, want)
+		}
+
+		got, err := io.ReadAll(aestream.NewReader(newProtocol(), buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, message) {
+			t.Errorf("ReadAll() = %d bytes, want = %d bytes", len(got), len(message))
+		}
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		w := aestream.NewWriter(newProtocol(), buf)
+		w.SetBuffering(aestream.MaxBlockSize, 0)
+		if _, err := w.Write([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := buf.Len(), 0; got != want {
+			t.Errorf("len(ciphertext) = %d before Flush(), want = %d", got, want)
+		}
+
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := buf.Len(), 5+2+newplex.TagSize; got != want {
+			t.Errorf("len(ciphertext) = %d after Flush(), want = %d", got, want)
+		}
+	})
+
+	t.Run("max delay", func(t *testing.T) {
+		blocks := make(chanWriter, 1)
+		w := aestream.NewWriter(newProtocol(), blocks)
+		w.SetBuffering(aestream.MaxBlockSize, 10*time.Millisecond)
+		if _, err := w.Write([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case block := <-blocks:
+			if got, want := len(block), 5+2+newplex.TagSize; got != want {
+				t.Errorf("len(block) = %d, want = %d", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("buffered data was not flushed after the maximum delay")
+		}
+	})
+
+	t.Run("records", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		w := newTypedWriter(newProtocol(), buf)
+		w.SetBuffering(aestream.MaxBlockSize, 0)
+		if _, err := w.Write([]byte("block")); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteRecord([]byte("ad"), []byte("record")); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// The buffered block is flushed before the record.
+		r := newTypedReader(newProtocol(), buf)
+		for _, want := range []struct{ ad, data string }{{"", "block"}, {"ad", "record"}} {
+			ad, data, err := r.ReadRecord()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(ad) != want.ad || string(data) != want.data {
+				t.Errorf("ReadRecord() = %q/%q, want = %q/%q", ad, data, want.ad, want.data)
+			}
+		}
+	})
+
+	t.Run("write after close", func(t *testing.T) {
+		w := aestream.NewWriter(newProtocol(), io.Discard)
+		w.SetBuffering(aestream.MaxBlockSize, 0)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write([]byte("hello")); err == nil {
+			t.Error("Write() after Close() err = nil, want = err")
+		}
+	})
+}
+
+func TestWriter_ReadFrom(t *testing.T) {
+	newProtocol := func() *newplex.Protocol {
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		return p
+	}
+	message := testdata.New("newplex aestream read from").Data(200_000)
+
+	for name, r := range map[string]func() io.Reader{
+		"full reads":     func() io.Reader { return bytes.NewReader(message) },
+		"one-byte reads": func() io.Reader { return iotest.OneByteReader(bytes.NewReader(message)) },
+		"half reads":     func() io.Reader { return iotest.HalfReader(bytes.NewReader(message)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, size := range []int{0, 1000, aestream.MaxBlockSize} {
+				buf := bytes.NewBuffer(nil)
+				w := aestream.NewWriter(newProtocol(), buf)
+				w.SetBuffering(size, 0)
+				n, err := w.ReadFrom(r())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+
+				if got, want := n, int64(len(message)); got != want {
+					t.Errorf("ReadFrom(size=%d) = %d, want = %d", size, got, want)
+				}
+
+				got, err := io.ReadAll(aestream.NewReader(newProtocol(), bytes.NewReader(buf.Bytes())))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(got, message) {
+					t.Errorf("ReadAll(size=%d) = %d bytes, want = %d bytes", size, len(got), len(message))
+				}
+
+				// With buffering, blocks are the same size regardless of how the data is read.
+				if size > 0 {
+					var want bytes.Buffer
+					w := aestream.NewWriter(newProtocol(), &want)
+					w.SetBuffering(size, 0)
+					_, _ = w.Write(message)
+					_ = w.Close()
+
+					if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+						t.Errorf("ReadFrom(size=%d) and Write() ciphertexts differ", size)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("underlying reader error", func(t *testing.T) {
+		er := &testdata.ErrReader{Err: errors.New("read failed")}
+		w := aestream.NewWriter(newProtocol(), io.Discard)
+
+		if _, err := w.ReadFrom(er); !errors.Is(err, er.Err) {
+			t.Errorf("expected %v, got %v", er.Err, err)
+		}
+	})
+
+	t.Run("underlying writer error", func(t *testing.T) {
+		ew := &testdata.ErrWriter{Err: errors.New("write failed")}
+		w := aestream.NewWriter(newProtocol(), ew)
+
+		if _, err := w.ReadFrom(bytes.NewReader(message)); !errors.Is(err, ew.Err) {
+			t.Errorf("expected %v, got %v", ew.Err, err)
+		}
+	})
+}
+
+// chanWriter is an io.Writer which sends a copy of each write to a channel.
+type chanWriter chan []byte
+
+func (c chanWriter) Write(p []byte) (int, error) {
+	c <- bytes.Clone(p)
+	return len(p), nil
+}
+
 func TestNewReader(t *testing.T) {
 	t.Run("truncation", func(t *testing.T) {
 		p1 := newplex.NewProtocol("example")
@@ -223,6 +860,66 @@ func TestReader_Read(t *testing.T) {
 	})
 }
 
+func TestReader_WriteTo(t *testing.T) {
+	newProtocol := func() *newplex.Protocol {
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		return p
+	}
+	message := testdata.New("newplex aestream write to").Data(200_000)
+
+	buf := bytes.NewBuffer(nil)
+	w := newTypedWriter(newProtocol(), buf)
+	if _, err := w.Write(message); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRecord([]byte("ad"), []byte("record")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := buf.Bytes()
+	want := append(bytes.Clone(message), "record"...)
+
+	t.Run("round trip", func(t *testing.T) {
+		r := newTypedReader(newProtocol(), bytes.NewReader(ciphertext))
+
+		// Partially read the first block, then write the rest of the stream.
+		head := make([]byte, 100)
+		if _, err := io.ReadFull(r, head); err != nil {
+			t.Fatal(err)
+		}
+
+		var got bytes.Buffer
+		n, err := r.WriteTo(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != int64(len(want)-len(head)) || !bytes.Equal(append(head, got.Bytes()...), want) {
+			t.Errorf("WriteTo() = %d bytes, want = %d bytes", n, len(want)-len(head))
+		}
+	})
+
+	t.Run("underlying writer error", func(t *testing.T) {
+		ew := &testdata.ErrWriter{Err: errors.New("write failed")}
+		r := newTypedReader(newProtocol(), bytes.NewReader(ciphertext))
+
+		if _, err := r.WriteTo(ew); !errors.Is(err, ew.Err) {
+			t.Errorf("expected %v, got %v", ew.Err, err)
+		}
+	})
+
+	t.Run("truncated stream", func(t *testing.T) {
+		r := newTypedReader(newProtocol(), bytes.NewReader(ciphertext[:len(ciphertext)-1]))
+
+		if _, err := r.WriteTo(io.Discard); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("WriteTo() err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+		}
+	})
+}
+
 func BenchmarkNewWriter(b *testing.B) {
 	for _, length := range lengths {
 		b.Run(length.name, func(b *testing.B) {
@@ -0,0 +1,307 @@
+package aestream
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/codahale/newplex"
+)
+
+// A ParallelWriter encrypts data into an index-keyed stream, sealing batches of blocks on multiple goroutines.
+//
+// Unlike a Writer, which chains each block to the one before it, a ParallelWriter masks the header of each block and
+// seals the block with a copy of the protocol into which the block's index has been mixed, so blocks can be sealed and
+// opened independently of each other while reordered, dropped, or spliced blocks still fail to open. As with a Writer,
+// the stream ends with an empty terminal block, so a truncated stream is detected. Written data is sealed in blocks of
+// MaxBlockSize bytes, regardless of the size of each write.
+//
+// Index-keyed streams do not support records, key updates, or padding, and can only be read by a ParallelReader.
+type ParallelWriter struct {
+	p           *newplex.Protocol
+	w           io.Writer
+	concurrency int
+	index       uint64 // index of the first block in buf
+	buf         []byte // plaintext accumulator, sealed when it holds a full batch
+	out         []byte // ciphertext buffer
+	closed      bool
+	err         error
+}
+
+// NewParallelWriter returns a ParallelWriter which seals blocks with the given number of goroutines. If concurrency is
+// less than 1, runtime.GOMAXPROCS(0) is used.
+//
+// The protocol's prior state must be probabilistic, as the same protocol state and block index always produce the same
+// keystream. The returned ParallelWriter MUST be closed for the encrypted stream to be valid. The provided
+// newplex.Protocol MUST NOT be used while the writer is open.
+func NewParallelWriter(p *newplex.Protocol, w io.Writer, concurrency int) *ParallelWriter {
+	concurrency = concurrencyOrDefault(concurrency)
+	blocks := concurrency * batchBlocks
+	return &ParallelWriter{
+		p:           p,
+		w:           w,
+		concurrency: concurrency,
+		buf:         make([]byte, 0, blocks*MaxBlockSize),
+		out:         make([]byte, (blocks+1)*parallelBlockSize),
+	}
+}
+
+// Write buffers data until a full batch of blocks is available, then seals the batch and writes it to the underlying
+// io.Writer.
+func (w *ParallelWriter) Write(data []byte) (n int, err error) {
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	for len(data) > 0 {
+		k := copy(w.buf[len(w.buf):cap(w.buf)], data)
+		w.buf = w.buf[:len(w.buf)+k]
+		data = data[k:]
+		n += k
+
+		// Seal and emit the batch once it's full.
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(false); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close seals any remaining buffered data, along with the terminal block, and writes it to the underlying io.Writer.
+func (w *ParallelWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.err != nil {
+		return w.err
+	}
+	return w.flush(true)
+}
+
+// flush seals the blocks in the buffer, plus the terminal block if final is true, and writes them.
+func (w *ParallelWriter) flush(final bool) error {
+	blocks := (len(w.buf) + MaxBlockSize - 1) / MaxBlockSize
+	if final {
+		blocks++
+	}
+
+	block := func(i int) []byte {
+		return w.buf[min(i*MaxBlockSize, len(w.buf)):min((i+1)*MaxBlockSize, len(w.buf))]
+	}
+
+	parallelize(blocks, w.concurrency, func(i int) {
+		p, off := blockProtocol(w.p, w.index+uint64(i)), i*parallelBlockSize
+		header := binary.BigEndian.AppendUint16(w.out[off:off:off+parallelBlockSize], uint16(len(block(i))))
+		p.Seal("block", p.Mask("header", header[:0], header), block(i))
+	})
+
+	// Only the last data block and the terminal block may be short, so move them up to follow the full blocks.
+	n := 0
+	for i := range blocks {
+		off, size := i*parallelBlockSize, headerSize+len(block(i))+newplex.TagSize
+		if n != off {
+			copy(w.out[n:], w.out[off:off+size])
+		}
+		n += size
+	}
+
+	if _, err := w.w.Write(w.out[:n]); err != nil {
+		// Discard the batch's plaintext and ciphertext, since the stream is unusable.
+		clear(w.buf[:cap(w.buf)])
+		clear(w.out)
+		w.buf = w.buf[:0]
+		w.err = err
+		return err
+	}
+	w.index += uint64(blocks)
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// A ParallelReader reads and authenticates an index-keyed stream written by a ParallelWriter, opening batches of blocks
+// on multiple goroutines.
+//
+// The reader unmasks each block's header as it reads a batch of blocks, then opens the blocks concurrently. Plaintext
+// is only returned once every block in its batch has been authenticated. Modified, reordered, or truncated streams
+// result in a newplex.ErrInvalidCiphertext.
+type ParallelReader struct {
+	p           *newplex.Protocol
+	r           io.Reader
+	concurrency int
+	index       uint64          // index of the first block in the next batch
+	in          []byte          // ciphertext of the current batch, without headers
+	blocks      []parallelBlock // the blocks of the current batch
+	out         []byte          // plaintext buffer
+	buf         []byte          // decrypted plaintext not yet returned to the caller
+	eos         bool            // true after the terminal block has been read
+	err         error
+}
+
+// A parallelBlock is a block of a batch being read by a ParallelReader.
+type parallelBlock struct {
+	p       newplex.Protocol // the block's protocol, after unmasking its header
+	in, out int              // the block's offsets in the ciphertext and plaintext buffers
+	n       int              // the block's length
+}
+
+// NewParallelReader returns a ParallelReader which opens blocks written by a ParallelWriter with the given number of
+// goroutines. If concurrency is less than 1, runtime.GOMAXPROCS(0) is used.
+//
+// The protocol state provided must be exactly synchronized with the protocol state used to initialize the writer. The
+// provided newplex.Protocol MUST NOT be used while the reader is open.
+func NewParallelReader(p *newplex.Protocol, r io.Reader, concurrency int) *ParallelReader {
+	concurrency = concurrencyOrDefault(concurrency)
+	blocks := concurrency * batchBlocks
+	return &ParallelReader{
+		p:           p,
+		r:           r,
+		concurrency: concurrency,
+		in:          make([]byte, 0, blocks*(MaxBlockSize+newplex.TagSize)),
+		blocks:      make([]parallelBlock, 0, blocks),
+		out:         make([]byte, blocks*MaxBlockSize),
+	}
+}
+
+// Read reads and decrypts data from the underlying stream.
+//
+// It returns io.EOF when the stream is fully read and authenticated. If the stream is tampered with, truncated, or
+// incorrectly formatted, it returns newplex.ErrInvalidCiphertext.
+func (r *ParallelReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.fill()
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// fill reads the next batch of blocks, unmasking their headers, and opens them into r.buf.
+func (r *ParallelReader) fill() error {
+	if r.eos {
+		return io.EOF
+	}
+
+	// Read blocks until the batch is full or the terminal block has been read.
+	r.in, r.blocks = r.in[:0], r.blocks[:0]
+	size := 0
+	for len(r.blocks) < cap(r.blocks) && !r.eos {
+		p := blockProtocol(r.p, r.index+uint64(len(r.blocks)))
+
+		var header [headerSize]byte
+		if err := r.read(header[:]); err != nil {
+			return err
+		}
+		p.Unmask("header", header[:0], header[:])
+
+		n, off := int(binary.BigEndian.Uint16(header[:])), len(r.in)
+		r.in = slices.Grow(r.in, n+newplex.TagSize)[:off+n+newplex.TagSize]
+		if err := r.read(r.in[off:]); err != nil {
+			return err
+		}
+
+		r.blocks = append(r.blocks, parallelBlock{p: p, in: off, out: size, n: n})
+		size += n
+		r.eos = n == 0
+	}
+
+	var invalid atomic.Bool
+	parallelize(len(r.blocks), r.concurrency, func(i int) {
+		b := &r.blocks[i]
+		if _, err := b.p.Open("block", r.out[b.out:b.out], r.in[b.in:b.in+b.n+newplex.TagSize]); err != nil {
+			invalid.Store(true)
+		}
+	})
+	if invalid.Load() {
+		return newplex.ErrInvalidCiphertext
+	}
+
+	r.index += uint64(len(r.blocks))
+	r.buf = r.out[:size]
+	if size == 0 {
+		return io.EOF
+	}
+	return nil
+}
+
+// read reads exactly len(p) bytes, returning newplex.ErrInvalidCiphertext if the stream ends first.
+func (r *ParallelReader) read(p []byte) error {
+	if _, err := io.ReadFull(r.r, p); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return newplex.ErrInvalidCiphertext
+		}
+		return err
+	}
+	return nil
+}
+
+// blockProtocol returns a clone of p with the given block index mixed in. The clone does not inherit p's tracer, so
+// blocks can be sealed and opened concurrently.
+func blockProtocol(p *newplex.Protocol, index uint64) newplex.Protocol {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], index)
+	bp := *p.Clone()
+	bp.Mix("index", b[:])
+	return bp
+}
+
+// parallelize calls f for each index in [0, n), dividing the indexes into contiguous ranges across at most the given
+// number of goroutines, and waits for them to return.
+func parallelize(n, concurrency int, f func(i int)) {
+	per := (n + concurrency - 1) / concurrency
+	if per == 0 || n <= per {
+		for i := range n {
+			f(i)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for start := 0; start < n; start += per {
+		wg.Go(func() {
+			for i := start; i < min(start+per, n); i++ {
+				f(i)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// concurrencyOrDefault returns the given concurrency, or runtime.GOMAXPROCS(0) if it is less than 1.
+func concurrencyOrDefault(concurrency int) int {
+	if concurrency < 1 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return concurrency
+}
+
+const (
+	// batchBlocks is the number of blocks each goroutine seals or opens per batch.
+	batchBlocks = 4
+
+	// parallelBlockSize is the size of a full block in an index-keyed stream, including its header and tag.
+	parallelBlockSize = headerSize + MaxBlockSize + newplex.TagSize
+)
+
+var (
+	_ io.WriteCloser = (*ParallelWriter)(nil)
+	_ io.Reader      = (*ParallelReader)(nil)
+)
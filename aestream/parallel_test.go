@@ -0,0 +1,214 @@
+package aestream_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/aestream"
+	"github.com/codahale/newplex/internal/testdata"
+)
+
+// parallelBlockSize is the size of a full block of an index-keyed stream, including its header and tag.
+const parallelBlockSize = 2 + aestream.MaxBlockSize + newplex.TagSize
+
+// parallelBatch is the size of a batch of a ParallelWriter with a concurrency of 2, which seals 4 blocks per goroutine.
+const parallelBatch = 8 * aestream.MaxBlockSize
+
+func TestParallelWriter(t *testing.T) {
+	p := newplex.NewProtocol("test")
+	p.Mix("key", []byte("it's a key"))
+	drbg := testdata.New("newplex aestream parallel")
+
+	for _, n := range []int{
+		0, 1, aestream.MaxBlockSize - 1, aestream.MaxBlockSize, aestream.MaxBlockSize + 1,
+		parallelBatch - 1, parallelBatch, parallelBatch + 1, 3*parallelBatch + 100,
+	} {
+		t.Run(fmt.Sprintf("%d bytes", n), func(t *testing.T) {
+			plaintext := drbg.Data(n)
+
+			var ciphertexts [][]byte
+			for _, concurrency := range []int{1, 2, 3} {
+				var buf bytes.Buffer
+				w := aestream.NewParallelWriter(p.Clone(), &buf, concurrency)
+				// Write in uneven chunks to exercise buffering.
+				for chunk := range slices.Chunk(plaintext, 50_000) {
+					if _, err := w.Write(chunk); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+				ciphertexts = append(ciphertexts, buf.Bytes())
+			}
+
+			// The plaintext is sealed in full blocks and a final partial block, followed by the terminal block.
+			blocks := (n + aestream.MaxBlockSize - 1) / aestream.MaxBlockSize
+			if got, want := len(ciphertexts[0]), n+(blocks+1)*(2+newplex.TagSize); got != want {
+				t.Errorf("len(ciphertext) = %d, want = %d", got, want)
+			}
+
+			for i, ciphertext := range ciphertexts[1:] {
+				if !bytes.Equal(ciphertext, ciphertexts[0]) {
+					t.Errorf("ciphertext %d differs from ciphertext 0", i+1)
+				}
+			}
+
+			for _, concurrency := range []int{0, 1, 3} {
+				r := aestream.NewParallelReader(p.Clone(), bytes.NewReader(ciphertexts[0]), concurrency)
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatalf("ReadAll(concurrency=%d) err = %v", concurrency, err)
+				}
+
+				if !bytes.Equal(got, plaintext) {
+					t.Errorf("ReadAll(concurrency=%d) = %d bytes, want = %d bytes", concurrency, len(got), n)
+				}
+			}
+		})
+	}
+
+	t.Run("write after close", func(t *testing.T) {
+		w := aestream.NewParallelWriter(p.Clone(), io.Discard, 2)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write([]byte("hello")); !errors.Is(err, aestream.ErrClosed) {
+			t.Errorf("Write() err = %v, want = %v", err, aestream.ErrClosed)
+		}
+	})
+
+	t.Run("underlying writer error", func(t *testing.T) {
+		ew := &testdata.ErrWriter{Err: errors.New("write failed")}
+		w := aestream.NewParallelWriter(p.Clone(), ew, 2)
+
+		if _, err := w.Write(make([]byte, parallelBatch)); !errors.Is(err, ew.Err) {
+			t.Errorf("Write() err = %v, want = %v", err, ew.Err)
+		}
+
+		if err := w.Close(); !errors.Is(err, ew.Err) {
+			t.Errorf("Close() err = %v, want = %v", err, ew.Err)
+		}
+	})
+}
+
+func TestParallelWriter_traced(t *testing.T) {
+	p := newplex.NewProtocol("test")
+	p.Mix("key", []byte("it's a key"))
+	plaintext := testdata.New("newplex aestream parallel").Data(parallelBatch + 100)
+
+	var writerLog, readerLog newplex.TraceLog
+	wp, rp := p.Clone(), p.Clone()
+	wp.SetTracer(&writerLog)
+	rp.SetTracer(&readerLog)
+
+	var buf bytes.Buffer
+	w := aestream.NewParallelWriter(wp, &buf, 4)
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := io.ReadAll(aestream.NewParallelReader(rp, &buf, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("ReadAll() = %d bytes, want = %d bytes", len(got), len(plaintext))
+	}
+
+	// Only the Start events are traced.
+	if got, want := len(writerLog)+len(readerLog), 2; got != want {
+		t.Errorf("len(events) = %d, want = %d", got, want)
+	}
+}
+
+func TestParallelReader(t *testing.T) {
+	p := newplex.NewProtocol("test")
+	p.Mix("key", []byte("it's a key"))
+	plaintext := testdata.New("newplex aestream parallel").Data(3*parallelBatch + 100)
+
+	var buf bytes.Buffer
+	w := aestream.NewParallelWriter(p.Clone(), &buf, 2)
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := buf.Bytes()
+
+	t.Run("chained reader", func(t *testing.T) {
+		if _, err := io.ReadAll(aestream.NewReader(p.Clone(), bytes.NewReader(ciphertext))); err == nil {
+			t.Error("ReadAll() err = nil, want = error")
+		}
+	})
+
+	flip := func(i int) []byte {
+		b := bytes.Clone(ciphertext)
+		b[i] ^= 1
+		return b
+	}
+
+	swapped := bytes.Clone(ciphertext)
+	copy(swapped[:parallelBlockSize], ciphertext[parallelBlockSize:2*parallelBlockSize])
+	copy(swapped[parallelBlockSize:2*parallelBlockSize], ciphertext[:parallelBlockSize])
+
+	for name, modified := range map[string][]byte{
+		"empty":                  nil,
+		"modified header":        flip(10 * parallelBlockSize),
+		"modified block":         flip(10*parallelBlockSize + 10),
+		"modified terminal":      flip(len(ciphertext) - 1),
+		"reordered blocks":       swapped,
+		"truncated mid-block":    ciphertext[:len(ciphertext)-100],
+		"dropped terminal block": ciphertext[:len(ciphertext)-2-newplex.TagSize],
+		"dropped first block":    ciphertext[parallelBlockSize:],
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := aestream.NewParallelReader(p.Clone(), bytes.NewReader(modified), 2)
+			if _, err := io.ReadAll(r); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+				t.Errorf("ReadAll() err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+			}
+		})
+	}
+
+	t.Run("underlying reader error", func(t *testing.T) {
+		er := &testdata.ErrReader{Err: errors.New("read failed")}
+		r := aestream.NewParallelReader(p.Clone(), er, 2)
+
+		if _, err := r.Read(make([]byte, 100)); !errors.Is(err, er.Err) {
+			t.Errorf("Read() err = %v, want = %v", err, er.Err)
+		}
+	})
+}
+
+func BenchmarkParallelWriter(b *testing.B) {
+	p := newplex.NewProtocol("example")
+	p.Mix("key", []byte("it's a key"))
+	plaintext := make([]byte, 16*1024*1024)
+
+	for _, concurrency := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("%d", concurrency), func(b *testing.B) {
+			b.SetBytes(int64(len(plaintext)))
+			b.ReportAllocs()
+			for b.Loop() {
+				w := aestream.NewParallelWriter(p.Clone(), io.Discard, concurrency)
+				if _, err := w.Write(plaintext); err != nil {
+					b.Fatal(err)
+				}
+				if err := w.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -11,9 +11,11 @@ import (
 	"io"
 	"log/slog"
 	"net"
+	"sync"
 
-	"github.com/codahale/newplex/aestream"
+	"github.com/codahale/newplex/conn"
 	"github.com/codahale/newplex/handshake"
+	"github.com/codahale/newplex/padding"
 	"github.com/gtank/ristretto255"
 )
 
@@ -21,6 +23,11 @@ func main() {
 	var (
 		listen  = flag.String("listen", "127.0.0.1:6060", "the address to listen on")
 		connect = flag.String("connect", "127.0.0.1:5050", "the address to connect to")
+		allow   = flag.String("allowlist", "", "a file of hex-encoded static keys of allowed peers")
+		rekey   = flag.Int64("rekey", 1<<30, "the number of bytes after which to update the key (0 to disable)")
+		resume  = flag.Bool("resume", false, "resume sessions with tickets issued by the reverse proxy")
+		pad     = flag.String("padding", "none", "the padding policy (none, pow2, padme, buckets:N,..., or random:N)")
+		delay   = flag.Duration("delay", 0, "the maximum time to buffer sent data before sending it (0 to disable)")
 	)
 	flag.Parse()
 
@@ -34,6 +41,35 @@ func main() {
 	qIS := ristretto255.NewIdentityElement().ScalarBaseMult(dIS)
 	log.Info("starting", "pk", hex.EncodeToString(qIS.Bytes()))
 
+	config := &conn.Config{Domain: "newplex.ae_proxy", StaticKey: dIS, KeyUpdateInterval: *rekey, FlushDelay: *delay}
+	policy, err := padding.Parse(*pad)
+	if err != nil {
+		panic(err)
+	}
+	config.Padding = policy
+
+	if *allow != "" {
+		allowlist, err := handshake.LoadAllowlist(*allow)
+		if err != nil {
+			panic(err)
+		}
+		config.VerifyPeer = allowlist.Verify
+		log.Info("loaded allowlist", "path", *allow, "keys", allowlist.Len())
+	}
+
+	// Keep the most recently issued session, if resuming sessions.
+	var (
+		sessionMu sync.Mutex
+		session   *handshake.Session
+	)
+	if *resume {
+		config.OnSession = func(s *handshake.Session) {
+			sessionMu.Lock()
+			defer sessionMu.Unlock()
+			session = s
+		}
+	}
+
 	listenConfig := new(net.ListenConfig)
 	listener, err := listenConfig.Listen(context.Background(), "tcp", *listen)
 	if err != nil {
@@ -42,78 +78,52 @@ func main() {
 	log.Info("listening", "addr", listener.Addr())
 
 	for {
-		conn, err := listener.Accept()
+		local, err := listener.Accept()
 		if err != nil {
 			log.Error("failed to accept connection", "err", err)
 			continue
 		}
 
 		go func() {
-			log.Info("accepted new connection", "addr", conn.RemoteAddr())
+			log.Info("accepted new connection", "addr", local.RemoteAddr())
 			defer func() {
-				_ = conn.Close()
-				log.Info("closed connection", "addr", conn.RemoteAddr())
+				_ = local.Close()
+				log.Info("closed connection", "addr", local.RemoteAddr())
 			}()
 
-			log.Info("connecting", "addr", *connect)
-			dialer := new(net.Dialer)
-			client, err := dialer.DialContext(context.Background(), "tcp", *connect)
+			// Each session is only used once.
+			sessionMu.Lock()
+			s := session
+			session = nil
+			sessionMu.Unlock()
+
+			log.Info("connecting", "addr", *connect, "resume", s != nil)
+			secure, err := dial(log, *connect, config, s)
 			if err != nil {
 				log.Error("error connecting", "err", err)
 				return
 			}
 			defer func() {
-				_ = client.Close()
+				_ = secure.Close()
 			}()
-
-			finish, request, err := handshake.Initiate("newplex.ae_proxy", dIS, rand.Reader)
-			if err != nil {
-				log.Error("error initiating handshake", "err", err)
-				return
-			}
-			_, err = client.Write(request)
-			if err != nil {
-				log.Error("error writing handshake request", "err", err)
-				return
-			}
-			response := make([]byte, handshake.ResponseSize)
-			_, err = io.ReadFull(client, response)
-			if err != nil {
-				log.Error("error reading handshake response", "err", err)
-				return
-			}
-			send, recv, qRS, confirmation, err := finish(response)
-			if err != nil {
-				log.Error("error finishing handshake", "err", err)
-				return
-			}
-			log.Info("handshake established", "pk", hex.EncodeToString(qRS.Bytes()))
-			_, err = client.Write(confirmation)
-			if err != nil {
-				log.Error("error writing handshake confirmation", "err", err)
-				return
-			}
-
-			r := aestream.NewReader(recv, client)
-			w := aestream.NewWriter(send, client)
+			log.Info("handshake established", "pk", hex.EncodeToString(secure.RemoteStaticKey().Bytes()))
 			defer func() {
 				log.Info("closing aestream")
-				err = w.Close()
-				if err != nil {
+				if err := secure.CloseWrite(); err != nil {
 					log.Error("error closing aestream", "err", err)
 				}
 			}()
 
 			ctx, cancel := context.WithCancelCause(context.Background())
 			go func() {
-				if _, err := io.Copy(w, conn); err != nil && !errors.Is(err, net.ErrClosed) {
+				if _, err := io.Copy(secure, local); err != nil && !errors.Is(err, net.ErrClosed) {
 					cancel(err)
 				} else {
 					cancel(nil)
 				}
 			}()
 			go func() {
-				if _, err := io.Copy(conn, r); err != nil && !errors.Is(err, net.ErrClosed) {
+				if _, err := io.Copy(local, secure); err != nil && !errors.Is(err, net.ErrClosed) {
 					cancel(err)
 				} else {
 					cancel(nil)
@@ -126,3 +136,18 @@ func main() {
 		}()
 	}
 }
+
+// dial connects to the given address and performs a handshake, resuming the given session if it is not nil. If the
+// session cannot be resumed, it connects again and performs a full handshake.
+func dial(log *slog.Logger, address string, config *conn.Config, session *handshake.Session) (*conn.Conn, error) {
+	if session != nil {
+		resume := *config
+		resume.Session = session
+		secure, err := conn.Dial("tcp", address, &resume)
+		if err == nil {
+			return secure, nil
+		}
+		log.Warn("error resuming session", "err", err)
+	}
+	return conn.Dial("tcp", address, config)
+}
@@ -11,9 +11,12 @@ import (
 	"io"
 	"log/slog"
 	"net"
+	"sync/atomic"
+	"time"
 
-	"github.com/codahale/newplex/aestream"
+	"github.com/codahale/newplex/conn"
 	"github.com/codahale/newplex/handshake"
+	"github.com/codahale/newplex/padding"
 	"github.com/gtank/ristretto255"
 )
 
@@ -21,6 +24,12 @@ func main() {
 	var (
 		listen  = flag.String("listen", "127.0.0.1:5050", "the address to listen on")
 		connect = flag.String("connect", "127.0.0.1:4040", "the address to connect to")
+		allow   = flag.String("allowlist", "", "a file of hex-encoded static keys of allowed peers")
+		rekey   = flag.Int64("rekey", 1<<30, "the number of bytes after which to update the key (0 to disable)")
+		tickets = flag.Duration("tickets", 0, "the lifetime of session resumption tickets (0 to disable)")
+		cookies = flag.Int64("cookies", 0, "require cookies above this many concurrent handshakes (0 to disable)")
+		pad     = flag.String("padding", "none", "the padding policy (none, pow2, padme, buckets:N,..., or random:N)")
+		delay   = flag.Duration("delay", 0, "the maximum time to buffer sent data before sending it (0 to disable)")
 	)
 
 	flag.Parse()
@@ -34,6 +43,41 @@ func main() {
 	qRS := ristretto255.NewIdentityElement().ScalarBaseMult(dRS)
 	log.Info("starting", "pk", hex.EncodeToString(qRS.Bytes()))
 
+	config := &conn.Config{Domain: "newplex.ae_proxy", StaticKey: dRS, KeyUpdateInterval: *rekey, FlushDelay: *delay}
+	policy, err := padding.Parse(*pad)
+	if err != nil {
+		panic(err)
+	}
+	config.Padding = policy
+
+	if *allow != "" {
+		allowlist, err := handshake.LoadAllowlist(*allow)
+		if err != nil {
+			panic(err)
+		}
+		config.VerifyPeer = allowlist.Verify
+		log.Info("loaded allowlist", "path", *allow, "keys", allowlist.Len())
+	}
+
+	if *tickets > 0 {
+		key := make([]byte, handshake.TicketKeySize)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+		config.TicketKey = handshake.NewTicketKey(key, *tickets)
+	}
+
+	// Require cookies when too many handshakes are in progress.
+	var handshakes atomic.Int64
+	if *cookies > 0 {
+		key := make([]byte, handshake.CookieKeySize)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+		config.CookieKey = handshake.NewCookieKey(key, 2*time.Minute)
+		config.UnderLoad = func() bool { return handshakes.Load() > *cookies }
+	}
+
 	listenConfig := new(net.ListenConfig)
 	listener, err := listenConfig.Listen(context.Background(), "tcp", *listen)
 	if err != nil {
@@ -42,54 +86,31 @@ func main() {
 	log.Info("listening", "addr", listener.Addr())
 
 	for {
-		conn, err := listener.Accept()
+		remote, err := listener.Accept()
 		if err != nil {
 			log.Error("failed to accept connection", "err", err)
 			continue
 		}
 
 		go func() {
-			log.Info("accepted new connection", "addr", conn.RemoteAddr())
+			log.Info("accepted new connection", "addr", remote.RemoteAddr())
 			defer func() {
-				_ = conn.Close()
+				_ = remote.Close()
 				log.Info("closed connection")
 			}()
 
-			request := make([]byte, handshake.RequestSize)
-			_, err = io.ReadFull(conn, request)
-			if err != nil {
-				log.Error("error connecting", "err", err)
-				return
-			}
-			finish, response, err := handshake.Respond("newplex.ae_proxy", rand.Reader, dRS, request)
-			if err != nil {
-				log.Error("error connecting", "err", err)
-				return
-			}
-			_, err = conn.Write(response)
-			if err != nil {
-				log.Error("error connecting", "err", err)
-				return
-			}
-			confirmation := make([]byte, handshake.ConfirmationSize)
-			_, err = io.ReadFull(conn, confirmation)
-			if err != nil {
-				log.Error("error connecting", "err", err)
-				return
-			}
-			send, recv, qIS, err := finish(confirmation)
+			secure := conn.Server(remote, config)
+			handshakes.Add(1)
+			err := secure.Handshake()
+			handshakes.Add(-1)
 			if err != nil {
-				log.Error("error connecting", "err", err)
+				log.Error("error performing handshake", "err", err)
 				return
 			}
-			log.Info("handshake established", "pk", hex.EncodeToString(qIS.Bytes()))
-
-			r := aestream.NewReader(recv, conn)
-			w := aestream.NewWriter(send, conn)
+			log.Info("handshake established", "pk", hex.EncodeToString(secure.RemoteStaticKey().Bytes()))
 			defer func() {
 				log.Info("closing aestream")
-				err = w.Close()
-				if err != nil {
+				if err := secure.CloseWrite(); err != nil {
 					log.Error("error closing aestream", "err", err)
 				}
 			}()
@@ -107,14 +128,14 @@ func main() {
 
 			ctx, cancel := context.WithCancelCause(context.Background())
 			go func() {
-				if _, err := io.Copy(client, r); err != nil && !errors.Is(err, net.ErrClosed) {
+				if _, err := io.Copy(client, secure); err != nil && !errors.Is(err, net.ErrClosed) {
 					cancel(err)
 				} else {
 					cancel(nil)
 				}
 			}()
 			go func() {
-				if _, err := io.Copy(w, client); err != nil && !errors.Is(err, net.ErrClosed) {
+				if _, err := io.Copy(secure, client); err != nil && !errors.Is(err, net.ErrClosed) {
 					cancel(err)
 				} else {
 					cancel(nil)
@@ -0,0 +1,66 @@
+// Command newplex-vectors generates JSON known-answer test vectors for Newplex and the schemes built on it.
+//
+// By default, it writes one file per scheme to the vectors directory of the current working directory, which should be
+// the root of the module. With -verify, it checks the existing files instead.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/codahale/newplex/internal/vectors"
+)
+
+func main() {
+	log := slog.New(slog.Default().Handler())
+
+	dir := flag.String("dir", "vectors", "the directory containing the vector files")
+	verify := flag.Bool("verify", false, "verify the existing vector files instead of writing them")
+	flag.Parse()
+
+	if !*verify {
+		if err := os.MkdirAll(*dir, 0o755); err != nil {
+			panic(err)
+		}
+	}
+
+	failed := false
+	for _, name := range vectors.Names() {
+		path := filepath.Join(*dir, name+".json")
+
+		want, err := vectors.Generate(name)
+		if err != nil {
+			panic(err)
+		}
+
+		if *verify {
+			got, err := os.ReadFile(path)
+			if err != nil {
+				panic(err)
+			}
+
+			if err := vectors.Verify(name, got); err != nil {
+				log.Error("invalid vectors", "path", path, "err", err)
+				failed = true
+			} else if !bytes.Equal(got, want) {
+				log.Error("vectors out of date", "path", path)
+				failed = true
+			} else {
+				log.Info("verified vectors", "path", path)
+			}
+			continue
+		}
+
+		if err := os.WriteFile(path, want, 0o644); err != nil {
+			panic(err)
+		}
+		log.Info("wrote vectors", "path", path)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
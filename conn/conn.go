@@ -0,0 +1,809 @@
+// Package conn implements authenticated, encrypted connections over a net.Conn, in the style of crypto/tls.
+//
+// A connection begins with a handshake (see the handshake package) in which each message is prefixed with its length
+// as a 2-byte big endian integer. After the handshake, each direction of the connection is an aestream stream, so data
+// is confidential, authenticated, and forward secure, and truncation is detected. Closing the write side of a
+// connection (via CloseWrite or Close) ends the stream, which the other party reads as io.EOF.
+//
+// The handshake is performed on the first call to Read or Write, or explicitly via Handshake or HandshakeContext.
+//
+// If the server has a ticket key and the client has a session callback, the server issues a resumption ticket after
+// each handshake, which the client can use to resume the session with a cheaper handshake (see handshake.Resume). In
+// that case, the client precedes its first handshake message with a byte indicating whether it is resuming a session,
+// and the server follows its last handshake message with the ticket, sealed with its sending protocol.
+//
+// If the server has a cookie key and is under load, it replies to the client's first handshake message with an empty
+// message followed by a cookie bound to the client's address and that message (see handshake.CookieKey). The client
+// then sends the cookie followed by its first handshake message again, which the server only processes if the cookie
+// is valid for it.
+package conn
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/aestream"
+	"github.com/codahale/newplex/handshake"
+	"github.com/codahale/newplex/padding"
+	"github.com/gtank/ristretto255"
+)
+
+var (
+	// ErrOneWayPattern is returned when a connection is configured with a one-way handshake pattern.
+	ErrOneWayPattern = errors.New("newplex/conn: one-way patterns are not supported")
+
+	// ErrShutdown is returned when writing to a connection whose write side has been closed.
+	ErrShutdown = errors.New("newplex/conn: connection is shut down")
+)
+
+// Config configures a connection. Both parties must use the same pattern, domain separation string, pre-shared key,
+// and hybrid mode, and must both use session resumption or neither. A Config may be reused for many connections, but
+// must not be modified while in use.
+type Config struct {
+	// Pattern is the handshake pattern. The zero value is handshake.XX. One-way patterns are not supported.
+	Pattern handshake.Pattern
+
+	// Domain is the domain separation string for the handshake.
+	Domain string
+
+	// StaticKey is the party's static private key. It is required if the pattern includes a static key for the party.
+	StaticKey *ristretto255.Scalar
+
+	// RemoteStaticKey is the other party's static public key. It is required if the pattern requires the other
+	// party's static key to be known in advance, and ignored otherwise.
+	RemoteStaticKey *ristretto255.Element
+
+	// VerifyPeer, if not nil, is called with the other party's static public key as soon as it is received. If it
+	// returns an error, the handshake fails with that error. See handshake.Config.
+	VerifyPeer func(q *ristretto255.Element) error
+
+	// PreSharedKey is an optional pre-shared key. See handshake.Config.
+	PreSharedKey []byte
+
+	// Hybrid is true if the handshake should also perform an ML-KEM-768 key exchange. See handshake.Config.
+	Hybrid bool
+
+	// KeyUpdateInterval, if positive, is the number of bytes after which the connection automatically updates the key
+	// used to send data. See aestream.Writer.SetKeyUpdateInterval.
+	KeyUpdateInterval int64
+
+	// Padding, if not nil, is the policy with which the connection pads the data it sends, to hide the exact sizes of
+	// writes. Both parties must either use padding or not, but may use different policies. See
+	// aestream.Writer.SetPadding.
+	Padding padding.Policy
+
+	// FlushDelay, if positive, enables buffering of the data the connection sends, which coalesces small writes into
+	// full blocks. Buffered data is sent once a full block is buffered, when Flush or CloseWrite is called, or once
+	// FlushDelay has passed since it was written, which bounds the latency buffering adds. See
+	// aestream.Writer.SetBuffering.
+	FlushDelay time.Duration
+
+	// TicketKey, if not nil, enables session resumption on the server. The server issues a ticket sealed with the key
+	// after each handshake and accepts resumption handshakes using tickets it issued. See handshake.TicketKey.
+	TicketKey *handshake.TicketKey
+
+	// OnSession, if not nil, enables session resumption on the client, and is called with a new session each time the
+	// server issues a ticket. Tickets are read on the first call to Read after the handshake.
+	OnSession func(session *handshake.Session)
+
+	// Session, if not nil, is a session which the client resumes instead of performing a full handshake, which also
+	// enables session resumption on the client. Each session should be used only once. If the server rejects the
+	// session's ticket (e.g., because it has expired), it closes the connection, and the client should connect again
+	// without it.
+	Session *handshake.Session
+
+	// CookieKey, if not nil and UnderLoad is not nil, enables cookies on the server. When UnderLoad returns true, the
+	// server replies to the client's first handshake message with a cookie bound to the client's address and that
+	// message instead of processing it, and only processes the message once the client resends it with a valid
+	// cookie. Clients always handle cookie replies. See handshake.CookieKey.
+	//
+	// On TCP, the client's address has already been verified by the TCP handshake, so cookies do not protect the
+	// server from load with spoofed addresses. They only shed the server's handshake CPU, deferring its Diffie-Hellman
+	// operations until the client has completed an extra round trip.
+	CookieKey *handshake.CookieKey
+
+	// UnderLoad, if not nil, is called at the start of each handshake on a server with a cookie key, and returns true
+	// if the server is under load and should require a cookie. If nil, cookies are never required.
+	UnderLoad func() bool
+
+	// Rand is the source of randomness for ephemeral keys. If nil, crypto/rand.Reader is used.
+	Rand io.Reader
+}
+
+// A Conn is an authenticated, encrypted connection. It implements net.Conn.
+//
+// Read and Write may be called concurrently. After a read or write returns an error (including a timeout), the
+// corresponding side of the connection is unusable and the Conn should be closed.
+type Conn struct {
+	conn     net.Conn
+	config   *Config
+	isClient bool
+
+	handshakeMu   sync.Mutex
+	handshakeDone bool
+	handshakeErr  error
+	handshakeOK   atomic.Bool
+	remoteStatic  *ristretto255.Element
+
+	readMu  sync.Mutex
+	r       *aestream.Reader
+	readErr error
+	recv    *newplex.Protocol
+	hs      *handshake.State // retained until the ticket is read, if awaiting one
+
+	writeMu     sync.Mutex
+	w           *aestream.Writer
+	writeErr    error
+	writeClosed bool
+}
+
+// Client returns a new client-side (i.e., initiator) connection using conn as the underlying transport.
+func Client(conn net.Conn, config *Config) *Conn {
+	return &Conn{conn: conn, config: config, isClient: true}
+}
+
+// Server returns a new server-side (i.e., responder) connection using conn as the underlying transport.
+func Server(conn net.Conn, config *Config) *Conn {
+	return &Conn{conn: conn, config: config}
+}
+
+// Handshake runs the handshake, if it has not yet been run. Most uses of this package need not call Handshake
+// explicitly: the first Read or Write will call it automatically.
+func (c *Conn) Handshake() error {
+	return c.HandshakeContext(context.Background())
+}
+
+// HandshakeContext runs the handshake, if it has not yet been run. If the context is canceled or its deadline passes
+// before the handshake is complete, the handshake is aborted and the connection is closed.
+func (c *Conn) HandshakeContext(ctx context.Context) error {
+	if c.handshakeOK.Load() {
+		return nil
+	}
+
+	c.handshakeMu.Lock()
+	defer c.handshakeMu.Unlock()
+
+	if c.handshakeDone {
+		return c.handshakeErr
+	}
+
+	stop := c.closeOnDone(ctx)
+	err := c.handshake()
+	if cerr := stop(); cerr != nil {
+		err = cerr
+	}
+
+	c.handshakeDone, c.handshakeErr = true, err
+	if err != nil {
+		_ = c.conn.Close()
+		return err
+	}
+	c.handshakeOK.Store(true)
+	return nil
+}
+
+// closeOnDone closes the underlying connection if the given context is done before the returned function is called,
+// which returns the context's error if so.
+func (c *Conn) closeOnDone(ctx context.Context) (stop func() error) {
+	if ctx.Done() == nil {
+		return func() error { return nil }
+	}
+
+	done, result := make(chan struct{}), make(chan error, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = c.conn.Close()
+			result <- ctx.Err()
+		case <-done:
+			result <- nil
+		}
+	}()
+
+	return func() error {
+		close(done)
+		return <-result
+	}
+}
+
+func (c *Conn) handshake() error {
+	if c.config.Pattern.OneWay() {
+		return ErrOneWayPattern
+	}
+
+	r := c.config.Rand
+	if r == nil {
+		r = rand.Reader
+	}
+
+	config := &handshake.Config{
+		Pattern:         c.config.Pattern,
+		Domain:          c.config.Domain,
+		Initiator:       c.isClient,
+		StaticKey:       c.config.StaticKey,
+		RemoteStaticKey: c.config.RemoteStaticKey,
+		VerifyPeer:      c.config.VerifyPeer,
+		PreSharedKey:    c.config.PreSharedKey,
+		Hybrid:          c.config.Hybrid,
+		Session:         c.config.Session,
+		TicketKey:       c.config.TicketKey,
+		Rand:            r,
+	}
+
+	// If using session resumption, the client indicates whether it is resuming a session.
+	var prefix []byte
+	if c.resumption() {
+		var err error
+		if prefix, err = c.negotiateResumption(config); err != nil {
+			return err
+		}
+	}
+
+	// If under load, the server requires a cookie before processing the client's first message.
+	received, err := c.requireCookie()
+	if err != nil {
+		return err
+	}
+
+	hs, err := handshake.New(config)
+	if err != nil {
+		return err
+	}
+
+	// The client keeps its first message until it reads the server's reply, in case the reply is a cookie.
+	var first []byte
+	for i := 0; !hs.Complete(); i++ {
+		if hs.CanWrite() {
+			msg, err := c.writeHandshakeMessage(hs, prefix)
+			if err != nil {
+				return err
+			}
+			if i == 0 {
+				first = msg
+			}
+			prefix = nil
+		} else if received != nil {
+			// The server has already read the client's first message along with its cookie.
+			if _, err := hs.ReadMessage(nil, received); err != nil {
+				return err
+			}
+			received = nil
+		} else {
+			if err := c.readHandshakeMessage(hs, first); err != nil {
+				return err
+			}
+			first = nil
+		}
+	}
+
+	// If using session resumption, the server issues a ticket, which the client reads on its first read.
+	ticket, err := c.issueTicket(hs)
+	if err != nil {
+		return err
+	}
+
+	send, recv, err := hs.Split()
+	if err != nil {
+		return err
+	}
+
+	if ticket != nil {
+		if _, err := c.conn.Write(send.Seal("ticket", nil, ticket)); err != nil {
+			return err
+		}
+	} else if c.resumption() {
+		c.hs = hs
+	}
+
+	c.remoteStatic = hs.RemoteStaticKey()
+	c.recv = recv
+	c.r = aestream.NewReader(recv, c.conn)
+	c.w = aestream.NewWriter(send, c.conn)
+	c.r.SetTypedHeaders(true)
+	c.w.SetTypedHeaders(true)
+	c.w.SetKeyUpdateInterval(c.config.KeyUpdateInterval, 0)
+	if c.config.Padding != nil {
+		c.r.SetPadding(true)
+		c.w.SetPadding(c.config.Padding)
+	}
+	if c.config.FlushDelay > 0 {
+		c.w.SetBuffering(aestream.MaxBlockSize, c.config.FlushDelay)
+	}
+	return nil
+}
+
+// resumption returns true if the connection uses session resumption.
+func (c *Conn) resumption() bool {
+	if c.isClient {
+		return c.config.OnSession != nil || c.config.Session != nil
+	}
+	return c.config.TicketKey != nil
+}
+
+// negotiateResumption returns the byte with which the client indicates whether it is resuming a session, or reads it
+// as the server. In either case, if a session is being resumed, the handshake configuration is changed to use the
+// Resume pattern.
+func (c *Conn) negotiateResumption(config *handshake.Config) ([]byte, error) {
+	var mode [1]byte
+	if c.isClient {
+		if c.config.Session != nil {
+			mode[0] = 1
+		}
+	} else if _, err := io.ReadFull(c.conn, mode[:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	switch mode[0] {
+	case 0:
+	case 1:
+		config.Pattern = handshake.Resume
+	default:
+		return nil, handshake.ErrInvalidHandshake
+	}
+
+	if c.isClient {
+		return mode[:], nil
+	}
+	return nil, nil
+}
+
+// issueTicket returns a new ticket for the client, if the connection is a server using session resumption.
+func (c *Conn) issueTicket(hs *handshake.State) ([]byte, error) {
+	if c.isClient || !c.resumption() {
+		return nil, nil
+	}
+	return hs.IssueTicket(c.config.TicketKey)
+}
+
+// readTicket reads the sealed ticket sent by the server after the handshake and passes the resulting session to the
+// session callback. The caller must hold readMu.
+func (c *Conn) readTicket() error {
+	ticket := make([]byte, handshake.TicketSize+newplex.TagSize)
+	if _, err := io.ReadFull(c.conn, ticket); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	ticket, err := c.recv.Open("ticket", ticket[:0], ticket)
+	if err != nil {
+		return err
+	}
+
+	session, err := c.hs.Session(ticket)
+	if err != nil {
+		return err
+	}
+	c.hs = nil
+
+	if c.config.OnSession != nil {
+		c.config.OnSession(session)
+	}
+	return nil
+}
+
+// requireCookie, if the connection is a server with a cookie key which is under load, reads and discards the client's
+// first handshake message, replies with a cookie for the client's address and that message, and reads the cookie the
+// client echoes and its resent first message. It returns the resent message if the cookie is valid for it.
+func (c *Conn) requireCookie() ([]byte, error) {
+	if c.isClient || c.config.CookieKey == nil || c.config.UnderLoad == nil || !c.config.UnderLoad() {
+		return nil, nil
+	}
+
+	msg, err := c.readFrame()
+	if err != nil {
+		return nil, err
+	}
+
+	// A cookie reply is an empty frame followed by the cookie.
+	addr := []byte(c.conn.RemoteAddr().String())
+	reply := append(make([]byte, 2, 2+handshake.CookieSize), c.config.CookieKey.Cookie(addr, msg)...)
+	if _, err := c.conn.Write(reply); err != nil {
+		return nil, err
+	}
+
+	cookie := make([]byte, handshake.CookieSize)
+	if _, err := io.ReadFull(c.conn, cookie); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	if msg, err = c.readFrame(); err != nil {
+		return nil, err
+	}
+
+	// Check the cookie before the message is processed.
+	if err := c.config.CookieKey.Verify(addr, msg, cookie); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// writeHandshakeMessage writes the next handshake message with a length prefix, preceded by the given prefix, and
+// returns the length-prefixed message.
+func (c *Conn) writeHandshakeMessage(hs *handshake.State, prefix []byte) ([]byte, error) {
+	n := len(prefix)
+	msg := make([]byte, n+2, n+2+hs.Overhead())
+	copy(msg, prefix)
+	msg, err := hs.WriteMessage(msg, nil)
+	if err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint16(msg[n:], uint16(len(msg)-n-2))
+	if _, err := c.conn.Write(msg); err != nil {
+		return nil, err
+	}
+	return msg[n:], nil
+}
+
+// readHandshakeMessage reads the next length-prefixed handshake message. If first is not nil and the server replies
+// with a cookie instead, the client echoes the cookie, resends first, and reads the next message.
+func (c *Conn) readHandshakeMessage(hs *handshake.State, first []byte) error {
+	msg, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+
+	if len(msg) == 0 && first != nil {
+		cookie := make([]byte, handshake.CookieSize, handshake.CookieSize+len(first))
+		if _, err := io.ReadFull(c.conn, cookie); err != nil {
+			return unexpectedEOF(err)
+		}
+
+		if _, err := c.conn.Write(append(cookie, first...)); err != nil {
+			return err
+		}
+
+		if msg, err = c.readFrame(); err != nil {
+			return err
+		}
+	}
+
+	_, err = hs.ReadMessage(nil, msg)
+	return err
+}
+
+// readFrame reads a length-prefixed handshake message.
+func (c *Conn) readFrame() ([]byte, error) {
+	var n [2]byte
+	if _, err := io.ReadFull(c.conn, n[:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	msg := make([]byte, binary.BigEndian.Uint16(n[:]))
+	if _, err := io.ReadFull(c.conn, msg); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return msg, nil
+}
+
+// Read reads data from the connection, running the handshake if necessary. It returns io.EOF once the other party has
+// closed its write side of the connection, or newplex.ErrInvalidCiphertext if the data has been modified or truncated.
+func (c *Conn) Read(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	if c.readErr != nil {
+		return 0, c.readErr
+	}
+
+	if c.hs != nil {
+		if err := c.readTicket(); err != nil {
+			c.readErr = err
+			return 0, err
+		}
+	}
+
+	n, err := c.r.Read(b)
+	if err != nil {
+		c.readErr = err
+	}
+	return n, err
+}
+
+// WriteTo writes data from the connection to w until the other party closes its write side of the connection or an
+// error occurs, running the handshake if necessary. It implements io.WriterTo, writing data directly from the
+// connection's block buffers.
+func (c *Conn) WriteTo(w io.Writer) (int64, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	if c.readErr != nil {
+		if errors.Is(c.readErr, io.EOF) {
+			return 0, nil
+		}
+		return 0, c.readErr
+	}
+
+	if c.hs != nil {
+		if err := c.readTicket(); err != nil {
+			c.readErr = err
+			return 0, err
+		}
+	}
+
+	n, err := c.r.WriteTo(w)
+	if err != nil {
+		c.readErr = err
+	}
+	return n, err
+}
+
+// Write writes data to the connection, running the handshake if necessary.
+//
+// Returns ErrShutdown if the write side of the connection has been closed.
+func (c *Conn) Write(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.writeErr != nil {
+		return 0, c.writeErr
+	}
+
+	if c.writeClosed {
+		return 0, ErrShutdown
+	}
+
+	n, err := c.w.Write(b)
+	if err != nil {
+		c.writeErr = err
+	}
+	return n, err
+}
+
+// ReadFrom reads data from r until EOF or an error and writes it to the connection, running the handshake if necessary.
+// It implements io.ReaderFrom, reading directly into the connection's block buffers. Unlike Write, ReadFrom does not
+// hold the connection's write lock while waiting for data from r, so other writes and CloseWrite may be called
+// concurrently.
+//
+// Returns ErrShutdown if the write side of the connection has been closed.
+func (c *Conn) ReadFrom(r io.Reader) (int64, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+
+	c.writeMu.Lock()
+	err := c.writeErr
+	if err == nil && c.writeClosed {
+		err = ErrShutdown
+	}
+	c.writeMu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := c.w.ReadFrom(r)
+	if errors.Is(err, aestream.ErrClosed) {
+		err = ErrShutdown
+	}
+	return n, err
+}
+
+// Flush sends any data buffered by a connection with a FlushDelay, running the handshake if necessary.
+//
+// Returns ErrShutdown if the write side of the connection has been closed.
+func (c *Conn) Flush() error {
+	if err := c.Handshake(); err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+
+	if c.writeClosed {
+		return ErrShutdown
+	}
+
+	if err := c.w.Flush(); err != nil {
+		c.writeErr = err
+		return err
+	}
+	return nil
+}
+
+// UpdateKey updates the key used to send data, running the handshake if necessary. The other party updates its
+// receiving key when it reads the key update.
+//
+// Returns ErrShutdown if the write side of the connection has been closed.
+func (c *Conn) UpdateKey() error {
+	if err := c.Handshake(); err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+
+	if c.writeClosed {
+		return ErrShutdown
+	}
+
+	if err := c.w.UpdateKey(); err != nil {
+		c.writeErr = err
+		return err
+	}
+	return nil
+}
+
+// CloseWrite ends the stream of data written to the connection, which the other party will read as io.EOF, and shuts
+// down the write side of the underlying connection if it supports half-closes (e.g., *net.TCPConn). Further writes
+// return ErrShutdown. It does nothing if the handshake has not been completed.
+func (c *Conn) CloseWrite() error {
+	if !c.handshakeOK.Load() {
+		return nil
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.closeStream(); err != nil {
+		return err
+	}
+
+	if cw, ok := c.conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// Close ends the stream of data written to the connection, if it has not already been ended and no write is in
+// progress, and closes the underlying connection.
+func (c *Conn) Close() error {
+	var err error
+	if c.handshakeOK.Load() && c.writeMu.TryLock() {
+		// Don't let a peer which isn't reading block the close indefinitely.
+		_ = c.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+		err = c.closeStream()
+		c.writeMu.Unlock()
+	}
+
+	if cerr := c.conn.Close(); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+// closeStream ends the written stream, if it has not already been ended. The caller must hold writeMu.
+func (c *Conn) closeStream() error {
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+
+	if c.writeClosed {
+		return nil
+	}
+	c.writeClosed = true
+
+	if err := c.w.Close(); err != nil {
+		c.writeErr = err
+		return err
+	}
+	return nil
+}
+
+// RemoteStaticKey returns the other party's static public key, which has been authenticated by the handshake. It
+// returns nil if the handshake has not been completed or the pattern does not authenticate the other party.
+func (c *Conn) RemoteStaticKey() *ristretto255.Element {
+	if !c.handshakeOK.Load() {
+		return nil
+	}
+	return c.remoteStatic
+}
+
+// NetConn returns the underlying connection. Reading from or writing to it directly will corrupt the connection.
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines of the underlying connection. See net.Conn.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the underlying connection. See net.Conn.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying connection. See net.Conn.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// Listen creates a listener which accepts connections on the given network address and wraps them with Server.
+func Listen(network, address string, config *Config) (net.Listener, error) {
+	l, err := new(net.ListenConfig).Listen(context.Background(), network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewListener(l, config), nil
+}
+
+// NewListener creates a listener which accepts connections from the given listener and wraps them with Server.
+func NewListener(inner net.Listener, config *Config) net.Listener {
+	return &listener{Listener: inner, config: config}
+}
+
+type listener struct {
+	net.Listener
+	config *Config
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return Server(c, l.config), nil
+}
+
+// Dial connects to the given network address and runs the handshake as a client.
+func Dial(network, address string, config *Config) (*Conn, error) {
+	return DialContext(context.Background(), network, address, config)
+}
+
+// DialContext connects to the given network address using the given context and runs the handshake as a client. The
+// context governs both the connection and the handshake.
+func DialContext(ctx context.Context, network, address string, config *Config) (*Conn, error) {
+	nc, err := new(net.Dialer).DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Client(nc, config)
+	if err := c.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// unexpectedEOF converts io.EOF into io.ErrUnexpectedEOF, since the handshake is incomplete.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+const closeTimeout = 5 * time.Second
+
+var (
+	_ net.Conn      = (*Conn)(nil)
+	_ io.ReaderFrom = (*Conn)(nil)
+	_ io.WriterTo   = (*Conn)(nil)
+	_ net.Listener  = (*listener)(nil)
+)
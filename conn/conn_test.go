@@ -0,0 +1,528 @@
+package conn_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/conn"
+	"github.com/codahale/newplex/handshake"
+	"github.com/codahale/newplex/internal/testdata"
+	"github.com/codahale/newplex/padding"
+)
+
+// pair returns a connected client and server, with the server accepted from a listener.
+func pair(t *testing.T, clientConfig, serverConfig *conn.Config) (client, server net.Conn) {
+	t.Helper()
+
+	l, err := conn.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		accepted <- c
+	}()
+
+	nc, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = conn.Client(nc, clientConfig)
+	server = <-accepted
+	if server == nil {
+		t.Fatal("failed to accept connection")
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return client, server
+}
+
+// configs returns client and server configurations for the given pattern.
+func configs(drbg *testdata.DRBG, pattern handshake.Pattern) (client, server *conn.Config) {
+	dC, qC := drbg.KeyPair()
+	dS, qS := drbg.KeyPair()
+	client = &conn.Config{
+		Pattern: pattern, Domain: "example", StaticKey: dC, RemoteStaticKey: qS, Rand: drbg.Reader(),
+	}
+	server = &conn.Config{
+		Pattern: pattern, Domain: "example", StaticKey: dS, RemoteStaticKey: qC, Rand: drbg.Reader(),
+	}
+	return client, server
+}
+
+// echo copies everything the connection reads back to it, then closes its write side.
+func echo(c net.Conn) error {
+	if _, err := io.Copy(c, c); err != nil {
+		return err
+	}
+	return c.(*conn.Conn).CloseWrite()
+}
+
+func TestConn(t *testing.T) {
+	for _, pattern := range []handshake.Pattern{handshake.XX, handshake.IK, handshake.XK, handshake.NK, handshake.KK} {
+		for _, mode := range []string{"default", "psk", "hybrid", "key update", "padding", "buffering"} {
+			t.Run(fmt.Sprintf("%s/%s", pattern, mode), func(t *testing.T) {
+				drbg := testdata.New("newplex conn " + pattern.String() + " " + mode)
+				clientConfig, serverConfig := configs(drbg, pattern)
+				switch mode {
+				case "psk":
+					psk := drbg.Data(handshake.PreSharedKeySize)
+					clientConfig.PreSharedKey, serverConfig.PreSharedKey = psk, psk
+				case "hybrid":
+					clientConfig.Hybrid, serverConfig.Hybrid = true, true
+				case "key update":
+					clientConfig.KeyUpdateInterval, serverConfig.KeyUpdateInterval = 10_000, 10_000
+				case "padding":
+					clientConfig.Padding, serverConfig.Padding = padding.Padme, padding.PowerOfTwo
+				case "buffering":
+					clientConfig.FlushDelay, serverConfig.FlushDelay = time.Millisecond, time.Millisecond
+				}
+
+				client, server := pair(t, clientConfig, serverConfig)
+				errs := make(chan error, 1)
+				go func() { errs <- echo(server) }()
+
+				message := drbg.Data(200_000)
+				go func() {
+					_, _ = client.Write(message)
+					_ = client.(*conn.Conn).CloseWrite()
+				}()
+
+				got, err := io.ReadAll(client)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(got, message) {
+					t.Errorf("echoed %d bytes, want %d", len(got), len(message))
+				}
+
+				if err := <-errs; err != nil {
+					t.Fatal(err)
+				}
+
+				if got, want := client.(*conn.Conn).RemoteStaticKey(), clientConfig.RemoteStaticKey; got == nil ||
+					got.Equal(want) != 1 {
+					t.Errorf("client.RemoteStaticKey() = %v, want = %v", got, want)
+				}
+
+				remote := server.(*conn.Conn).RemoteStaticKey()
+				if pattern == handshake.NK {
+					if remote != nil {
+						t.Errorf("server.RemoteStaticKey() = %v, want = nil", remote)
+					}
+				} else if remote == nil || remote.Equal(serverConfig.RemoteStaticKey) != 1 {
+					t.Errorf("server.RemoteStaticKey() = %v, want = %v", remote, serverConfig.RemoteStaticKey)
+				}
+			})
+		}
+	}
+}
+
+func TestConn_UpdateKey(t *testing.T) {
+	drbg := testdata.New("newplex conn update key")
+	clientConfig, serverConfig := configs(drbg, handshake.XX)
+	client, server := pair(t, clientConfig, serverConfig)
+
+	go func() {
+		_, _ = io.WriteString(client, "before")
+		_ = client.(*conn.Conn).UpdateKey()
+		_, _ = io.WriteString(client, " after")
+		_ = client.(*conn.Conn).CloseWrite()
+	}()
+
+	got, err := io.ReadAll(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(got), "before after"; got != want {
+		t.Errorf("ReadAll() = %q, want = %q", got, want)
+	}
+}
+
+func TestConn_Resume(t *testing.T) {
+	drbg := testdata.New("newplex conn resume")
+	clientConfig, serverConfig := configs(drbg, handshake.XX)
+	serverConfig.TicketKey = handshake.NewTicketKey(drbg.Data(handshake.TicketKeySize), time.Hour)
+	sessions := make(chan *handshake.Session, 1)
+	clientConfig.OnSession = func(session *handshake.Session) { sessions <- session }
+
+	// connect connects a client with the given configuration, echoes a message, and returns the client's new session.
+	connect := func(t *testing.T, clientConfig *conn.Config) *handshake.Session {
+		t.Helper()
+
+		client, server := pair(t, clientConfig, serverConfig)
+		go func() { _ = echo(server) }()
+		go func() {
+			_, _ = io.WriteString(client, "hello")
+			_ = client.(*conn.Conn).CloseWrite()
+		}()
+
+		got, err := io.ReadAll(client)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := string(got), "hello"; got != want {
+			t.Errorf("ReadAll() = %q, want = %q", got, want)
+		}
+
+		if got, want := client.(*conn.Conn).RemoteStaticKey(), clientConfig.RemoteStaticKey; got == nil ||
+			got.Equal(want) != 1 {
+			t.Errorf("client.RemoteStaticKey() = %v, want = %v", got, want)
+		}
+
+		if got, want := server.(*conn.Conn).RemoteStaticKey(), serverConfig.RemoteStaticKey; got == nil ||
+			got.Equal(want) != 1 {
+			t.Errorf("server.RemoteStaticKey() = %v, want = %v", got, want)
+		}
+
+		return <-sessions
+	}
+
+	session := connect(t, clientConfig)
+	for range 2 {
+		resumeConfig := *clientConfig
+		resumeConfig.Session = session
+		session = connect(t, &resumeConfig)
+	}
+
+	t.Run("invalid ticket", func(t *testing.T) {
+		resumeConfig := *clientConfig
+		resumeConfig.Session = &handshake.Session{
+			Ticket: drbg.Data(handshake.TicketSize),
+			Secret: drbg.Data(handshake.ResumptionSecretSize),
+		}
+		client, server := pair(t, &resumeConfig, serverConfig)
+
+		go func() { _ = client.(*conn.Conn).Handshake() }()
+		if err := server.(*conn.Conn).Handshake(); !errors.Is(err, handshake.ErrInvalidTicket) {
+			t.Errorf("Handshake() err = %v, want = %v", err, handshake.ErrInvalidTicket)
+		}
+	})
+}
+
+func TestConn_Cookie(t *testing.T) {
+	drbg := testdata.New("newplex conn cookie")
+
+	for _, mode := range []string{"under load", "not under load", "no load callback", "resumption"} {
+		t.Run(mode, func(t *testing.T) {
+			clientConfig, serverConfig := configs(drbg, handshake.XX)
+			serverConfig.CookieKey = handshake.NewCookieKey(drbg.Data(handshake.CookieKeySize), time.Minute)
+			serverConfig.UnderLoad = func() bool { return true }
+			switch mode {
+			case "not under load":
+				serverConfig.UnderLoad = func() bool { return false }
+			case "no load callback":
+				serverConfig.UnderLoad = nil
+			case "resumption":
+				serverConfig.TicketKey = handshake.NewTicketKey(drbg.Data(handshake.TicketKeySize), time.Hour)
+				clientConfig.OnSession = func(*handshake.Session) {}
+			}
+
+			client, server := pair(t, clientConfig, serverConfig)
+			go func() { _ = echo(server) }()
+			go func() {
+				_, _ = io.WriteString(client, "hello")
+				_ = client.(*conn.Conn).CloseWrite()
+			}()
+
+			got, err := io.ReadAll(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := string(got), "hello"; got != want {
+				t.Errorf("ReadAll() = %q, want = %q", got, want)
+			}
+		})
+	}
+
+	t.Run("invalid cookie", func(t *testing.T) {
+		_, serverConfig := configs(drbg, handshake.XX)
+		serverConfig.CookieKey = handshake.NewCookieKey(drbg.Data(handshake.CookieKeySize), time.Minute)
+		serverConfig.UnderLoad = func() bool { return true }
+		a, b := net.Pipe()
+		defer func() { _ = a.Close() }()
+
+		// A client which sends a first message, reads the cookie reply, and resends it with the wrong cookie.
+		go func() {
+			first := append([]byte{0, 32}, drbg.Data(32)...)
+			_, _ = a.Write(first)
+			_, _ = io.ReadFull(a, make([]byte, 2+handshake.CookieSize))
+			_, _ = a.Write(append(drbg.Data(handshake.CookieSize), first...))
+		}()
+
+		if err := conn.Server(b, serverConfig).Handshake(); !errors.Is(err, handshake.ErrInvalidCookie) {
+			t.Errorf("Handshake() err = %v, want = %v", err, handshake.ErrInvalidCookie)
+		}
+	})
+
+	t.Run("cookie for another message", func(t *testing.T) {
+		_, serverConfig := configs(drbg, handshake.XX)
+		serverConfig.CookieKey = handshake.NewCookieKey(drbg.Data(handshake.CookieKeySize), time.Minute)
+		serverConfig.UnderLoad = func() bool { return true }
+		a, b := net.Pipe()
+		defer func() { _ = a.Close() }()
+
+		// A client which sends a first message, reads the cookie reply, and echoes the cookie with a different message.
+		go func() {
+			_, _ = a.Write(append([]byte{0, 32}, drbg.Data(32)...))
+			reply := make([]byte, 2+handshake.CookieSize)
+			_, _ = io.ReadFull(a, reply)
+			_, _ = a.Write(append(append(reply[2:], 0, 32), drbg.Data(32)...))
+		}()
+
+		if err := conn.Server(b, serverConfig).Handshake(); !errors.Is(err, handshake.ErrInvalidCookie) {
+			t.Errorf("Handshake() err = %v, want = %v", err, handshake.ErrInvalidCookie)
+		}
+	})
+}
+
+func TestConn_Write(t *testing.T) {
+	drbg := testdata.New("newplex conn write")
+	clientConfig, serverConfig := configs(drbg, handshake.XX)
+	client, server := pair(t, clientConfig, serverConfig)
+
+	go func() { _, _ = io.Copy(io.Discard, server) }()
+
+	if err := client.(*conn.Conn).Handshake(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.(*conn.Conn).CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Write([]byte("more")); !errors.Is(err, conn.ErrShutdown) {
+		t.Errorf("Write() err = %v, want = %v", err, conn.ErrShutdown)
+	}
+
+	if err := client.(*conn.Conn).UpdateKey(); !errors.Is(err, conn.ErrShutdown) {
+		t.Errorf("UpdateKey() err = %v, want = %v", err, conn.ErrShutdown)
+	}
+
+	if err := client.(*conn.Conn).Flush(); !errors.Is(err, conn.ErrShutdown) {
+		t.Errorf("Flush() err = %v, want = %v", err, conn.ErrShutdown)
+	}
+
+	if _, err := client.(*conn.Conn).ReadFrom(bytes.NewReader([]byte("more"))); !errors.Is(err, conn.ErrShutdown) {
+		t.Errorf("ReadFrom() err = %v, want = %v", err, conn.ErrShutdown)
+	}
+}
+
+func TestConn_Flush(t *testing.T) {
+	drbg := testdata.New("newplex conn flush")
+	clientConfig, serverConfig := configs(drbg, handshake.XX)
+	clientConfig.FlushDelay = time.Hour
+	client, server := pair(t, clientConfig, serverConfig)
+
+	// Buffered data is only sent once it's flushed.
+	read := make(chan []byte, 1)
+	go func() {
+		b := make([]byte, 10)
+		n, _ := server.Read(b)
+		read <- b[:n]
+	}()
+
+	if _, err := client.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case b := <-read:
+		t.Fatalf("Read() = %q before Flush()", b)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := client.(*conn.Conn).Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(<-read), "hello"; got != want {
+		t.Errorf("Read() = %q, want = %q", got, want)
+	}
+}
+
+func TestConn_Read(t *testing.T) {
+	drbg := testdata.New("newplex conn read")
+
+	t.Run("truncated stream", func(t *testing.T) {
+		clientConfig, serverConfig := configs(drbg, handshake.XX)
+		client, server := pair(t, clientConfig, serverConfig)
+
+		go func() {
+			_, _ = server.Write([]byte("partial"))
+			_ = server.(*conn.Conn).NetConn().Close()
+		}()
+
+		if _, err := io.ReadAll(client); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("ReadAll() err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+		}
+	})
+
+	t.Run("modified stream", func(t *testing.T) {
+		clientConfig, serverConfig := configs(drbg, handshake.XX)
+		a, b := net.Pipe()
+		f := &flipper{Conn: a}
+		client, server := conn.Client(f, clientConfig), conn.Server(b, serverConfig)
+		defer func() {
+			_ = a.Close()
+			_ = b.Close()
+		}()
+
+		errs := make(chan error, 1)
+		go func() { errs <- client.Handshake() }()
+		if err := server.Handshake(); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+
+		f.on = true
+		go func() {
+			_, _ = client.Write([]byte("message"))
+		}()
+
+		if _, err := server.Read(make([]byte, 10)); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("Read() err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+		}
+	})
+}
+
+func TestConn_Handshake(t *testing.T) {
+	drbg := testdata.New("newplex conn handshake")
+
+	t.Run("wrong remote static key", func(t *testing.T) {
+		clientConfig, serverConfig := configs(drbg, handshake.IK)
+		_, clientConfig.RemoteStaticKey = drbg.KeyPair()
+		client, server := pair(t, clientConfig, serverConfig)
+
+		go func() { _ = client.(*conn.Conn).Handshake() }()
+		if err := server.(*conn.Conn).Handshake(); !errors.Is(err, handshake.ErrInvalidHandshake) {
+			t.Errorf("Handshake() err = %v, want = %v", err, handshake.ErrInvalidHandshake)
+		}
+
+		// The error is sticky.
+		if _, err := server.Read(make([]byte, 10)); !errors.Is(err, handshake.ErrInvalidHandshake) {
+			t.Errorf("Read() err = %v, want = %v", err, handshake.ErrInvalidHandshake)
+		}
+	})
+
+	t.Run("rejected peer", func(t *testing.T) {
+		clientConfig, serverConfig := configs(drbg, handshake.XX)
+		serverConfig.VerifyPeer = handshake.NewAllowlist().Verify
+		client, server := pair(t, clientConfig, serverConfig)
+
+		go func() { _ = client.(*conn.Conn).Handshake() }()
+		if err := server.(*conn.Conn).Handshake(); !errors.Is(err, handshake.ErrUnknownPeer) {
+			t.Errorf("Handshake() err = %v, want = %v", err, handshake.ErrUnknownPeer)
+		}
+	})
+
+	t.Run("one-way pattern", func(t *testing.T) {
+		clientConfig, _ := configs(drbg, handshake.N)
+		a, b := net.Pipe()
+		defer func() { _ = b.Close() }()
+
+		if err := conn.Client(a, clientConfig).Handshake(); !errors.Is(err, conn.ErrOneWayPattern) {
+			t.Errorf("Handshake() err = %v, want = %v", err, conn.ErrOneWayPattern)
+		}
+	})
+
+	t.Run("context deadline", func(t *testing.T) {
+		// A server which never responds.
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = l.Close() }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		clientConfig, _ := configs(drbg, handshake.XX)
+		if _, err := conn.DialContext(ctx, "tcp", l.Addr().String(), clientConfig); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("DialContext() err = %v, want = %v", err, context.DeadlineExceeded)
+		}
+	})
+}
+
+func ExampleDial() {
+	drbg := testdata.New("newplex conn example")
+	dS, qS := drbg.KeyPair()
+
+	// Listen for connections with the server's static key.
+	l, err := conn.Listen("tcp", "127.0.0.1:0", &conn.Config{
+		Pattern:   handshake.NK,
+		Domain:    "example",
+		StaticKey: dS,
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer func() { _ = l.Close() }()
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			panic(err)
+		}
+		defer func() { _ = c.Close() }()
+
+		if _, err := io.WriteString(c, "hello, client"); err != nil {
+			panic(err)
+		}
+	}()
+
+	// Connect to the server, which must have the given static key.
+	c, err := conn.Dial("tcp", l.Addr().String(), &conn.Config{
+		Pattern:         handshake.NK,
+		Domain:          "example",
+		RemoteStaticKey: qS,
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	msg, err := io.ReadAll(c)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(msg))
+	// Output:
+	// hello, client
+}
+
+// flipper is a net.Conn which flips a bit in the last byte of everything it writes once on is set.
+type flipper struct {
+	net.Conn
+	on bool
+}
+
+func (f *flipper) Write(b []byte) (int, error) {
+	if f.on && len(b) > 0 {
+		b = bytes.Clone(b)
+		b[len(b)-1] ^= 1
+	}
+	return f.Conn.Write(b)
+}
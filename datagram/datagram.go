@@ -0,0 +1,229 @@
+// Package datagram implements authenticated encryption for unreliable, unordered datagrams (e.g., UDP packets).
+//
+// Unlike aestream and oae2, in which each block is chained to the one before it, each datagram is sealed
+// independently. A datagram consists of an 8-byte big endian counter, followed by the payload sealed with a clone of
+// the sender's protocol into which the counter has been mixed. The receiver opens each datagram with a clone of its
+// own protocol, so datagrams may be lost or reordered without affecting others, and checks the counter against a
+// sliding window of WindowSize counters to reject replayed datagrams and datagrams which are too old.
+//
+// The sender's and receiver's protocols must be keyed identically (e.g., the send and receive protocols from a
+// handshake.State), and each protocol must only be used by a single Sender or Receiver. Datagrams are not forward
+// secret within a session: the protocols are never ratcheted, so compromise of either reveals every datagram.
+package datagram
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/codahale/newplex"
+)
+
+const (
+	// Overhead is the number of bytes a sealed datagram adds to its payload.
+	Overhead = counterSize + newplex.TagSize
+
+	// WindowSize is the size of the replay window. A datagram whose counter is WindowSize or more less than the
+	// highest counter received is rejected.
+	WindowSize = 2048
+)
+
+var (
+	// ErrReplay is returned when a datagram has already been received or is too old to be checked.
+	ErrReplay = errors.New("newplex/datagram: replayed datagram")
+
+	// ErrExhausted is returned when a Sender has sealed the maximum number of datagrams.
+	ErrExhausted = errors.New("newplex/datagram: counter exhausted")
+)
+
+// A Sender seals datagrams. It is safe for concurrent use.
+type Sender struct {
+	p    newplex.Protocol
+	next atomic.Uint64
+}
+
+// NewSender returns a Sender which seals datagrams using a clone of the given protocol, which does not inherit the
+// protocol's tracer. The protocol MUST NOT be used afterward.
+func NewSender(p *newplex.Protocol) *Sender {
+	return &Sender{p: *p.Clone()}
+}
+
+// Seal appends the sealed datagram containing the given payload to dst and returns the resulting slice.
+//
+// Returns ErrExhausted if the Sender has sealed 2**64-1 datagrams.
+func (s *Sender) Seal(dst, payload []byte) ([]byte, error) {
+	var counter uint64
+	for {
+		counter = s.next.Load()
+		if counter == math.MaxUint64 {
+			return nil, ErrExhausted
+		}
+
+		if s.next.CompareAndSwap(counter, counter+1) {
+			break
+		}
+	}
+
+	var header [counterSize]byte
+	binary.BigEndian.PutUint64(header[:], counter)
+
+	// Seal the payload with a clone of the protocol which has the counter mixed in.
+	p := s.p
+	p.Mix("counter", header[:])
+	return p.Seal("datagram", append(dst, header[:]...), payload), nil
+}
+
+// A Receiver opens datagrams and rejects replays. It is safe for concurrent use.
+type Receiver struct {
+	p newplex.Protocol
+
+	mu     sync.Mutex
+	next   uint64                  // one more than the highest counter received, or zero if none have been
+	window [WindowSize / 64]uint64 // a bitmap of received counters, indexed by counter modulo WindowSize
+}
+
+// NewReceiver returns a Receiver which opens datagrams using a clone of the given protocol, which does not inherit the
+// protocol's tracer. The protocol MUST NOT be used afterward.
+func NewReceiver(p *newplex.Protocol) *Receiver {
+	return &Receiver{p: *p.Clone()}
+}
+
+// Open opens the given sealed datagram, appends its payload to dst, and returns the resulting slice.
+//
+// Returns newplex.ErrInvalidCiphertext if the datagram is malformed or has been modified, or ErrReplay if the datagram
+// has already been received or is too old.
+func (r *Receiver) Open(dst, datagram []byte) ([]byte, error) {
+	if len(datagram) < Overhead {
+		return nil, newplex.ErrInvalidCiphertext
+	}
+
+	// Check for replays before opening the datagram, to avoid needless work.
+	counter := binary.BigEndian.Uint64(datagram)
+	r.mu.Lock()
+	ok := r.check(counter)
+	r.mu.Unlock()
+	if !ok {
+		return nil, ErrReplay
+	}
+
+	// Open the datagram with a clone of the protocol which has the counter mixed in.
+	p := r.p
+	p.Mix("counter", datagram[:counterSize])
+	payload, err := p.Open("datagram", dst, datagram[counterSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	// Only authentic datagrams update the window. Check it again, in case the same datagram was opened concurrently.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.check(counter) {
+		return nil, ErrReplay
+	}
+	r.update(counter)
+
+	return payload, nil
+}
+
+// check returns true if the given counter is new and within the window. The caller must hold mu.
+func (r *Receiver) check(counter uint64) bool {
+	switch {
+	case counter >= r.next:
+		return true
+	case r.next-counter > WindowSize:
+		return false
+	default:
+		return r.window[(counter%WindowSize)/64]&(1<<(counter%64)) == 0
+	}
+}
+
+// update marks the given counter as received, advancing the window if necessary. The caller must hold mu.
+func (r *Receiver) update(counter uint64) {
+	if counter >= r.next {
+		// Clear the bits for the counters between the previous highest counter and this one, which are no longer in
+		// the window.
+		if counter-r.next >= WindowSize {
+			r.window = [WindowSize / 64]uint64{}
+		} else {
+			for c := r.next; c < counter; c++ {
+				r.window[(c%WindowSize)/64] &^= 1 << (c % 64)
+			}
+		}
+		r.next = counter + 1
+	}
+	r.window[(counter%WindowSize)/64] |= 1 << (counter % 64)
+}
+
+// A Conn sends and receives sealed datagrams over a packet-oriented net.Conn, such as a connected *net.UDPConn.
+type Conn struct {
+	net.Conn
+
+	s *Sender
+	r *Receiver
+
+	readMu sync.Mutex
+	buf    []byte
+}
+
+// NewConn returns a Conn which sends datagrams sealed with the send protocol and opens received datagrams with the
+// recv protocol (e.g., the protocols returned by handshake.State.Split) over the given connection. The protocols MUST
+// NOT be used afterward.
+func NewConn(conn net.Conn, send, recv *newplex.Protocol) *Conn {
+	return &Conn{
+		Conn: conn,
+		s:    NewSender(send),
+		r:    NewReceiver(recv),
+		buf:  make([]byte, maxDatagramSize),
+	}
+}
+
+// Read reads the next authentic, non-replayed datagram from the connection and copies its payload into b. Datagrams
+// which cannot be opened or are replays are silently discarded.
+//
+// Returns io.ErrShortBuffer if b is too small to hold the payload, in which case the datagram is discarded.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	for {
+		n, err := c.Conn.Read(c.buf)
+		if err != nil {
+			return 0, err
+		}
+
+		// Open the datagram in place.
+		payload, err := c.r.Open(c.buf[counterSize:counterSize], c.buf[:n])
+		if err != nil {
+			continue
+		}
+
+		if len(payload) > len(b) {
+			return 0, io.ErrShortBuffer
+		}
+		return copy(b, payload), nil
+	}
+}
+
+// Write seals b as a single datagram and writes it to the connection.
+func (c *Conn) Write(b []byte) (int, error) {
+	datagram, err := c.s.Seal(make([]byte, 0, Overhead+len(b)), b)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := c.Conn.Write(datagram); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+const (
+	counterSize     = 8
+	maxDatagramSize = 65535
+)
+
+var _ net.Conn = (*Conn)(nil)
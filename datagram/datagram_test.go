@@ -0,0 +1,396 @@
+package datagram_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/datagram"
+	"github.com/codahale/newplex/internal/testdata"
+)
+
+// newPair returns a Sender and Receiver with identically keyed protocols.
+func newPair() (*datagram.Sender, *datagram.Receiver) {
+	p := newplex.NewProtocol("example")
+	p.Mix("key", []byte("it's a key"))
+	return datagram.NewSender(p.Clone()), datagram.NewReceiver(p)
+}
+
+// seal seals the given number of datagrams, each containing its index.
+func seal(t *testing.T, s *datagram.Sender, n int) [][]byte {
+	t.Helper()
+
+	datagrams := make([][]byte, n)
+	for i := range datagrams {
+		d, err := s.Seal(nil, fmt.Appendf(nil, "datagram %d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		datagrams[i] = d
+	}
+	return datagrams
+}
+
+// open opens the given datagram and checks that it contains the given index.
+func open(t *testing.T, r *datagram.Receiver, d []byte, i int) {
+	t.Helper()
+
+	got, err := r.Open(nil, d)
+	if err != nil {
+		t.Fatalf("Open(datagram %d) err = %v", i, err)
+	}
+
+	if want := fmt.Appendf(nil, "datagram %d", i); !bytes.Equal(got, want) {
+		t.Errorf("Open(datagram %d) = %q, want = %q", i, got, want)
+	}
+}
+
+func TestSender_Seal(t *testing.T) {
+	s, _ := newPair()
+
+	t.Run("overhead", func(t *testing.T) {
+		d, err := s.Seal(nil, []byte("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := len(d), len("hello")+datagram.Overhead; got != want {
+			t.Errorf("len(Seal()) = %d, want = %d", got, want)
+		}
+	})
+
+	t.Run("append", func(t *testing.T) {
+		d, err := s.Seal([]byte("prefix"), []byte("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.HasPrefix(d, []byte("prefix")) {
+			t.Errorf("Seal() = %x, want prefix", d)
+		}
+	})
+
+	t.Run("distinct datagrams", func(t *testing.T) {
+		a, _ := s.Seal(nil, []byte("hello"))
+		b, _ := s.Seal(nil, []byte("hello"))
+
+		if bytes.Equal(a, b) {
+			t.Error("Seal() returned identical datagrams for identical payloads")
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		s, r := newPair()
+		var wg sync.WaitGroup
+		datagrams := make([][]byte, 100)
+		for i := range datagrams {
+			wg.Go(func() {
+				datagrams[i], _ = s.Seal(nil, []byte("hello"))
+			})
+		}
+		wg.Wait()
+
+		for i, d := range datagrams {
+			if _, err := r.Open(nil, d); err != nil {
+				t.Errorf("Open(datagram %d) err = %v", i, err)
+			}
+		}
+	})
+
+	t.Run("concurrent with traced protocols", func(t *testing.T) {
+		var sendLog, recvLog newplex.TraceLog
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		q := p.Clone()
+		p.SetTracer(&sendLog)
+		q.SetTracer(&recvLog)
+		s, r := datagram.NewSender(p), datagram.NewReceiver(q)
+
+		var wg sync.WaitGroup
+		for range 100 {
+			wg.Go(func() {
+				d, err := s.Seal(nil, []byte("hello"))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := r.Open(nil, d); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+		wg.Wait()
+
+		// Only the Start events are traced.
+		if got, want := len(sendLog)+len(recvLog), 2; got != want {
+			t.Errorf("len(events) = %d, want = %d", got, want)
+		}
+	})
+}
+
+func TestReceiver_Open(t *testing.T) {
+	t.Run("in order", func(t *testing.T) {
+		s, r := newPair()
+		for i, d := range seal(t, s, 10) {
+			open(t, r, d, i)
+		}
+	})
+
+	t.Run("reordered and lost", func(t *testing.T) {
+		s, r := newPair()
+		datagrams := seal(t, s, 10)
+		for _, i := range []int{3, 0, 9, 5, 1, 8} {
+			open(t, r, datagrams[i], i)
+		}
+	})
+
+	t.Run("replayed", func(t *testing.T) {
+		s, r := newPair()
+		datagrams := seal(t, s, 3)
+		open(t, r, datagrams[2], 2)
+		open(t, r, datagrams[0], 0)
+
+		for _, i := range []int{0, 2} {
+			if _, err := r.Open(nil, datagrams[i]); !errors.Is(err, datagram.ErrReplay) {
+				t.Errorf("Open(datagram %d) err = %v, want = %v", i, err, datagram.ErrReplay)
+			}
+		}
+	})
+
+	t.Run("window", func(t *testing.T) {
+		s, r := newPair()
+		datagrams := seal(t, s, datagram.WindowSize+10)
+		open(t, r, datagrams[datagram.WindowSize+5], datagram.WindowSize+5)
+
+		// The oldest counter in the window is accepted, and the one before it is too old.
+		open(t, r, datagrams[6], 6)
+		if _, err := r.Open(nil, datagrams[5]); !errors.Is(err, datagram.ErrReplay) {
+			t.Errorf("Open(datagram 5) err = %v, want = %v", err, datagram.ErrReplay)
+		}
+
+		// Advancing the window by less than its size keeps counters which are still in it.
+		open(t, r, datagrams[datagram.WindowSize+9], datagram.WindowSize+9)
+		if _, err := r.Open(nil, datagrams[datagram.WindowSize+5]); !errors.Is(err, datagram.ErrReplay) {
+			t.Errorf("Open(datagram %d) err = %v, want = %v", datagram.WindowSize+5, err, datagram.ErrReplay)
+		}
+		open(t, r, datagrams[datagram.WindowSize+7], datagram.WindowSize+7)
+		open(t, r, datagrams[10], 10)
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		s, r := newPair()
+		d, err := s.Seal(nil, []byte("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range d {
+			modified := bytes.Clone(d)
+			modified[i] ^= 1
+			if _, err := r.Open(nil, modified); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+				t.Errorf("Open(byte %d modified) err = %v, want = %v", i, err, newplex.ErrInvalidCiphertext)
+			}
+		}
+
+		// Invalid datagrams don't update the window.
+		if _, err := r.Open(nil, d); err != nil {
+			t.Errorf("Open() err = %v", err)
+		}
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		_, r := newPair()
+		if _, err := r.Open(nil, make([]byte, datagram.Overhead-1)); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("Open() err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		s, _ := newPair()
+		r := datagram.NewReceiver(newplex.NewProtocol("example"))
+		d, err := s.Seal(nil, []byte("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := r.Open(nil, d); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("Open() err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+		}
+	})
+}
+
+func TestConn(t *testing.T) {
+	a, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = a.Close() }()
+
+	nc, err := net.Dial("udp", a.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := newplex.NewProtocol("example")
+	p.Mix("key", []byte("it's a key"))
+	send, recv := p.Fork("direction", []byte("client"), []byte("server"))
+	client := datagram.NewConn(nc, send, recv)
+	defer func() { _ = client.Close() }()
+
+	serverSend, serverRecv := datagram.NewSender(recv.Clone()), datagram.NewReceiver(send.Clone())
+
+	if _, err := client.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1024)
+	n, addr, err := a.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := serverRecv.Open(nil, buf[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(got), "hello"; got != want {
+		t.Errorf("Open() = %q, want = %q", got, want)
+	}
+
+	t.Run("discards invalid datagrams", func(t *testing.T) {
+		valid, err := serverSend.Seal(nil, []byte("world"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		invalid := bytes.Clone(valid)
+		invalid[len(invalid)-1] ^= 1
+		for _, d := range [][]byte{invalid, []byte("short"), valid, valid} {
+			if _, err := a.WriteTo(d, addr); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		n, err := client.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := string(buf[:n]), "world"; got != want {
+			t.Errorf("Read() = %q, want = %q", got, want)
+		}
+
+		// The replayed datagram is discarded.
+		if err := client.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("Read() err = %v, want = %v", err, os.ErrDeadlineExceeded)
+		}
+	})
+
+	t.Run("short buffer", func(t *testing.T) {
+		if err := client.SetReadDeadline(time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+
+		valid, err := serverSend.Seal(nil, bytes.Repeat([]byte("x"), 100))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := a.WriteTo(valid, addr); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Read(make([]byte, 10)); !errors.Is(err, io.ErrShortBuffer) {
+			t.Errorf("Read() err = %v, want = %v", err, io.ErrShortBuffer)
+		}
+	})
+}
+
+func Example() {
+	// Initialize identically keyed protocols (e.g., from a handshake) for the sender and receiver.
+	p := newplex.NewProtocol("com.example.datagram")
+	p.Mix("key", []byte("my-secret-key"))
+	s, r := datagram.NewSender(p.Clone()), datagram.NewReceiver(p)
+
+	// Seal a few datagrams.
+	var datagrams [][]byte
+	for _, msg := range []string{"one", "two", "three"} {
+		d, err := s.Seal(nil, []byte(msg))
+		if err != nil {
+			panic(err)
+		}
+		datagrams = append(datagrams, d)
+	}
+
+	// Open them out of order, and try to replay one.
+	for _, i := range []int{2, 0, 2} {
+		msg, err := r.Open(nil, datagrams[i])
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("%s\n", msg)
+	}
+
+	// Output:
+	// three
+	// one
+	// newplex/datagram: replayed datagram
+}
+
+func BenchmarkSender_Seal(b *testing.B) {
+	s, _ := newPair()
+	payload := make([]byte, 1200)
+	buf := make([]byte, 0, len(payload)+datagram.Overhead)
+	b.SetBytes(int64(len(payload)))
+	b.ReportAllocs()
+	for b.Loop() {
+		_, _ = s.Seal(buf, payload)
+	}
+}
+
+func BenchmarkReceiver_Open(b *testing.B) {
+	s, _ := newPair()
+	payload := make([]byte, 1200)
+	datagrams := make([][]byte, 1024)
+	for i := range datagrams {
+		datagrams[i], _ = s.Seal(nil, payload)
+	}
+	buf := make([]byte, 0, len(payload))
+	b.SetBytes(int64(len(payload) * len(datagrams)))
+	b.ReportAllocs()
+	for b.Loop() {
+		// Use a new receiver for each batch of datagrams, since each can only be opened once.
+		_, r := newPair()
+		for _, d := range datagrams {
+			_, _ = r.Open(buf, d)
+		}
+	}
+}
+
+func FuzzReceiver(f *testing.F) {
+	drbg := testdata.New("newplex datagram fuzz")
+	for range 10 {
+		f.Add(drbg.Data(64))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, r := newPair()
+		v, err := r.Open(nil, data)
+		if err == nil {
+			t.Errorf("Open(data=%x) = payload=%x, want = err", data, v)
+		}
+	})
+}
@@ -25,6 +25,28 @@ func BenchmarkDigest(b *testing.B) {
 	}
 }
 
+func BenchmarkSumMany(b *testing.B) {
+	for _, length := range lengths[:4] {
+		b.Run(length.name, func(b *testing.B) {
+			msgs := make([][]byte, 64)
+			dst := make([][]byte, len(msgs))
+			for i := range msgs {
+				msgs[i] = make([]byte, length.n)
+				dst[i] = make([]byte, 0, digest.UnkeyedSize)
+			}
+
+			b.ReportAllocs()
+			b.SetBytes(int64(length.n * len(msgs)))
+			for b.Loop() {
+				for i := range dst {
+					dst[i] = dst[i][:0]
+				}
+				digest.SumMany("com.example.benchmark", msgs, dst)
+			}
+		})
+	}
+}
+
 var lengths = []struct {
 	name string
 	n    int
@@ -39,6 +39,28 @@ func NewKeyed(domain string, key []byte) hash.Hash {
 	return d
 }
 
+// SumMany appends the unkeyed digest of each message to the corresponding element of dst and returns the resulting
+// slices. If dst is nil, a new slice is allocated. The results are identical to hashing each message with a hash.Hash
+// returned by New with the given domain string.
+//
+// Where supported, SumMany hashes groups of independent messages in parallel, providing much higher aggregate throughput
+// than hashing short messages one at a time. Currently, this is only supported on amd64 processors with AVX-512 and
+// VAES; on other processors, including all arm64 processors, SumMany is no faster than hashing each message in turn.
+// See newplex.Protocol.DeriveMany.
+//
+// SumMany panics if dst is not nil and len(dst) != len(msgs).
+func SumMany(domain string, msgs [][]byte, dst [][]byte) [][]byte {
+	base := newplex.NewProtocol(domain)
+	batch := make([]newplex.Protocol, len(msgs)) // Allocate all protocols at once instead of cloning each.
+	protocols := make([]*newplex.Protocol, len(msgs))
+	for i, msg := range msgs {
+		batch[i] = *base
+		batch[i].Mix("message", msg)
+		protocols[i] = &batch[i]
+	}
+	return newplex.DeriveMany(protocols, "digest", dst, UnkeyedSize)
+}
+
 type digest struct {
 	base, p *newplex.Protocol
 	w       *newplex.MixWriter
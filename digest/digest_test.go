@@ -74,3 +74,23 @@ func TestDigest_Reset(t *testing.T) {
 		t.Errorf("Sum() after Reset+Write = %x, want %x", sum2, sum1)
 	}
 }
+
+func TestSumMany(t *testing.T) {
+	var msgs [][]byte
+	for i := range 11 {
+		msgs = append(msgs, bytes.Repeat([]byte{byte(i)}, i*20))
+	}
+
+	got := digest.SumMany("com.example.test", msgs, nil)
+	if got, want := len(got), len(msgs); got != want {
+		t.Fatalf("len(SumMany()) = %d, want %d", got, want)
+	}
+
+	for i, msg := range msgs {
+		h := digest.New("com.example.test")
+		h.Write(msg)
+		if want := h.Sum(nil); !bytes.Equal(got[i], want) {
+			t.Errorf("SumMany()[%d] = %x, want %x", i, got[i], want)
+		}
+	}
+}
@@ -208,3 +208,28 @@ func ExampleProtocol_hpke() {
 	// ciphertext = 672e904ba78b50b56f896d4b9c2f8018aecfd34038523a6faa4e82e37be4281fbf994b02f43b7d778450dc5ca8a017b07cc18b32082e9160372940
 	// plaintext  = hello world
 }
+
+func ExampleDiffTraces() {
+	var senderLog, receiverLog newplex.TraceLog
+
+	sender := newplex.NewProtocol("com.example.trace")
+	sender.SetTracer(&senderLog)
+	sender.Mix("key", []byte("a shared key"))
+	sender.Mix("nonce", []byte("a nonce"))
+	ciphertext := sender.Seal("message", nil, []byte("hello world"))
+
+	// The receiver uses the wrong nonce.
+	receiver := newplex.NewProtocol("com.example.trace")
+	receiver.SetTracer(&receiverLog)
+	receiver.Mix("key", []byte("a shared key"))
+	receiver.Mix("nonce", []byte("A nonce"))
+	_, err := receiver.Open("message", nil, ciphertext)
+	fmt.Println(err)
+
+	_, diff := newplex.DiffTraces(senderLog, receiverLog)
+	fmt.Println(diff)
+
+	// Output:
+	// newplex: invalid ciphertext
+	// event 2: data differs: Mix("nonce", 7) = 99b0b10500f9433a vs Mix("nonce", 7) = 9fbe9009a74176d1
+}
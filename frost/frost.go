@@ -14,6 +14,7 @@ import (
 
 	"github.com/codahale/newplex"
 	"github.com/codahale/newplex/sig"
+	"github.com/codahale/newplex/transcript"
 	"github.com/gtank/ristretto255"
 )
 
@@ -96,7 +97,7 @@ func KeyGen(domain string, maxSigners, threshold int, rand []byte) (*ristretto25
 
 	coeffs := make([]*ristretto255.Scalar, threshold)
 	for i := range threshold {
-		coeffs[i], _ = ristretto255.NewScalar().SetUniformBytes(keygen.Derive("coefficient", nil, 64))
+		coeffs[i] = transcript.ChallengeScalar(keygen, "coefficient")
 	}
 
 	// The group public key is [a_0]G where a_0 is the secret.
@@ -128,11 +129,11 @@ func KeyGen(domain string, maxSigners, threshold int, rand []byte) (*ristretto25
 func (s *Signer) Commit(rand []byte) (Nonce, Commitment) {
 	x := newplex.NewProtocol(s.domain)
 	_, c := x.Fork("process", []byte("keygen"), []byte("commitment"))
-	c.Mix("signing-share", s.signingShare.Bytes())
+	transcript.AppendScalar(c, "signing-share", s.signingShare)
 	c.Mix("rand", rand)
 
-	hiding, _ := ristretto255.NewScalar().SetUniformBytes(c.Derive("hiding-nonce", nil, 64))
-	binding, _ := ristretto255.NewScalar().SetUniformBytes(c.Derive("binding-nonce", nil, 64))
+	hiding := transcript.ChallengeScalar(c, "hiding-nonce")
+	binding := transcript.ChallengeScalar(c, "binding-nonce")
 
 	return Nonce{hiding: hiding, binding: binding}, Commitment{
 		Identifier: s.identifier,
@@ -286,7 +287,7 @@ func VerifyShare(domain string, verifyingShare, groupKey *ristretto255.Element,
 // protocol state via cloning to align with the FROST security proof.
 func computeBindingFactors(domain string, groupKey *ristretto255.Element, message []byte, commitments []Commitment) (map[uint16]*ristretto255.Scalar, error) {
 	p := newplex.NewProtocol(domain)
-	p.Mix("frost-binding", groupKey.Bytes())
+	transcript.AppendElement(p, "frost-binding", groupKey)
 	p.Mix("message", message)
 	for _, c := range commitments {
 		if len(c.Hiding) != 32 || len(c.Binding) != 32 {
@@ -301,7 +302,7 @@ func computeBindingFactors(domain string, groupKey *ristretto255.Element, messag
 	for _, c := range commitments {
 		bp := p.Clone()
 		bp.Mix("binding-participant", binary.BigEndian.AppendUint16(nil, c.Identifier))
-		rho, _ := ristretto255.NewScalar().SetUniformBytes(bp.Derive("binding-factor", nil, 64))
+		rho := transcript.ChallengeScalar(bp, "binding-factor")
 		factors[c.Identifier] = rho
 	}
 
@@ -330,11 +331,11 @@ func computeGroupCommitment(commitments []Commitment, bindingFactors map[uint16]
 // computeChallenge derives the Schnorr challenge scalar. The transcript matches [sig.Verify], ensuring compatibility.
 func computeChallenge(domain string, groupKey *ristretto255.Element, message []byte, groupCommitment *ristretto255.Element) *ristretto255.Scalar {
 	p := newplex.NewProtocol(domain)
-	p.Mix("signer", groupKey.Bytes())
+	transcript.AppendElement(p, "signer", groupKey)
 	p.Mix("message", message)
 	_, verifier := p.Fork("role", []byte("prover"), []byte("verifier"))
-	verifier.Mix("commitment", groupCommitment.Bytes())
-	c, _ := ristretto255.NewScalar().SetUniformBytes(verifier.Derive("challenge", nil, 64))
+	transcript.AppendElement(verifier, "commitment", groupCommitment)
+	c := transcript.ChallengeScalar(verifier, "challenge")
 
 	return c
 }
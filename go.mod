@@ -5,6 +5,7 @@ go 1.26
 require (
 	github.com/gtank/ristretto255 v0.2.0
 	github.com/trailofbits/go-fuzz-utils v0.0.0-20250830184917-b61e672bc9ed
+	golang.org/x/sys v0.47.0
 )
 
 require filippo.io/edwards25519 v1.2.0 // indirect
@@ -0,0 +1,91 @@
+package handshake
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gtank/ristretto255"
+)
+
+// ErrUnknownPeer is returned by Allowlist.Verify when a static key is not in the allowlist.
+var ErrUnknownPeer = errors.New("newplex/handshake: unknown peer")
+
+// An Allowlist is a set of static public keys which are allowed to complete a handshake. Its Verify method can be used
+// as Config.VerifyPeer.
+type Allowlist struct {
+	keys map[[32]byte]struct{}
+}
+
+// NewAllowlist returns an Allowlist containing the given static public keys.
+func NewAllowlist(keys ...*ristretto255.Element) *Allowlist {
+	a := &Allowlist{keys: make(map[[32]byte]struct{}, len(keys))}
+	for _, q := range keys {
+		a.keys[[32]byte(q.Bytes())] = struct{}{}
+	}
+	return a
+}
+
+// ReadAllowlist returns an Allowlist containing the static public keys read from r. Each line must contain a single
+// hex-encoded public key. Blank lines and lines starting with # are ignored, as is leading and trailing whitespace.
+func ReadAllowlist(r io.Reader) (*Allowlist, error) {
+	a := NewAllowlist()
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		b, err := hex.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("newplex/handshake: allowlist line %d: %w", n, err)
+		}
+
+		q, err := ristretto255.NewIdentityElement().SetCanonicalBytes(b)
+		if err != nil {
+			return nil, fmt.Errorf("newplex/handshake: allowlist line %d: %w", n, err)
+		}
+		a.keys[[32]byte(q.Bytes())] = struct{}{}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// LoadAllowlist returns an Allowlist containing the static public keys in the named file. See ReadAllowlist for the
+// file's format.
+func LoadAllowlist(name string) (*Allowlist, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	return ReadAllowlist(f)
+}
+
+// Contains returns true if the given static public key is in the allowlist.
+func (a *Allowlist) Contains(q *ristretto255.Element) bool {
+	_, ok := a.keys[[32]byte(q.Bytes())]
+	return ok
+}
+
+// Len returns the number of static public keys in the allowlist.
+func (a *Allowlist) Len() int {
+	return len(a.keys)
+}
+
+// Verify returns ErrUnknownPeer if the given static public key is not in the allowlist.
+func (a *Allowlist) Verify(q *ristretto255.Element) error {
+	if !a.Contains(q) {
+		return ErrUnknownPeer
+	}
+	return nil
+}
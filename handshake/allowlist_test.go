@@ -0,0 +1,79 @@
+package handshake_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codahale/newplex/handshake"
+	"github.com/codahale/newplex/internal/testdata"
+)
+
+func TestReadAllowlist(t *testing.T) {
+	drbg := testdata.New("newplex handshake allowlist")
+	_, qA := drbg.KeyPair()
+	_, qB := drbg.KeyPair()
+	_, qX := drbg.KeyPair()
+
+	t.Run("valid", func(t *testing.T) {
+		a, err := handshake.ReadAllowlist(strings.NewReader("# peers\n\n" + hex.EncodeToString(qA.Bytes()) + "\n  " +
+			hex.EncodeToString(qB.Bytes()) + "  \n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := a.Len(), 2; got != want {
+			t.Errorf("Len() = %d, want = %d", got, want)
+		}
+
+		if err := a.Verify(qA); err != nil {
+			t.Errorf("Verify(qA) = %v, want = nil", err)
+		}
+
+		if err := a.Verify(qB); err != nil {
+			t.Errorf("Verify(qB) = %v, want = nil", err)
+		}
+
+		if got, want := a.Verify(qX), handshake.ErrUnknownPeer; !errors.Is(got, want) {
+			t.Errorf("Verify(qX) = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("invalid hex", func(t *testing.T) {
+		if _, err := handshake.ReadAllowlist(strings.NewReader("not hex\n")); err == nil {
+			t.Error("ReadAllowlist() err = nil, want = error")
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		if _, err := handshake.ReadAllowlist(strings.NewReader(strings.Repeat("ff", 32))); err == nil {
+			t.Error("ReadAllowlist() err = nil, want = error")
+		}
+	})
+}
+
+func TestLoadAllowlist(t *testing.T) {
+	drbg := testdata.New("newplex handshake allowlist")
+	_, qA := drbg.KeyPair()
+
+	name := filepath.Join(t.TempDir(), "allowlist")
+	if err := os.WriteFile(name, []byte(hex.EncodeToString(qA.Bytes())+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := handshake.LoadAllowlist(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !a.Contains(qA) {
+		t.Error("Contains(qA) = false, want = true")
+	}
+
+	if _, err := handshake.LoadAllowlist(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("LoadAllowlist(missing) err = nil, want = error")
+	}
+}
@@ -0,0 +1,87 @@
+package handshake
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/codahale/newplex/digest"
+)
+
+const (
+	// CookieKeySize is the size, in bytes, of a cookie key.
+	CookieKeySize = 32
+
+	// CookieSize is the size, in bytes, of a cookie.
+	CookieSize = digest.KeyedSize
+)
+
+// ErrInvalidCookie is returned when a cookie was not issued for the given address and message or has expired.
+var ErrInvalidCookie = errors.New("newplex/handshake: invalid cookie")
+
+// A CookieKey is a secret key held by a responder, with which it issues and verifies stateless cookies. A cookie is a
+// MAC of the current time window, the initiator's address, and the initiator's first message, computed with a keyed
+// digest.
+//
+// Under load, a responder can reply to an initiator's first message with a cookie instead of processing it, and only
+// process first messages which are resent with a valid cookie for the initiator's address and that message, as in
+// WireGuard's cookie reply. Because an attacker who spoofs its address never receives the cookie, this limits the work
+// of responding to initiators which can receive messages at their addresses, and because the cookie is bound to the
+// message, it cannot be used to start other handshakes. The cookie is checked with a single hash before any
+// Diffie-Hellman operation is performed, so a responder on a transport which allows spoofing (e.g., UDP) should call
+// Verify with the initiator's address and first message before passing the message to Respond or State.ReadMessage.
+//
+// Cookies are not confidential and do not authenticate the responder, so an attacker who can send messages to an
+// initiator can make it retry with an invalid cookie, but no more.
+type CookieKey struct {
+	key    []byte
+	window time.Duration
+}
+
+// NewCookieKey returns a CookieKey which issues cookies with the given key, valid for between one and two of the
+// given time windows.
+//
+// Panics if the key is not CookieKeySize bytes long or the window is not positive.
+func NewCookieKey(key []byte, window time.Duration) *CookieKey {
+	if len(key) != CookieKeySize {
+		panic("newplex/handshake: cookie key must be 32 bytes")
+	}
+
+	if window <= 0 {
+		panic("newplex/handshake: cookie window must be positive")
+	}
+
+	return &CookieKey{key: key, window: window}
+}
+
+// Cookie returns a cookie for the given initiator address (e.g., its IP address and port) and first message in the
+// current time window.
+func (ck *CookieKey) Cookie(addr, msg []byte) []byte {
+	return ck.cookie(ck.now(), addr, msg)
+}
+
+// Verify returns ErrInvalidCookie unless the given cookie was issued for the given initiator address and first message
+// in the current or previous time window.
+func (ck *CookieKey) Verify(addr, msg, cookie []byte) error {
+	now := ck.now()
+	current, previous := ck.cookie(now, addr, msg), ck.cookie(now-1, addr, msg)
+	if subtle.ConstantTimeCompare(cookie, current)|subtle.ConstantTimeCompare(cookie, previous) == 0 {
+		return ErrInvalidCookie
+	}
+	return nil
+}
+
+// now returns the index of the current time window.
+func (ck *CookieKey) now() uint64 {
+	return uint64(time.Now().UnixNano() / int64(ck.window))
+}
+
+// cookie returns the cookie for the given time window, address, and message.
+func (ck *CookieKey) cookie(window uint64, addr, msg []byte) []byte {
+	h := digest.NewKeyed("newplex.handshake.cookie", ck.key)
+	_, _ = h.Write(binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint64(nil, window), uint64(len(addr))))
+	_, _ = h.Write(addr)
+	_, _ = h.Write(msg)
+	return h.Sum(nil)
+}
@@ -0,0 +1,122 @@
+package handshake_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/codahale/newplex/handshake"
+	"github.com/codahale/newplex/internal/testdata"
+)
+
+func TestCookieKey(t *testing.T) {
+	drbg := testdata.New("newplex handshake cookie")
+	ck := handshake.NewCookieKey(drbg.Data(handshake.CookieKeySize), time.Minute)
+	addr, msg := []byte("192.0.2.1:4040"), drbg.Data(32)
+	cookie := ck.Cookie(addr, msg)
+
+	if got, want := len(cookie), handshake.CookieSize; got != want {
+		t.Errorf("len(Cookie()) = %d, want = %d", got, want)
+	}
+
+	t.Run("valid cookie", func(t *testing.T) {
+		if err := ck.Verify(addr, msg, cookie); err != nil {
+			t.Errorf("Verify() = %v, want = nil", err)
+		}
+	})
+
+	t.Run("wrong address", func(t *testing.T) {
+		if got, want := ck.Verify([]byte("192.0.2.2:4040"), msg, cookie), handshake.ErrInvalidCookie; !errors.Is(got, want) {
+			t.Errorf("Verify() = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("wrong message", func(t *testing.T) {
+		if got, want := ck.Verify(addr, drbg.Data(32), cookie), handshake.ErrInvalidCookie; !errors.Is(got, want) {
+			t.Errorf("Verify() = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("ambiguous address and message", func(t *testing.T) {
+		addr2, msg2 := append(bytes.Clone(addr), msg[0]), msg[1:]
+		if got, want := ck.Verify(addr2, msg2, cookie), handshake.ErrInvalidCookie; !errors.Is(got, want) {
+			t.Errorf("Verify() = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		other := handshake.NewCookieKey(drbg.Data(handshake.CookieKeySize), time.Minute)
+		if got, want := other.Verify(addr, msg, cookie), handshake.ErrInvalidCookie; !errors.Is(got, want) {
+			t.Errorf("Verify() = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("modified cookie", func(t *testing.T) {
+		for _, modified := range [][]byte{nil, cookie[:8], append([]byte{cookie[0] ^ 1}, cookie[1:]...)} {
+			if got, want := ck.Verify(addr, msg, modified), handshake.ErrInvalidCookie; !errors.Is(got, want) {
+				t.Errorf("Verify(%x) = %v, want = %v", modified, got, want)
+			}
+		}
+	})
+
+	t.Run("expired cookie", func(t *testing.T) {
+		ck := handshake.NewCookieKey(drbg.Data(handshake.CookieKeySize), 10*time.Millisecond)
+		cookie := ck.Cookie(addr, msg)
+		time.Sleep(25 * time.Millisecond)
+
+		if got, want := ck.Verify(addr, msg, cookie), handshake.ErrInvalidCookie; !errors.Is(got, want) {
+			t.Errorf("Verify() = %v, want = %v", got, want)
+		}
+	})
+}
+
+func TestNewCookieKey(t *testing.T) {
+	for name, f := range map[string]func(){
+		"short key":           func() { handshake.NewCookieKey(make([]byte, 16), time.Minute) },
+		"non-positive window": func() { handshake.NewCookieKey(make([]byte, handshake.CookieKeySize), 0) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("NewCookieKey() did not panic")
+				}
+			}()
+
+			f()
+		})
+	}
+}
+
+func ExampleCookieKey() {
+	drbg := testdata.New("newplex handshake cookie example")
+	dRS, _ := drbg.KeyPair()
+	dIS, _ := drbg.KeyPair()
+	ck := handshake.NewCookieKey(drbg.Data(handshake.CookieKeySize), time.Minute)
+	addr := []byte("192.0.2.1:4040") // The initiator's source address, e.g. of a UDP datagram.
+
+	// Initiator sends a request to the responder.
+	_, request, err := handshake.Initiate("example", dIS, drbg.Reader())
+	if err != nil {
+		panic(err)
+	}
+
+	// Responder is under load, so it replies with a cookie instead of responding.
+	cookie := ck.Cookie(addr, request)
+
+	// Initiator resends the request along with the cookie.
+
+	// Responder checks the cookie before performing any Diffie-Hellman operations.
+	if err := ck.Verify(addr, request, cookie); err != nil {
+		panic(err)
+	}
+
+	if _, _, err := handshake.Respond("example", drbg.Reader(), dRS, request); err != nil {
+		panic(err)
+	}
+	fmt.Println("responded")
+
+	// Output:
+	// responded
+}
@@ -1,7 +1,18 @@
-// Package handshake implements a mutually-authenticated static-ephemeral handshake using Ristretto255 and Newplex.
+// Package handshake implements static-ephemeral handshakes using Ristretto255 and Newplex.
 //
-// This handshake provides mutual authentication, forward secrecy, and key compromise impersonation resistance for both
-// initiator and responder. It is equivalent to the "XX" handshake from the [Noise Protocol Framework]:
+// Handshakes follow the patterns of the [Noise Protocol Framework]: a State runs one party's side of a handshake for
+// a given Pattern (XX, IK, XK, NK, KK, or N), writing and reading messages in turn until the handshake is complete,
+// then splits the handshake's protocol into a pair of protocols for sending and receiving. Patterns trade round trips
+// against identity hiding and the need to know static keys in advance. Each message carries an optional payload, which
+// is encrypted and authenticated with the keys established so far (including 0-RTT early data in the first message of
+// patterns in which the responder's static key is known in advance), or sent in the clear and authenticated by later
+// messages if no keys have been established yet. Any pattern can also be used with a pre-shared key, which keeps
+// sessions confidential even if Ristretto255 is broken and acts as an additional authentication factor. A responder
+// can issue the initiator a ticket (see TicketKey) after a handshake, which the initiator can use to resume the session
+// later with the cheaper Resume pattern.
+//
+// Initiate and Respond implement the XX pattern, which provides mutual authentication, forward secrecy, and key
+// compromise impersonation resistance for both initiator and responder:
 //
 //	XX:
 //	-> e
@@ -37,68 +48,42 @@ type InitiatorFinish = func(in []byte) (send, recv *newplex.Protocol, qRS *ristr
 
 // Initiate starts the handshake from the initiator role, returning a finish function, a payload, and potentially an
 // error. If no error is returned, the payload should be transmitted to the responder.
+//
+// Initiate and Respond implement the XX pattern with fixed-size messages which carry no payloads and without
+// authentication tags on messages which do not contain a static key. New code should use State with XX.
 func Initiate(domain string, dIS *ristretto255.Scalar, rand io.Reader) (finish InitiatorFinish, request []byte, err error) {
-	// Initialize a protocol.
-	p := newplex.NewProtocol(domain)
-
-	// Generate an ephemeral key pair.
-	var r [64]byte
-	if _, err := io.ReadFull(rand, r[:]); err != nil {
+	hs, err := newState(&Config{Pattern: XX, Domain: domain, Initiator: true, StaticKey: dIS, Rand: rand}, true)
+	if err != nil {
 		return nil, nil, err
 	}
-	dIE, _ := ristretto255.NewScalar().SetUniformBytes(r[:])
-	qIE := ristretto255.NewIdentityElement().ScalarBaseMult(dIE)
-	request = qIE.Bytes()
 
-	// Mix the initiator's ephemeral public key into the protocol.
-	p.Mix("ie", request)
+	// Generate an ephemeral key pair and send its public key.
+	request, err = hs.WriteMessage(nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Wait for the responder's response.
 	finish = func(response []byte) (send, recv *newplex.Protocol, qRS *ristretto255.Element, confirmation []byte, err error) {
-		qIS := ristretto255.NewIdentityElement().ScalarBaseMult(dIS)
-
-		// Decode the responder's ephemeral public key.
-		qRE, _ := ristretto255.NewIdentityElement().SetCanonicalBytes(response[:32])
-		if qRE == nil {
+		// Read the responder's ephemeral public key and sealed static public key.
+		if _, err := hs.ReadMessage(nil, response); err != nil {
 			return nil, nil, nil, nil, ErrInvalidHandshake
 		}
 
-		// Mix in the responder's ephemeral public key.
-		p.Mix("re", qRE.Bytes())
-
-		// Calculate and mix in the ephemeral-ephemeral shared secret.
-		iErE := ristretto255.NewIdentityElement().ScalarMult(dIE, qRE)
-		p.Mix("ie-re", iErE.Bytes())
-
-		// Open the responder's static public key.
-		response, err = p.Open("rs", nil, response[32:])
+		// Seal the initiator's static public key.
+		confirmation, err = hs.WriteMessage(nil, nil)
 		if err != nil {
-			return nil, nil, nil, nil, ErrInvalidHandshake
+			return nil, nil, nil, nil, err
 		}
 
-		// Decode the responder's static public key.
-		qRS, _ = ristretto255.NewIdentityElement().SetCanonicalBytes(response)
-		if qRS == nil {
-			return nil, nil, nil, nil, ErrInvalidHandshake
+		// Ratchet and fork the protocol into send and recv clones.
+		send, recv, err = hs.Split()
+		if err != nil {
+			return nil, nil, nil, nil, err
 		}
 
-		// Calculate and mix in the ephemeral-static shared secret.
-		iErS := ristretto255.NewIdentityElement().ScalarMult(dIE, qRS)
-		p.Mix("ie-rs", iErS.Bytes())
-
-		// Seal the initiator's static public key.
-		confirmation = p.Seal("is", nil, qIS.Bytes())
-
-		// Calculate and mix in the static-ephemral shared secret.
-		iSrE := ristretto255.NewIdentityElement().ScalarMult(dIS, qRE)
-		p.Mix("is-re", iSrE.Bytes())
-
-		// Ratchet and fork the protocol into recv and send clones.
-		p.Ratchet("handshake")
-		recv, send = p.Fork("sender", []byte("responder"), []byte("initiator"))
-
 		// Return the forked protocols and the confirmation.
-		return send, recv, qRS, confirmation, nil
+		return send, recv, hs.RemoteStaticKey(), confirmation, nil
 	}
 
 	// Return the finish function and the initiate message.
@@ -112,68 +97,41 @@ type ResponderFinish = func(confirmation []byte) (send, recv *newplex.Protocol,
 // Respond accepts the handshake from the responder's role, given a domain separation string, a source of random data,
 // a static private key, and the initiator's payload. Returns a finish function and a payload to be transmitted to the
 // initiator.
+//
+// Respond performs Diffie-Hellman operations on any well-formed request, so responders exposed to spoofed requests
+// should, when under load, verify a cookie for the initiator's address and request (see CookieKey) before calling it.
 func Respond(domain string, rand io.Reader, dRS *ristretto255.Scalar, request []byte) (finish ResponderFinish, response []byte, err error) {
-	qRS := ristretto255.NewIdentityElement().ScalarBaseMult(dRS)
+	hs, err := newState(&Config{Pattern: XX, Domain: domain, StaticKey: dRS, Rand: rand}, true)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Decode the initiator's ephemeral public key.
-	qIE, _ := ristretto255.NewIdentityElement().SetCanonicalBytes(request)
-	if qIE == nil {
+	// Read the initiator's ephemeral public key.
+	if _, err := hs.ReadMessage(nil, request); err != nil {
 		return nil, nil, ErrInvalidHandshake
 	}
 
-	// Initialize a protocol.
-	p := newplex.NewProtocol(domain)
-
-	// Mix the initiator's ephemeral public key into the protocol.
-	p.Mix("ie", qIE.Bytes())
-
-	// Generate an ephemeral key pair.
-	var r [64]byte
-	if _, err := io.ReadFull(rand, r[:]); err != nil {
+	// Generate an ephemeral key pair and send its public key and the sealed static public key.
+	response, err = hs.WriteMessage(nil, nil)
+	if err != nil {
 		return nil, nil, err
 	}
-	dRE, _ := ristretto255.NewScalar().SetUniformBytes(r[:])
-	qRE := ristretto255.NewIdentityElement().ScalarBaseMult(dRE)
-	response = qRE.Bytes()
-
-	// Mix in the responder's ephemeral public key.
-	p.Mix("re", response)
-
-	// Calculate and mix in the ephemeral-ephemeral shared secret.
-	iErE := ristretto255.NewIdentityElement().ScalarMult(dRE, qIE)
-	p.Mix("ie-re", iErE.Bytes())
-
-	// Seal the responder's static public key.
-	response = p.Seal("rs", response, qRS.Bytes())
-
-	// Calculate and mix in the ephemeral-static shared secret.
-	iErS := ristretto255.NewIdentityElement().ScalarMult(dRS, qIE)
-	p.Mix("ie-rs", iErS.Bytes())
 
 	// Wait for the initiator's confirmation.
 	finish = func(confirmation []byte) (send, recv *newplex.Protocol, qIS *ristretto255.Element, err error) {
 		// Open the initiator's static public key.
-		confirmation, err = p.Open("is", nil, confirmation)
-		if err != nil {
+		if _, err := hs.ReadMessage(nil, confirmation); err != nil {
 			return nil, nil, nil, ErrInvalidHandshake
 		}
 
-		// Decode the initiator's static public key.
-		qIS, _ = ristretto255.NewIdentityElement().SetCanonicalBytes(confirmation)
-		if qIS == nil {
-			return nil, nil, nil, ErrInvalidHandshake
+		// Ratchet and fork the protocol into send and recv clones.
+		send, recv, err = hs.Split()
+		if err != nil {
+			return nil, nil, nil, err
 		}
 
-		// Calculate and mix in the static-ephemeral shared secret.
-		iSrE := ristretto255.NewIdentityElement().ScalarMult(dRE, qIS)
-		p.Mix("is-re", iSrE.Bytes())
-
-		// Fork the protocol and fork it into recv and send clones.
-		p.Ratchet("handshake")
-		send, recv = p.Fork("sender", []byte("responder"), []byte("initiator"))
-
 		// Return the forked protocols and the initiator's public key.
-		return send, recv, qIS, nil
+		return send, recv, hs.RemoteStaticKey(), nil
 	}
 
 	// Return the finish function and the response.
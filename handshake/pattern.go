@@ -0,0 +1,247 @@
+package handshake
+
+import (
+	"fmt"
+	"slices"
+)
+
+// A Pattern is a handshake pattern, which determines the number of messages in the handshake, which parties have
+// static keys, which static keys are known in advance, and the Diffie-Hellman operations which are performed.
+//
+// Pattern names and semantics follow the [Noise Protocol Framework]. The first character describes the initiator's
+// static key: N for none, K for known to the responder in advance, X for transmitted to the responder, and I for
+// transmitted immediately (with less identity hiding). The second character describes the responder's static key: K
+// for known to the initiator in advance and X for transmitted to the initiator. One-way patterns have a single
+// character which describes the responder's static key.
+//
+// Each message can carry a payload, and the documentation for each pattern describes the security of each message's
+// payload using the following terms:
+//
+//   - Cleartext: the payload is not encrypted. It is authenticated by the following messages, so tampering is detected
+//     before the handshake completes, but it must not contain secrets.
+//   - 0-RTT: the payload is encrypted to the responder's static key, so it is confidential unless that key is
+//     compromised. It is not forward secret and may be replayed by an attacker.
+//   - Ephemeral: the payload is encrypted with an ephemeral-ephemeral shared secret, so it is forward secret, but the
+//     recipient has not been authenticated, so an active attacker could be the recipient.
+//   - Forward secret: the payload is encrypted to an authenticated recipient with ephemeral keys on both sides.
+//
+// Each message also states whether it authenticates its sender. A sender authenticated only by a static-static
+// exchange (ss) can be impersonated by an attacker who has compromised the recipient's static key.
+//
+// Every pattern can be used with a pre-shared key (see Config.PreSharedKey), in which case every payload is also
+// encrypted with the pre-shared key and every sender is also authenticated by knowledge of it. Every two-way pattern can
+// also be used in hybrid mode (see Config.Hybrid), in which case the session keys and every payload after the
+// initiator's first are also protected by an ephemeral ML-KEM-768 exchange.
+//
+// [Noise Protocol Framework]: http://www.noiseprotocol.org/noise.html#handshake-patterns
+type Pattern int
+
+const (
+	// XX is a three-message pattern in which both parties transmit their static keys. It requires no prior knowledge
+	// of either party's static key and hides both parties' identities from passive attackers:
+	//
+	//	-> e              cleartext, sender anonymous
+	//	<- e, ee, s, es   ephemeral, sender authenticated
+	//	-> s, se          forward secret, sender authenticated
+	XX Pattern = iota
+
+	// IK is a two-message pattern in which the initiator knows the responder's static key in advance and transmits
+	// its own static key in the first message, encrypted to the responder's static key. The initiator's identity is
+	// not forward-secret:
+	//
+	//	<- s
+	//	...
+	//	-> e, es, s, ss   0-RTT, sender authenticated (by ss)
+	//	<- e, ee, se      forward secret, sender authenticated
+	IK
+
+	// XK is a three-message pattern in which the initiator knows the responder's static key in advance and transmits
+	// its own static key in the final message, which provides stronger identity hiding for the initiator than IK:
+	//
+	//	<- s
+	//	...
+	//	-> e, es          0-RTT, sender anonymous
+	//	<- e, ee          ephemeral, sender authenticated
+	//	-> s, se          forward secret, sender authenticated
+	XK
+
+	// NK is a two-message pattern in which the initiator is anonymous and knows the responder's static key in
+	// advance:
+	//
+	//	<- s
+	//	...
+	//	-> e, es          0-RTT, sender anonymous
+	//	<- e, ee          ephemeral, sender authenticated
+	NK
+
+	// KK is a two-message pattern in which both parties know each other's static keys in advance:
+	//
+	//	-> s
+	//	<- s
+	//	...
+	//	-> e, es, ss      0-RTT, sender authenticated (by ss)
+	//	<- e, ee, se      forward secret, sender authenticated
+	KK
+
+	// N is a one-message, one-way pattern in which an anonymous initiator sends data to a responder whose static key
+	// it knows in advance. Only the initiator's sending protocol and the responder's receiving protocol should be used.
+	// Like the payload, data sent after the handshake is not forward secret with respect to the responder's static key
+	// and may be replayed:
+	//
+	//	<- s
+	//	...
+	//	-> e, es          0-RTT, sender anonymous
+	N
+
+	// Resume is a two-message pattern which resumes a previous session with a ticket issued by the responder (see
+	// State.IssueTicket and Config.Session). Instead of using static keys, the initiator sends the ticket and both
+	// parties mix in the previous session's resumption secret, which authenticates each of them as a party to the
+	// previous session, before an ephemeral exchange which makes the new session forward secret. It requires a single
+	// Diffie-Hellman operation per party, and each party's static public key is that of the previous session:
+	//
+	//	-> ticket, e      0-RTT, sender authenticated (by the resumption secret)
+	//	<- e, ee          forward secret, sender authenticated (by the resumption secret)
+	//
+	// The initiator's first payload is encrypted with the resumption secret, so it is not forward secret with respect
+	// to the responder's ticket key and may be replayed.
+	Resume
+)
+
+// String returns the name of the pattern.
+func (pattern Pattern) String() string {
+	if pattern < 0 || int(pattern) >= len(patterns) {
+		return fmt.Sprintf("Pattern(%d)", int(pattern))
+	}
+	return patterns[pattern].name
+}
+
+// OneWay returns true if the pattern only allows the initiator to send data after the handshake.
+func (pattern Pattern) OneWay() bool {
+	return pattern.spec().oneWay
+}
+
+// Messages returns the number of messages in the handshake.
+func (pattern Pattern) Messages() int {
+	return len(pattern.spec().messages)
+}
+
+type token uint8
+
+// Tokens which key the protocol (i.e., tokenEE and after) must be ordered last.
+const (
+	tokenE token = iota
+	tokenS
+	tokenE1
+	tokenEE
+	tokenES
+	tokenSE
+	tokenSS
+	tokenEKEM1
+	tokenTicket
+)
+
+type patternSpec struct {
+	name string
+
+	// initiatorKnown and responderKnown are true if the respective party's static key is known to the other party in
+	// advance.
+	initiatorKnown, responderKnown bool
+
+	// messages are the tokens of each message, starting with the initiator's first message.
+	messages [][]token
+
+	oneWay bool
+}
+
+var patterns = [...]patternSpec{
+	XX: {
+		name: "XX",
+		messages: [][]token{
+			{tokenE},
+			{tokenE, tokenEE, tokenS, tokenES},
+			{tokenS, tokenSE},
+		},
+	},
+	IK: {
+		name:           "IK",
+		responderKnown: true,
+		messages: [][]token{
+			{tokenE, tokenES, tokenS, tokenSS},
+			{tokenE, tokenEE, tokenSE},
+		},
+	},
+	XK: {
+		name:           "XK",
+		responderKnown: true,
+		messages: [][]token{
+			{tokenE, tokenES},
+			{tokenE, tokenEE},
+			{tokenS, tokenSE},
+		},
+	},
+	NK: {
+		name:           "NK",
+		responderKnown: true,
+		messages: [][]token{
+			{tokenE, tokenES},
+			{tokenE, tokenEE},
+		},
+	},
+	KK: {
+		name:           "KK",
+		initiatorKnown: true,
+		responderKnown: true,
+		messages: [][]token{
+			{tokenE, tokenES, tokenSS},
+			{tokenE, tokenEE, tokenSE},
+		},
+	},
+	N: {
+		name:           "N",
+		responderKnown: true,
+		messages: [][]token{
+			{tokenE, tokenES},
+		},
+		oneWay: true,
+	},
+	Resume: {
+		name: "Resume",
+		messages: [][]token{
+			{tokenTicket, tokenE},
+			{tokenE, tokenEE},
+		},
+	},
+}
+
+// spec returns the pattern's specification. Panics if the pattern is unknown.
+func (pattern Pattern) spec() *patternSpec {
+	if pattern < 0 || int(pattern) >= len(patterns) {
+		panic("newplex/handshake: unknown pattern")
+	}
+	return &patterns[pattern]
+}
+
+// hybridMessages returns the pattern's messages with the tokens for an ephemeral ML-KEM exchange added: the
+// initiator's KEM encapsulation key (e1) after its ephemeral key in the first message, and the responder's KEM
+// ciphertext (ekem1) after the ee exchange in the second message, as in the Noise HFS extension. The pattern must not
+// be one-way.
+func (spec *patternSpec) hybridMessages() [][]token {
+	messages := slices.Clone(spec.messages)
+	messages[0] = slices.Insert(slices.Clone(messages[0]), slices.Index(messages[0], tokenE)+1, tokenE1)
+	messages[1] = slices.Insert(slices.Clone(messages[1]), slices.Index(messages[1], tokenEE)+1, tokenEKEM1)
+	return messages
+}
+
+// hasStatic returns true if the given party has a static key in the pattern.
+func (spec *patternSpec) hasStatic(initiator bool) bool {
+	if initiator && spec.initiatorKnown || !initiator && spec.responderKnown {
+		return true
+	}
+
+	for i, msg := range spec.messages {
+		if (i%2 == 0) == initiator && slices.Contains(msg, tokenS) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,634 @@
+package handshake
+
+import (
+	"crypto/mlkem"
+	"errors"
+	"io"
+	"slices"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/internal/kem"
+	"github.com/gtank/ristretto255"
+)
+
+const (
+	// PreSharedKeySize is the size, in bytes, of a pre-shared key.
+	PreSharedKeySize = 32
+
+	// KEMEncapsulationKeySize is the size, in bytes, of the ML-KEM-768 encapsulation key which hybrid mode adds to the
+	// initiator's first message (plus newplex.TagSize if the protocol is keyed).
+	KEMEncapsulationKeySize = mlkem.EncapsulationKeySize768
+
+	// KEMCiphertextSize is the size, in bytes, of the ML-KEM-768 ciphertext which hybrid mode adds to the responder's
+	// first message (plus newplex.TagSize).
+	KEMCiphertextSize = mlkem.CiphertextSize768
+)
+
+var (
+	// ErrHybridOneWay is returned when hybrid mode is used with a one-way pattern.
+	ErrHybridOneWay = errors.New("newplex/handshake: hybrid mode requires a two-way pattern")
+
+	// ErrInvalidPreSharedKey is returned when a pre-shared key is not PreSharedKeySize bytes long.
+	ErrInvalidPreSharedKey = errors.New("newplex/handshake: invalid pre-shared key")
+
+	// ErrMissingKey is returned when a static key required by the handshake pattern was not provided.
+	ErrMissingKey = errors.New("newplex/handshake: missing static key")
+
+	// ErrOutOfOrder is returned when a message is written or read out of turn, or when a handshake is split before it
+	// is complete.
+	ErrOutOfOrder = errors.New("newplex/handshake: message out of order")
+)
+
+// Config configures a party to a handshake.
+type Config struct {
+	// Pattern is the handshake pattern. Both parties must use the same pattern.
+	Pattern Pattern
+
+	// Domain is the domain separation string. Both parties must use the same domain separation string.
+	Domain string
+
+	// Initiator is true if the party is the initiator of the handshake.
+	Initiator bool
+
+	// StaticKey is the party's static private key. It is required if the pattern includes a static key for the party.
+	StaticKey *ristretto255.Scalar
+
+	// RemoteStaticKey is the other party's static public key. It is required if the pattern requires the other
+	// party's static key to be known in advance, and ignored otherwise.
+	RemoteStaticKey *ristretto255.Element
+
+	// PreSharedKey is an optional secret shared by both parties, which must be PreSharedKeySize bytes long if present.
+	// Both parties must use the same pre-shared key, or neither.
+	//
+	// The pre-shared key is mixed into the protocol before the first message, so every payload and every transmitted
+	// static key is encrypted with it, and the handshake only succeeds if both parties have it. Sessions remain
+	// confidential against an attacker who does not know the pre-shared key even if they can break Ristretto255 (e.g.,
+	// a future attacker with a quantum computer who has recorded the handshake), and the pre-shared key acts as an
+	// additional authentication factor for both parties. It does not make early data forward secret or prevent it
+	// from being replayed.
+	PreSharedKey []byte
+
+	// VerifyPeer, if not nil, is called with the other party's static public key as soon as it is received, before any
+	// further messages are read or written. If it returns an error, the handshake fails with that error. It is not
+	// called for static keys known in advance, but is called for the initiator's static key in a resumption ticket.
+	//
+	// When VerifyPeer is called, the other party has not yet proven possession of the corresponding private key; the
+	// handshake will fail if it cannot. VerifyPeer allows a party to reject unknown peers (see Allowlist) without
+	// revealing anything further to them.
+	VerifyPeer func(q *ristretto255.Element) error
+
+	// Hybrid is true if the handshake should also perform an ephemeral ML-KEM-768 key exchange, which must be true for
+	// both parties or neither. It is not supported for one-way patterns.
+	//
+	// In hybrid mode, the initiator sends an ephemeral ML-KEM-768 encapsulation key in its first message, and the
+	// responder sends a ciphertext encapsulating a shared secret to it in its first message, which is mixed into the
+	// protocol along with the Diffie-Hellman shared secrets. The session keys and every payload after the initiator's
+	// first remain confidential unless both Ristretto255 and ML-KEM-768 are broken, which protects recorded sessions
+	// from a future attacker with a quantum computer. Hybrid mode does not protect the parties' identities or the
+	// initiator's first payload, and static keys are still authenticated only with Ristretto255.
+	//
+	// Hybrid mode adds KEMEncapsulationKeySize bytes to the initiator's first message and KEMCiphertextSize bytes to
+	// the responder's first message (plus tags; see Overhead). Encapsulation uses crypto/rand, regardless of Rand.
+	Hybrid bool
+
+	// Session is the previous session which the initiator resumes with the Resume pattern. It is required for the
+	// initiator of a Resume handshake and ignored otherwise.
+	Session *Session
+
+	// TicketKey is the key with which the responder opens the initiator's ticket in the Resume pattern. It is required
+	// for the responder of a Resume handshake and ignored otherwise.
+	TicketKey *TicketKey
+
+	// Rand is the source of randomness for ephemeral keys (e.g., crypto/rand.Reader).
+	Rand io.Reader
+}
+
+// A State is one party's state of a handshake. Parties take turns writing and reading messages, starting with the
+// initiator, until the handshake is complete, at which point Split returns protocols for sending and receiving.
+//
+// If writing or reading a message returns an error, the handshake has failed and the State must be discarded.
+type State struct {
+	p         *newplex.Protocol
+	domain    string
+	spec      *patternSpec
+	initiator bool
+	rand      io.Reader
+	verify    func(q *ristretto255.Element) error
+	s, e      *ristretto255.Scalar
+	rs, re    *ristretto255.Element
+	kem       *mlkem.DecapsulationKey768
+	rkem      *mlkem.EncapsulationKey768
+	session   *Session
+	ticketKey *TicketKey
+	secret    []byte
+	messages  [][]token
+	msg       int
+	keyed     bool
+	compat    bool
+	err       error
+}
+
+// New returns a new State for the given configuration.
+//
+// Returns ErrMissingKey if the configuration lacks a static key, session, or ticket key required by the pattern,
+// ErrInvalidPreSharedKey if the configuration has a pre-shared key of the wrong size, ErrInvalidTicket if the
+// configuration has a session with a ticket of the wrong size, or ErrHybridOneWay if the configuration uses hybrid mode
+// with a one-way pattern. Panics if the pattern is unknown.
+func New(config *Config) (*State, error) {
+	return newState(config, false)
+}
+
+// newState returns a new State. If compat is true, the pattern's name is not mixed into the protocol and messages do
+// not have payloads, which matches Initiate and Respond.
+func newState(config *Config, compat bool) (*State, error) {
+	spec := config.Pattern.spec()
+	hs := &State{
+		p:         newplex.NewProtocol(config.Domain),
+		domain:    config.Domain,
+		spec:      spec,
+		initiator: config.Initiator,
+		rand:      config.Rand,
+		verify:    config.VerifyPeer,
+		messages:  spec.messages,
+		compat:    compat,
+	}
+
+	// Check for the party's static key.
+	if spec.hasStatic(hs.initiator) {
+		if config.StaticKey == nil {
+			return nil, ErrMissingKey
+		}
+		hs.s = config.StaticKey
+	}
+
+	// Check for the other party's static key, if it must be known in advance.
+	if hs.initiator && spec.responderKnown || !hs.initiator && spec.initiatorKnown {
+		if config.RemoteStaticKey == nil {
+			return nil, ErrMissingKey
+		}
+		hs.rs = config.RemoteStaticKey
+	}
+
+	// Check for the initiator's session or the responder's ticket key, if resuming a session.
+	if spec == Resume.spec() {
+		if hs.initiator {
+			if config.Session == nil {
+				return nil, ErrMissingKey
+			}
+			if len(config.Session.Ticket) != TicketSize || len(config.Session.Secret) != ResumptionSecretSize {
+				return nil, ErrInvalidTicket
+			}
+			hs.session, hs.rs = config.Session, config.Session.RemoteStaticKey
+		} else {
+			if config.TicketKey == nil {
+				return nil, ErrMissingKey
+			}
+			hs.ticketKey = config.TicketKey
+		}
+	}
+
+	if !compat {
+		hs.p.Mix("pattern", []byte(spec.name))
+	}
+
+	// Add the ML-KEM tokens to the pattern, if in hybrid mode.
+	if config.Hybrid {
+		if spec.oneWay {
+			return nil, ErrHybridOneWay
+		}
+		hs.messages = spec.hybridMessages()
+		hs.p.Mix("hybrid", []byte("ML-KEM-768"))
+	}
+
+	// Mix in the static keys which are known in advance, initiator first.
+	if spec.initiatorKnown {
+		hs.p.Mix("is", hs.static(true).Bytes())
+	}
+	if spec.responderKnown {
+		hs.p.Mix("rs", hs.static(false).Bytes())
+	}
+
+	// Mix in the pre-shared key, if any, which keys the protocol for the first message.
+	if config.PreSharedKey != nil {
+		if len(config.PreSharedKey) != PreSharedKeySize {
+			return nil, ErrInvalidPreSharedKey
+		}
+		hs.p.Mix("psk", config.PreSharedKey)
+		hs.keyed = true
+	}
+
+	return hs, nil
+}
+
+// WriteMessage appends the next handshake message, carrying the given payload, to dst and returns the resulting slice.
+// The message should be transmitted to the other party. The payload may be empty.
+//
+// If PayloadEncrypted returns true, the payload is sealed under the keys established so far; otherwise, it is sent in
+// the clear and authenticated by later messages. The confidentiality and forward secrecy of each message's payload
+// depend on the pattern; see the documentation for each Pattern.
+//
+// Returns ErrOutOfOrder if it is not the party's turn to write a message, or any error returned by Rand.
+func (hs *State) WriteMessage(dst, payload []byte) ([]byte, error) {
+	if hs.err != nil {
+		return nil, hs.err
+	}
+
+	if !hs.CanWrite() {
+		return nil, ErrOutOfOrder
+	}
+
+	for _, t := range hs.messages[hs.msg] {
+		switch t {
+		case tokenE:
+			var r [64]byte
+			if _, err := io.ReadFull(hs.rand, r[:]); err != nil {
+				hs.err = err
+				return nil, err
+			}
+			hs.e, _ = ristretto255.NewScalar().SetUniformBytes(r[:])
+			qE := ristretto255.NewIdentityElement().ScalarBaseMult(hs.e).Bytes()
+			hs.p.Mix(hs.label("e", hs.initiator), qE)
+			dst = append(dst, qE...)
+		case tokenE1:
+			var seed [mlkem.SeedSize]byte
+			if _, err := io.ReadFull(hs.rand, seed[:]); err != nil {
+				hs.err = err
+				return nil, err
+			}
+			hs.kem, _ = mlkem.NewDecapsulationKey768(seed[:])
+			dst = hs.send(dst, hs.label("e1", hs.initiator), hs.kem.EncapsulationKey().Bytes())
+		case tokenEKEM1:
+			ss, ct := kem.Encapsulate768(hs.rkem)
+			dst = hs.send(dst, hs.label("ekem1", hs.initiator), ct)
+			hs.p.Mix("kem", ss)
+		case tokenS:
+			qS := ristretto255.NewIdentityElement().ScalarBaseMult(hs.s).Bytes()
+			dst = hs.send(dst, hs.label("s", hs.initiator), qS)
+		case tokenTicket:
+			dst = append(dst, hs.session.Ticket...)
+			hs.mixTicket(hs.session.Ticket, hs.session.Secret)
+		default:
+			hs.mixDH(t)
+		}
+	}
+
+	switch {
+	case hs.compat:
+	case hs.keyed:
+		dst = hs.p.Seal("payload", dst, payload)
+	default:
+		hs.p.Mix("payload", payload)
+		dst = append(dst, payload...)
+	}
+
+	hs.msg++
+	return dst, nil
+}
+
+// ReadMessage reads the next handshake message, which was received from the other party, appends its payload to dst,
+// and returns the resulting slice.
+//
+// Returns ErrOutOfOrder if it is not the party's turn to read a message, ErrInvalidHandshake if the message is invalid,
+// ErrInvalidTicket if the message contains a ticket which is invalid or has expired, or the error returned by VerifyPeer
+// if it rejects the other party's static key.
+func (hs *State) ReadMessage(dst, msg []byte) ([]byte, error) {
+	if hs.err != nil {
+		return nil, hs.err
+	}
+
+	if hs.Complete() || hs.CanWrite() {
+		return nil, ErrOutOfOrder
+	}
+
+	if n := hs.Overhead(); len(msg) < n || hs.compat && len(msg) != n {
+		return nil, hs.fail()
+	}
+
+	var err error
+	for _, t := range hs.messages[hs.msg] {
+		switch t {
+		case tokenE:
+			hs.re, _ = ristretto255.NewIdentityElement().SetCanonicalBytes(msg[:32])
+			if hs.re == nil || hs.re.Equal(ristretto255.NewIdentityElement()) == 1 {
+				return nil, hs.fail()
+			}
+			hs.p.Mix(hs.label("e", !hs.initiator), msg[:32])
+			msg = msg[32:]
+		case tokenE1:
+			var ek []byte
+			if ek, msg, err = hs.receive(hs.label("e1", !hs.initiator), msg, KEMEncapsulationKeySize); err != nil {
+				return nil, err
+			}
+			if hs.rkem, err = mlkem.NewEncapsulationKey768(ek); err != nil {
+				return nil, hs.fail()
+			}
+		case tokenEKEM1:
+			var ct, ss []byte
+			if ct, msg, err = hs.receive(hs.label("ekem1", !hs.initiator), msg, KEMCiphertextSize); err != nil {
+				return nil, err
+			}
+			if ss, err = hs.kem.Decapsulate(ct); err != nil {
+				return nil, hs.fail()
+			}
+			hs.p.Mix("kem", ss)
+		case tokenS:
+			var qS []byte
+			if qS, msg, err = hs.receive(hs.label("s", !hs.initiator), msg, 32); err != nil {
+				return nil, err
+			}
+			hs.rs, _ = ristretto255.NewIdentityElement().SetCanonicalBytes(qS)
+			if hs.rs == nil || hs.rs.Equal(ristretto255.NewIdentityElement()) == 1 {
+				return nil, hs.fail()
+			}
+			if err := hs.verifyPeer(); err != nil {
+				return nil, err
+			}
+		case tokenTicket:
+			var secret []byte
+			if secret, hs.rs, err = hs.ticketKey.open(hs.domain, msg[:TicketSize]); err != nil {
+				hs.err = err
+				return nil, err
+			}
+			hs.mixTicket(msg[:TicketSize], secret)
+			msg = msg[TicketSize:]
+			if hs.rs != nil {
+				if err := hs.verifyPeer(); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			hs.mixDH(t)
+		}
+	}
+
+	switch {
+	case hs.compat:
+	case hs.keyed:
+		if dst, err = hs.p.Open("payload", dst, msg); err != nil {
+			return nil, hs.fail()
+		}
+	default:
+		hs.p.Mix("payload", msg)
+		dst = append(dst, msg...)
+	}
+
+	hs.msg++
+	return dst, nil
+}
+
+// Overhead returns the number of bytes the next handshake message adds to its payload, regardless of which party
+// writes it. If the handshake is complete, it returns zero.
+func (hs *State) Overhead() int {
+	if hs.Complete() {
+		return 0
+	}
+
+	// Determine whether the protocol will be keyed at each point in the message.
+	keyed := hs.keyed
+	n := 0
+	for _, t := range hs.messages[hs.msg] {
+		switch t {
+		case tokenE:
+			n += 32
+		case tokenS, tokenE1, tokenEKEM1:
+			n += tokenSize(t)
+			if keyed {
+				n += newplex.TagSize
+			}
+		case tokenTicket:
+			n += TicketSize
+			keyed = true
+		default:
+			keyed = true
+		}
+	}
+
+	if keyed && !hs.compat {
+		n += newplex.TagSize
+	}
+	return n
+}
+
+// PayloadEncrypted returns true if the payload of the next handshake message will be encrypted. Payloads are encrypted
+// once the first Diffie-Hellman operation has been performed, which for patterns in which the responder's static key
+// is known in advance includes the initiator's first message (i.e., 0-RTT early data). If a pre-shared key is used or
+// a session is resumed, all payloads are encrypted.
+func (hs *State) PayloadEncrypted() bool {
+	if hs.Complete() {
+		return false
+	}
+
+	return hs.keyed || slices.ContainsFunc(hs.messages[hs.msg], func(t token) bool { return t >= tokenEE })
+}
+
+// CanWrite returns true if it is the party's turn to write a handshake message.
+func (hs *State) CanWrite() bool {
+	return !hs.Complete() && (hs.msg%2 == 0) == hs.initiator
+}
+
+// Complete returns true if all of the pattern's messages have been written or read.
+func (hs *State) Complete() bool {
+	return hs.msg == len(hs.spec.messages)
+}
+
+// RemoteStaticKey returns the other party's static public key, if it was known in advance or has been received. If
+// not, it returns nil.
+func (hs *State) RemoteStaticKey() *ristretto255.Element {
+	return hs.rs
+}
+
+// Split returns a pair of protocols for sending data to and receiving data from the other party. The handshake must be
+// complete.
+//
+// For one-way patterns, only the initiator's send protocol and the responder's receive protocol should be used.
+//
+// Returns ErrOutOfOrder if the handshake is not complete.
+func (hs *State) Split() (send, recv *newplex.Protocol, err error) {
+	if hs.err != nil {
+		return nil, nil, hs.err
+	}
+
+	if !hs.Complete() {
+		return nil, nil, ErrOutOfOrder
+	}
+
+	// Derive the resumption secret before the protocol is ratcheted.
+	hs.resumptionSecret()
+
+	// Ratchet and fork the protocol into recv and send clones.
+	hs.p.Ratchet("handshake")
+	responder, initiator := hs.p.Fork("sender", []byte("responder"), []byte("initiator"))
+	if hs.initiator {
+		return initiator, responder, nil
+	}
+	return responder, initiator, nil
+}
+
+// ResumptionSecret returns a secret derived from the complete handshake, with which the initiator can later resume the
+// session using a ticket (see IssueTicket and Session).
+//
+// Returns ErrOutOfOrder if the handshake is not complete.
+func (hs *State) ResumptionSecret() ([]byte, error) {
+	if hs.err != nil {
+		return nil, hs.err
+	}
+
+	if !hs.Complete() {
+		return nil, ErrOutOfOrder
+	}
+
+	return slices.Clone(hs.resumptionSecret()), nil
+}
+
+// IssueTicket returns a new ticket, sealed with the given ticket key, with which the other party can resume the session
+// using the Resume pattern. The ticket contains the resumption secret and the other party's static public key, if any.
+// It should be sent to the other party over the established session. Typically, the responder issues tickets to the
+// initiator, which passes them to Session.
+//
+// Returns ErrOutOfOrder if the handshake is not complete, or any error returned by Rand.
+func (hs *State) IssueTicket(key *TicketKey) ([]byte, error) {
+	secret, err := hs.ResumptionSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	return key.seal(hs.rand, hs.domain, secret, hs.rs)
+}
+
+// Session returns a Session which resumes the handshake's session with the given ticket, which was issued by the other
+// party.
+//
+// Returns ErrOutOfOrder if the handshake is not complete, or ErrInvalidTicket if the ticket is the wrong size.
+func (hs *State) Session(ticket []byte) (*Session, error) {
+	secret, err := hs.ResumptionSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ticket) != TicketSize {
+		return nil, ErrInvalidTicket
+	}
+
+	return &Session{Ticket: slices.Clone(ticket), Secret: secret, RemoteStaticKey: hs.rs}, nil
+}
+
+// resumptionSecret derives the resumption secret from a clone of the protocol, if it has not yet been derived, and
+// returns it. The handshake must be complete.
+func (hs *State) resumptionSecret() []byte {
+	if hs.secret == nil {
+		hs.secret = hs.p.Clone().Derive("resumption", nil, ResumptionSecretSize)
+	}
+	return hs.secret
+}
+
+// mixTicket mixes the given ticket and resumption secret into the protocol, which keys it.
+func (hs *State) mixTicket(ticket, secret []byte) {
+	hs.p.Mix("ticket", ticket)
+	hs.p.Mix("resumption", secret)
+	hs.keyed = true
+}
+
+// verifyPeer calls VerifyPeer, if any, with the other party's static public key, and marks the handshake as failed if
+// it returns an error.
+func (hs *State) verifyPeer() error {
+	if hs.verify == nil {
+		return nil
+	}
+
+	if err := hs.verify(hs.rs); err != nil {
+		hs.err = err
+		return err
+	}
+	return nil
+}
+
+// mixDH calculates the Diffie-Hellman shared secret for the given token and mixes it into the protocol.
+func (hs *State) mixDH(t token) {
+	var d *ristretto255.Scalar
+	var q *ristretto255.Element
+	switch {
+	case t == tokenEE:
+		d, q = hs.e, hs.re
+	case t == tokenSS:
+		d, q = hs.s, hs.rs
+	case (t == tokenES) == hs.initiator:
+		d, q = hs.e, hs.rs
+	default:
+		d, q = hs.s, hs.re
+	}
+
+	var label string
+	switch t {
+	case tokenEE:
+		label = "ie-re"
+	case tokenES:
+		label = "ie-rs"
+	case tokenSE:
+		label = "is-re"
+	case tokenSS:
+		label = "is-rs"
+	}
+
+	hs.p.Mix(label, ristretto255.NewIdentityElement().ScalarMult(d, q).Bytes())
+	hs.keyed = true
+}
+
+// send appends the given key to dst, sealing it if the protocol is keyed and mixing it in otherwise, and returns the
+// resulting slice.
+func (hs *State) send(dst []byte, label string, key []byte) []byte {
+	if hs.keyed {
+		return hs.p.Seal(label, dst, key)
+	}
+	hs.p.Mix(label, key)
+	return append(dst, key...)
+}
+
+// receive reads a key of the given size from the start of msg, opening it if the protocol is keyed and mixing it in
+// otherwise, and returns the key and the rest of msg.
+func (hs *State) receive(label string, msg []byte, size int) (key, rest []byte, err error) {
+	if !hs.keyed {
+		hs.p.Mix(label, msg[:size])
+		return msg[:size], msg[size:], nil
+	}
+
+	size += newplex.TagSize
+	key, err = hs.p.Open(label, nil, msg[:size])
+	if err != nil {
+		return nil, nil, hs.fail()
+	}
+	return key, msg[size:], nil
+}
+
+// tokenSize returns the size, in bytes, of the key transmitted for the given token, excluding any tag.
+func tokenSize(t token) int {
+	switch t {
+	case tokenE1:
+		return KEMEncapsulationKeySize
+	case tokenEKEM1:
+		return KEMCiphertextSize
+	default:
+		return 32
+	}
+}
+
+// label returns the label for the given party's key (e.g., "ie" for the initiator's ephemeral key).
+func (hs *State) label(key string, initiator bool) string {
+	if initiator {
+		return "i" + key
+	}
+	return "r" + key
+}
+
+// static returns the given party's static public key.
+func (hs *State) static(initiator bool) *ristretto255.Element {
+	if initiator == hs.initiator {
+		return ristretto255.NewIdentityElement().ScalarBaseMult(hs.s)
+	}
+	return hs.rs
+}
+
+// fail marks the handshake as failed and returns ErrInvalidHandshake.
+func (hs *State) fail() error {
+	hs.err = ErrInvalidHandshake
+	return hs.err
+}
@@ -0,0 +1,830 @@
+package handshake_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/codahale/newplex/handshake"
+	"github.com/codahale/newplex/internal/testdata"
+	"github.com/gtank/ristretto255"
+)
+
+var patterns = []handshake.Pattern{handshake.XX, handshake.IK, handshake.XK, handshake.NK, handshake.KK, handshake.N}
+
+// newPair returns initiator and responder states for the given pattern, providing every key the pattern needs.
+func newPair(t *testing.T, drbg *testdata.DRBG, pattern handshake.Pattern) (initiator, responder *handshake.State) {
+	t.Helper()
+
+	return newPairWith(t, drbg, pattern, func(*handshake.Config) {})
+}
+
+// newPairWith returns initiator and responder states for the given pattern, providing every key the pattern needs, and
+// calling configure on each party's configuration.
+func newPairWith(
+	t *testing.T, drbg *testdata.DRBG, pattern handshake.Pattern, configure func(config *handshake.Config),
+) (initiator, responder *handshake.State) {
+	t.Helper()
+
+	dIS, qIS := drbg.KeyPair()
+	dRS, qRS := drbg.KeyPair()
+
+	initiatorConfig := &handshake.Config{
+		Pattern:         pattern,
+		Domain:          "example",
+		Initiator:       true,
+		StaticKey:       dIS,
+		RemoteStaticKey: qRS,
+		Rand:            drbg.Reader(),
+	}
+	configure(initiatorConfig)
+	initiator, err := handshake.New(initiatorConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responderConfig := &handshake.Config{
+		Pattern:         pattern,
+		Domain:          "example",
+		StaticKey:       dRS,
+		RemoteStaticKey: qIS,
+		Rand:            drbg.Reader(),
+	}
+	configure(responderConfig)
+	responder, err = handshake.New(responderConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return initiator, responder
+}
+
+// run performs a handshake, sending a distinct payload in each message and calling tamper on each message before it is
+// read, and returns the first error. Cleartext payloads are authenticated by later messages, so a mismatched payload is
+// only reported if the handshake otherwise succeeds.
+func run(initiator, responder *handshake.State, tamper func(i int, msg []byte)) error {
+	var mismatch error
+	writer, reader := initiator, responder
+	for i := 0; !initiator.Complete(); i++ {
+		payload := fmt.Appendf(nil, "payload %d", i)
+		msg, err := writer.WriteMessage(nil, payload)
+		if err != nil {
+			return err
+		}
+
+		if got, want := len(msg), reader.Overhead()+len(payload); got != want {
+			return fmt.Errorf("len(msg %d) = %d, want = %d", i, got, want)
+		}
+
+		tamper(i, msg)
+
+		got, err := reader.ReadMessage(nil, msg)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(got, payload) && mismatch == nil {
+			mismatch = fmt.Errorf("payload %d = %q, want = %q", i, got, payload)
+		}
+		writer, reader = reader, writer
+	}
+	return mismatch
+}
+
+// runMismatched performs a handshake between parties which disagree about the size of each message, reading each
+// message as-is, and returns the first error.
+func runMismatched(initiator, responder *handshake.State) error {
+	writer, reader := initiator, responder
+	for !initiator.Complete() {
+		msg, err := writer.WriteMessage(nil, []byte("payload"))
+		if err != nil {
+			return err
+		}
+
+		if _, err := reader.ReadMessage(nil, msg); err != nil {
+			return err
+		}
+		writer, reader = reader, writer
+	}
+	return nil
+}
+
+func TestState(t *testing.T) {
+	for _, pattern := range patterns {
+		t.Run(pattern.String(), func(t *testing.T) {
+			drbg := testdata.New("newplex handshake " + pattern.String())
+
+			t.Run("successful round trip", func(t *testing.T) {
+				initiator, responder := newPair(t, drbg, pattern)
+
+				if err := run(initiator, responder, func(int, []byte) {}); err != nil {
+					t.Fatal(err)
+				}
+
+				if !responder.Complete() {
+					t.Fatal("responder is not complete")
+				}
+
+				iSend, iRecv, err := initiator.Split()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				rSend, rRecv, err := responder.Split()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got, want := iSend.Equal(rRecv), 1; got != want {
+					t.Errorf("iSend.Equal(rRecv) = %v, want %v", got, want)
+				}
+				if got, want := rSend.Equal(iRecv), 1; got != want {
+					t.Errorf("rSend.Equal(iRecv) = %v, want %v", got, want)
+				}
+				if got, want := iSend.Equal(iRecv), 0; got != want {
+					t.Errorf("iSend.Equal(iRecv) = %v, want %v", got, want)
+				}
+			})
+
+			t.Run("tampered messages", func(t *testing.T) {
+				for i := range pattern.Messages() {
+					for _, pos := range []string{"first", "last"} {
+						initiator, responder := newPair(t, drbg, pattern)
+						err := run(initiator, responder, func(j int, msg []byte) {
+							if i != j {
+								return
+							}
+							if pos == "first" {
+								msg[0] ^= 1
+							} else {
+								msg[len(msg)-1] ^= 1
+							}
+						})
+
+						if got, want := err, handshake.ErrInvalidHandshake; !errors.Is(got, want) {
+							t.Errorf("message %d, %s byte: err = %v, want = %v", i, pos, got, want)
+						}
+					}
+				}
+			})
+
+			t.Run("domain mismatch", func(t *testing.T) {
+				dIS, qIS := drbg.KeyPair()
+				dRS, qRS := drbg.KeyPair()
+
+				initiator, _ := handshake.New(&handshake.Config{
+					Pattern: pattern, Domain: "domain A", Initiator: true, StaticKey: dIS, RemoteStaticKey: qRS,
+					Rand: drbg.Reader(),
+				})
+				responder, _ := handshake.New(&handshake.Config{
+					Pattern: pattern, Domain: "domain B", StaticKey: dRS, RemoteStaticKey: qIS, Rand: drbg.Reader(),
+				})
+
+				if got, want := run(initiator, responder, func(int, []byte) {}), handshake.ErrInvalidHandshake; !errors.Is(got, want) {
+					t.Errorf("err = %v, want = %v", got, want)
+				}
+			})
+
+			t.Run("out of order", func(t *testing.T) {
+				initiator, responder := newPair(t, drbg, pattern)
+
+				if _, err := responder.WriteMessage(nil, nil); !errors.Is(err, handshake.ErrOutOfOrder) {
+					t.Errorf("responder.WriteMessage() err = %v, want = %v", err, handshake.ErrOutOfOrder)
+				}
+
+				if _, err := initiator.ReadMessage(nil, nil); !errors.Is(err, handshake.ErrOutOfOrder) {
+					t.Errorf("initiator.ReadMessage() err = %v, want = %v", err, handshake.ErrOutOfOrder)
+				}
+
+				if _, _, err := initiator.Split(); !errors.Is(err, handshake.ErrOutOfOrder) {
+					t.Errorf("initiator.Split() err = %v, want = %v", err, handshake.ErrOutOfOrder)
+				}
+			})
+
+			t.Run("rand failure", func(t *testing.T) {
+				dIS, _ := drbg.KeyPair()
+				_, qRS := drbg.KeyPair()
+				initiator, _ := handshake.New(&handshake.Config{
+					Pattern: pattern, Domain: "example", Initiator: true, StaticKey: dIS, RemoteStaticKey: qRS,
+					Rand: &testdata.ErrReader{Err: errors.New("oh no")},
+				})
+
+				if _, err := initiator.WriteMessage(nil, nil); err == nil {
+					t.Error("expected error from rand failure, got nil")
+				}
+			})
+		})
+	}
+}
+
+func TestState_RemoteStaticKey(t *testing.T) {
+	for _, pattern := range patterns {
+		t.Run(pattern.String(), func(t *testing.T) {
+			drbg := testdata.New("newplex handshake " + pattern.String())
+			dIS, qIS := drbg.KeyPair()
+			dRS, qRS := drbg.KeyPair()
+
+			initiator, _ := handshake.New(&handshake.Config{
+				Pattern: pattern, Domain: "example", Initiator: true, StaticKey: dIS, RemoteStaticKey: qRS,
+				Rand: drbg.Reader(),
+			})
+			responder, _ := handshake.New(&handshake.Config{
+				Pattern: pattern, Domain: "example", StaticKey: dRS, RemoteStaticKey: qIS, Rand: drbg.Reader(),
+			})
+
+			if err := run(initiator, responder, func(int, []byte) {}); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := initiator.RemoteStaticKey(); got == nil || got.Equal(qRS) != 1 {
+				t.Errorf("initiator.RemoteStaticKey() = %v, want = %v", got, qRS)
+			}
+
+			got := responder.RemoteStaticKey()
+			switch pattern {
+			case handshake.NK, handshake.N:
+				if got != nil {
+					t.Errorf("responder.RemoteStaticKey() = %v, want = nil", got)
+				}
+			default:
+				if got == nil || got.Equal(qIS) != 1 {
+					t.Errorf("responder.RemoteStaticKey() = %v, want = %v", got, qIS)
+				}
+			}
+		})
+	}
+}
+
+func TestState_wrongRemoteStaticKey(t *testing.T) {
+	for _, pattern := range []handshake.Pattern{handshake.IK, handshake.XK, handshake.NK, handshake.KK, handshake.N} {
+		t.Run(pattern.String(), func(t *testing.T) {
+			drbg := testdata.New("newplex handshake " + pattern.String())
+			dIS, qIS := drbg.KeyPair()
+			dRS, _ := drbg.KeyPair()
+			_, qX := drbg.KeyPair()
+
+			initiator, _ := handshake.New(&handshake.Config{
+				Pattern: pattern, Domain: "example", Initiator: true, StaticKey: dIS, RemoteStaticKey: qX,
+				Rand: drbg.Reader(),
+			})
+			responder, _ := handshake.New(&handshake.Config{
+				Pattern: pattern, Domain: "example", StaticKey: dRS, RemoteStaticKey: qIS, Rand: drbg.Reader(),
+			})
+
+			if got, want := run(initiator, responder, func(int, []byte) {}), handshake.ErrInvalidHandshake; !errors.Is(got, want) {
+				t.Errorf("err = %v, want = %v", got, want)
+			}
+		})
+	}
+}
+
+func TestState_PayloadEncrypted(t *testing.T) {
+	for _, tc := range []struct {
+		pattern   handshake.Pattern
+		encrypted []bool
+	}{
+		{handshake.XX, []bool{false, true, true}},
+		{handshake.IK, []bool{true, true}},
+		{handshake.XK, []bool{true, true, true}},
+		{handshake.NK, []bool{true, true}},
+		{handshake.KK, []bool{true, true}},
+		{handshake.N, []bool{true}},
+	} {
+		t.Run(tc.pattern.String(), func(t *testing.T) {
+			drbg := testdata.New("newplex handshake " + tc.pattern.String())
+			initiator, responder := newPair(t, drbg, tc.pattern)
+
+			writer, reader := initiator, responder
+			for i, want := range tc.encrypted {
+				if got := writer.PayloadEncrypted(); got != want {
+					t.Errorf("message %d: writer.PayloadEncrypted() = %v, want = %v", i, got, want)
+				}
+
+				if got := reader.PayloadEncrypted(); got != want {
+					t.Errorf("message %d: reader.PayloadEncrypted() = %v, want = %v", i, got, want)
+				}
+
+				secret := []byte("secret payload")
+				msg, err := writer.WriteMessage(nil, secret)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got := bytes.Contains(msg, secret); got == want {
+					t.Errorf("message %d: contains plaintext payload = %v, want = %v", i, got, !want)
+				}
+
+				if _, err := reader.ReadMessage(nil, msg); err != nil {
+					t.Fatal(err)
+				}
+				writer, reader = reader, writer
+			}
+
+			if initiator.PayloadEncrypted() || responder.PayloadEncrypted() {
+				t.Error("PayloadEncrypted() = true after the handshake is complete")
+			}
+		})
+	}
+}
+
+func TestState_PreSharedKey(t *testing.T) {
+	for _, pattern := range patterns {
+		t.Run(pattern.String(), func(t *testing.T) {
+			drbg := testdata.New("newplex handshake psk " + pattern.String())
+			psk := drbg.Data(handshake.PreSharedKeySize)
+
+			t.Run("successful round trip", func(t *testing.T) {
+				initiator, responder := newPairWith(t, drbg, pattern, func(config *handshake.Config) {
+					config.PreSharedKey = psk
+				})
+
+				writer, reader := initiator, responder
+				for i := range pattern.Messages() {
+					if !writer.PayloadEncrypted() {
+						t.Errorf("message %d: PayloadEncrypted() = false, want = true", i)
+					}
+
+					secret := []byte("secret payload")
+					msg, err := writer.WriteMessage(nil, secret)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if bytes.Contains(msg, secret) {
+						t.Errorf("message %d contains plaintext payload", i)
+					}
+
+					if got, want := len(msg), reader.Overhead()+len(secret); got != want {
+						t.Errorf("len(msg %d) = %d, want = %d", i, got, want)
+					}
+
+					got, err := reader.ReadMessage(nil, msg)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if !bytes.Equal(got, secret) {
+						t.Errorf("payload %d = %q, want = %q", i, got, secret)
+					}
+					writer, reader = reader, writer
+				}
+
+				iSend, _, err := initiator.Split()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				_, rRecv, err := responder.Split()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got, want := iSend.Equal(rRecv), 1; got != want {
+					t.Errorf("iSend.Equal(rRecv) = %v, want %v", got, want)
+				}
+			})
+
+			t.Run("pre-shared key mismatch", func(t *testing.T) {
+				other := drbg.Data(handshake.PreSharedKeySize)
+				initiator, responder := newPairWith(t, drbg, pattern, func(config *handshake.Config) {
+					config.PreSharedKey = psk
+					if config.Initiator {
+						config.PreSharedKey = other
+					}
+				})
+
+				if got, want := run(initiator, responder, func(int, []byte) {}), handshake.ErrInvalidHandshake; !errors.Is(got, want) {
+					t.Errorf("err = %v, want = %v", got, want)
+				}
+			})
+
+			t.Run("missing pre-shared key", func(t *testing.T) {
+				initiator, responder := newPairWith(t, drbg, pattern, func(config *handshake.Config) {
+					if !config.Initiator {
+						config.PreSharedKey = psk
+					}
+				})
+
+				if got, want := runMismatched(initiator, responder), handshake.ErrInvalidHandshake; !errors.Is(got, want) {
+					t.Errorf("err = %v, want = %v", got, want)
+				}
+			})
+		})
+	}
+
+	t.Run("invalid size", func(t *testing.T) {
+		drbg := testdata.New("newplex handshake psk")
+		_, q := drbg.KeyPair()
+
+		for _, size := range []int{0, 16, handshake.PreSharedKeySize + 1} {
+			_, err := handshake.New(&handshake.Config{
+				Pattern:         handshake.NK,
+				Domain:          "example",
+				Initiator:       true,
+				RemoteStaticKey: q,
+				PreSharedKey:    make([]byte, size),
+				Rand:            drbg.Reader(),
+			})
+			if got, want := err, handshake.ErrInvalidPreSharedKey; !errors.Is(got, want) {
+				t.Errorf("New(%d-byte pre-shared key) err = %v, want = %v", size, got, want)
+			}
+		}
+	})
+}
+
+func TestState_Hybrid(t *testing.T) {
+	for _, pattern := range patterns {
+		t.Run(pattern.String(), func(t *testing.T) {
+			drbg := testdata.New("newplex handshake hybrid " + pattern.String())
+
+			if pattern.OneWay() {
+				_, q := drbg.KeyPair()
+				_, err := handshake.New(&handshake.Config{
+					Pattern: pattern, Domain: "example", Initiator: true, RemoteStaticKey: q, Hybrid: true,
+					Rand: drbg.Reader(),
+				})
+				if got, want := err, handshake.ErrHybridOneWay; !errors.Is(got, want) {
+					t.Errorf("New() err = %v, want = %v", got, want)
+				}
+				return
+			}
+
+			for _, psk := range []bool{false, true} {
+				hybrid := func(config *handshake.Config) {
+					config.Hybrid = true
+					if psk {
+						config.PreSharedKey = make([]byte, handshake.PreSharedKeySize)
+					}
+				}
+
+				t.Run(fmt.Sprintf("psk=%v", psk), func(t *testing.T) {
+					t.Run("successful round trip", func(t *testing.T) {
+						initiator, responder := newPairWith(t, drbg, pattern, hybrid)
+
+						if got, want := initiator.Overhead(), handshake.KEMEncapsulationKeySize; got < want {
+							t.Errorf("Overhead() = %d, want >= %d", got, want)
+						}
+
+						if err := run(initiator, responder, func(int, []byte) {}); err != nil {
+							t.Fatal(err)
+						}
+
+						iSend, iRecv, err := initiator.Split()
+						if err != nil {
+							t.Fatal(err)
+						}
+
+						rSend, rRecv, err := responder.Split()
+						if err != nil {
+							t.Fatal(err)
+						}
+
+						if got, want := iSend.Equal(rRecv), 1; got != want {
+							t.Errorf("iSend.Equal(rRecv) = %v, want %v", got, want)
+						}
+						if got, want := rSend.Equal(iRecv), 1; got != want {
+							t.Errorf("rSend.Equal(iRecv) = %v, want %v", got, want)
+						}
+					})
+
+					t.Run("tampered messages", func(t *testing.T) {
+						for i := range pattern.Messages() {
+							for _, pos := range []int{0, 40, -1} {
+								initiator, responder := newPairWith(t, drbg, pattern, hybrid)
+								err := run(initiator, responder, func(j int, msg []byte) {
+									if i != j {
+										return
+									}
+									msg[(pos+len(msg))%len(msg)] ^= 1
+								})
+
+								if got, want := err, handshake.ErrInvalidHandshake; !errors.Is(got, want) {
+									t.Errorf("message %d, byte %d: err = %v, want = %v", i, pos, got, want)
+								}
+							}
+						}
+					})
+				})
+			}
+
+			t.Run("hybrid mismatch", func(t *testing.T) {
+				initiator, responder := newPairWith(t, drbg, pattern, func(config *handshake.Config) {
+					config.Hybrid = config.Initiator
+				})
+
+				if got, want := runMismatched(initiator, responder), handshake.ErrInvalidHandshake; !errors.Is(got, want) {
+					t.Errorf("err = %v, want = %v", got, want)
+				}
+			})
+		})
+	}
+}
+
+func TestState_VerifyPeer(t *testing.T) {
+	for _, tc := range []struct {
+		pattern handshake.Pattern
+		msg     int  // The index of the message in which the responder receives a static key.
+		byInit  bool // Whether the initiator receives a static key.
+		byResp  bool // Whether the responder receives a static key.
+	}{
+		{handshake.XX, 2, true, true},
+		{handshake.IK, 0, false, true},
+		{handshake.XK, 2, false, true},
+		{handshake.NK, 0, false, false},
+		{handshake.KK, 0, false, false},
+	} {
+		t.Run(tc.pattern.String(), func(t *testing.T) {
+			drbg := testdata.New("newplex handshake verify " + tc.pattern.String())
+
+			t.Run("accepted", func(t *testing.T) {
+				var initCalls, respCalls int
+				initiator, responder := newPairWith(t, drbg, tc.pattern, func(config *handshake.Config) {
+					config.VerifyPeer = func(q *ristretto255.Element) error {
+						if q.Equal(config.RemoteStaticKey) != 1 {
+							return errors.New("unexpected key")
+						}
+						if config.Initiator {
+							initCalls++
+						} else {
+							respCalls++
+						}
+						return nil
+					}
+				})
+
+				if err := run(initiator, responder, func(int, []byte) {}); err != nil {
+					t.Fatal(err)
+				}
+
+				if got, want := initCalls == 1, tc.byInit; got != want {
+					t.Errorf("initiator VerifyPeer calls = %d, want one = %v", initCalls, want)
+				}
+				if got, want := respCalls == 1, tc.byResp; got != want {
+					t.Errorf("responder VerifyPeer calls = %d, want one = %v", respCalls, want)
+				}
+			})
+
+			if !tc.byResp {
+				return
+			}
+
+			t.Run("rejected", func(t *testing.T) {
+				errRejected := errors.New("rejected")
+				initiator, responder := newPairWith(t, drbg, tc.pattern, func(config *handshake.Config) {
+					if !config.Initiator {
+						config.VerifyPeer = func(*ristretto255.Element) error { return errRejected }
+					}
+				})
+
+				writer, reader := initiator, responder
+				for i := range tc.msg + 1 {
+					msg, err := writer.WriteMessage(nil, nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					_, err = reader.ReadMessage(nil, msg)
+					if i < tc.msg && err != nil {
+						t.Fatal(err)
+					} else if i == tc.msg && !errors.Is(err, errRejected) {
+						t.Errorf("message %d: ReadMessage() err = %v, want = %v", i, err, errRejected)
+					}
+					writer, reader = reader, writer
+				}
+
+				// The failed handshake cannot be continued.
+				if _, _, err := responder.Split(); !errors.Is(err, errRejected) {
+					t.Errorf("Split() err = %v, want = %v", err, errRejected)
+				}
+			})
+		})
+	}
+}
+
+func ExampleAllowlist() {
+	drbg := testdata.New("newplex handshake allowlist example")
+	dI, qI := drbg.KeyPair()
+	dR, qR := drbg.KeyPair()
+
+	// The responder only allows initiators whose static keys are in its allowlist.
+	allowlist, err := handshake.ReadAllowlist(strings.NewReader("# allowed initiators\n" + hex.EncodeToString(qI.Bytes())))
+	if err != nil {
+		panic(err)
+	}
+
+	initiator, err := handshake.New(&handshake.Config{
+		Pattern:         handshake.IK,
+		Domain:          "example",
+		Initiator:       true,
+		StaticKey:       dI,
+		RemoteStaticKey: qR,
+		Rand:            drbg.Reader(),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	responder, err := handshake.New(&handshake.Config{
+		Pattern:    handshake.IK,
+		Domain:     "example",
+		StaticKey:  dR,
+		VerifyPeer: allowlist.Verify,
+		Rand:       drbg.Reader(),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	msg, err := initiator.WriteMessage(nil, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := responder.ReadMessage(nil, msg); err != nil {
+		panic(err)
+	}
+
+	fmt.Println(responder.RemoteStaticKey().Equal(qI) == 1)
+	// Output:
+	// true
+}
+
+func TestState_ReadMessage(t *testing.T) {
+	drbg := testdata.New("newplex handshake")
+
+	t.Run("short message", func(t *testing.T) {
+		initiator, responder := newPair(t, drbg, handshake.IK)
+		msg, _ := initiator.WriteMessage(nil, nil)
+
+		if _, err := responder.ReadMessage(nil, msg[:len(msg)-1]); !errors.Is(err, handshake.ErrInvalidHandshake) {
+			t.Errorf("ReadMessage() err = %v, want = %v", err, handshake.ErrInvalidHandshake)
+		}
+
+		// The failed handshake cannot be continued.
+		if _, err := responder.WriteMessage(nil, nil); !errors.Is(err, handshake.ErrInvalidHandshake) {
+			t.Errorf("WriteMessage() err = %v, want = %v", err, handshake.ErrInvalidHandshake)
+		}
+	})
+
+	t.Run("appends to dst", func(t *testing.T) {
+		initiator, responder := newPair(t, drbg, handshake.NK)
+		msg, _ := initiator.WriteMessage([]byte("header"), []byte("payload"))
+
+		got, err := responder.ReadMessage([]byte("prefix "), msg[len("header"):])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := string(got), "prefix payload"; got != want {
+			t.Errorf("ReadMessage() = %q, want = %q", got, want)
+		}
+	})
+}
+
+func TestNew(t *testing.T) {
+	drbg := testdata.New("newplex handshake")
+	d, q := drbg.KeyPair()
+
+	for _, tc := range []struct {
+		pattern   handshake.Pattern
+		initiator bool
+		staticKey *ristretto255.Scalar
+		remoteKey *ristretto255.Element
+		err       error
+	}{
+		{handshake.XX, true, nil, nil, handshake.ErrMissingKey},
+		{handshake.XX, false, nil, nil, handshake.ErrMissingKey},
+		{handshake.XX, true, d, nil, nil},
+		{handshake.IK, true, d, nil, handshake.ErrMissingKey},
+		{handshake.IK, true, nil, q, handshake.ErrMissingKey},
+		{handshake.IK, false, d, nil, nil},
+		{handshake.NK, true, nil, q, nil},
+		{handshake.NK, false, nil, nil, handshake.ErrMissingKey},
+		{handshake.KK, false, d, nil, handshake.ErrMissingKey},
+		{handshake.KK, false, d, q, nil},
+		{handshake.N, true, nil, q, nil},
+		{handshake.N, true, nil, nil, handshake.ErrMissingKey},
+	} {
+		t.Run(fmt.Sprintf("%s/%v/%v/%v", tc.pattern, tc.initiator, tc.staticKey != nil, tc.remoteKey != nil), func(t *testing.T) {
+			_, err := handshake.New(&handshake.Config{
+				Pattern:         tc.pattern,
+				Domain:          "example",
+				Initiator:       tc.initiator,
+				StaticKey:       tc.staticKey,
+				RemoteStaticKey: tc.remoteKey,
+				Rand:            drbg.Reader(),
+			})
+			if got, want := err, tc.err; !errors.Is(got, want) {
+				t.Errorf("New() err = %v, want = %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern  handshake.Pattern
+		name     string
+		messages int
+		oneWay   bool
+	}{
+		{handshake.XX, "XX", 3, false},
+		{handshake.IK, "IK", 2, false},
+		{handshake.XK, "XK", 3, false},
+		{handshake.NK, "NK", 2, false},
+		{handshake.KK, "KK", 2, false},
+		{handshake.N, "N", 1, true},
+		{handshake.Resume, "Resume", 2, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := tc.pattern.String(), tc.name; got != want {
+				t.Errorf("String() = %q, want = %q", got, want)
+			}
+
+			if got, want := tc.pattern.Messages(), tc.messages; got != want {
+				t.Errorf("Messages() = %d, want = %d", got, want)
+			}
+
+			if got, want := tc.pattern.OneWay(), tc.oneWay; got != want {
+				t.Errorf("OneWay() = %v, want = %v", got, want)
+			}
+		})
+	}
+
+	if got, want := handshake.Pattern(99).String(), "Pattern(99)"; got != want {
+		t.Errorf("String() = %q, want = %q", got, want)
+	}
+}
+
+func ExampleState() {
+	drbg := testdata.New("newplex handshake example")
+	dIS, _ := drbg.KeyPair()
+	dRS, qRS := drbg.KeyPair()
+
+	// The initiator knows the responder's static public key in advance, so it uses IK for a single round trip.
+	initiator, err := handshake.New(&handshake.Config{
+		Pattern:         handshake.IK,
+		Domain:          "example",
+		Initiator:       true,
+		StaticKey:       dIS,
+		RemoteStaticKey: qRS,
+		Rand:            drbg.Reader(),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	responder, err := handshake.New(&handshake.Config{
+		Pattern:   handshake.IK,
+		Domain:    "example",
+		StaticKey: dRS,
+		Rand:      drbg.Reader(),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// The initiator sends the first message with early data, which is encrypted to the responder's static key.
+	msg, err := initiator.WriteMessage(nil, []byte("hello"))
+	if err != nil {
+		panic(err)
+	}
+	payload, err := responder.ReadMessage(nil, msg)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("early data: %s\n", payload)
+
+	// The responder replies with a forward-secret payload, completing the handshake.
+	msg, err = responder.WriteMessage(nil, []byte("hi"))
+	if err != nil {
+		panic(err)
+	}
+	payload, err = initiator.ReadMessage(nil, msg)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("response: %s\n", payload)
+
+	// Both parties split the handshake into protocols for sending and receiving.
+	iSend, _, err := initiator.Split()
+	if err != nil {
+		panic(err)
+	}
+	_, rRecv, err := responder.Split()
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("initiator send: %x\n", iSend.Derive("test", nil, 16))
+	fmt.Printf("responder recv: %x\n", rRecv.Derive("test", nil, 16))
+
+	// Output:
+	// early data: hello
+	// response: hi
+	// initiator send: 738cba9a6d4e84ce72c56613884a617b
+	// responder recv: 738cba9a6d4e84ce72c56613884a617b
+}
@@ -0,0 +1,117 @@
+package handshake
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/siv"
+	"github.com/gtank/ristretto255"
+)
+
+const (
+	// TicketKeySize is the size, in bytes, of a ticket key.
+	TicketKeySize = 32
+
+	// TicketSize is the size, in bytes, of a resumption ticket.
+	TicketSize = ticketNonceSize + ResumptionSecretSize + 32 + 8 + newplex.TagSize
+
+	// ResumptionSecretSize is the size, in bytes, of a resumption secret.
+	ResumptionSecretSize = 32
+)
+
+// ErrInvalidTicket is returned when a resumption ticket cannot be opened, has expired, or is the wrong size.
+var ErrInvalidTicket = errors.New("newplex/handshake: invalid ticket")
+
+// A Session is what an initiator needs to resume a previous session with the Resume pattern: the ticket issued by the
+// responder, the resumption secret of the previous session, and the responder's static public key, if it was
+// authenticated by the previous session.
+//
+// The resumption secret must be kept as confidential as a private key. Each ticket should be used only once, since a
+// ticket is sent in the clear and would otherwise allow passive attackers to link sessions.
+type Session struct {
+	// Ticket is the opaque ticket issued by the responder.
+	Ticket []byte
+
+	// Secret is the resumption secret of the previous session.
+	Secret []byte
+
+	// RemoteStaticKey is the responder's static public key, or nil if the previous session did not authenticate it.
+	RemoteStaticKey *ristretto255.Element
+}
+
+// A TicketKey is a secret key held by a responder, with which it seals resumption tickets for initiators and opens
+// them when they resume their sessions. Tickets contain the resumption secret of the previous session, the
+// initiator's static public key (if any), and an expiration time.
+//
+// An attacker who compromises a ticket key can impersonate either party in a resumed session and decrypt the first
+// payload of any resumption handshake using a ticket it sealed, but cannot decrypt the rest of those sessions, which
+// are protected by an ephemeral Diffie-Hellman exchange. Ticket keys should be rotated regularly.
+type TicketKey struct {
+	aead     cipher.AEAD
+	lifetime time.Duration
+}
+
+// NewTicketKey returns a TicketKey which seals tickets with the given key and rejects them after the given lifetime.
+//
+// Panics if the key is not TicketKeySize bytes long.
+func NewTicketKey(key []byte, lifetime time.Duration) *TicketKey {
+	if len(key) != TicketKeySize {
+		panic("newplex/handshake: ticket key must be 32 bytes")
+	}
+	return &TicketKey{
+		aead:     siv.New("newplex.handshake.ticket", key, ticketNonceSize),
+		lifetime: lifetime,
+	}
+}
+
+// seal returns a new ticket for the given domain, resumption secret, and initiator static key, which may be nil.
+func (tk *TicketKey) seal(rand io.Reader, domain string, secret []byte, q *ristretto255.Element) ([]byte, error) {
+	ticket := make([]byte, ticketNonceSize, TicketSize)
+	if _, err := io.ReadFull(rand, ticket); err != nil {
+		return nil, err
+	}
+
+	plaintext := slices.Clone(secret)
+	if q != nil {
+		plaintext = append(plaintext, q.Bytes()...)
+	} else {
+		plaintext = append(plaintext, make([]byte, 32)...)
+	}
+	plaintext = binary.BigEndian.AppendUint64(plaintext, uint64(time.Now().Add(tk.lifetime).UnixNano()))
+
+	return tk.aead.Seal(ticket, ticket, plaintext, []byte(domain)), nil
+}
+
+// open returns the resumption secret and initiator static key, which may be nil, of the given ticket for the given
+// domain. Returns ErrInvalidTicket if the ticket is invalid or has expired.
+func (tk *TicketKey) open(domain string, ticket []byte) (secret []byte, q *ristretto255.Element, err error) {
+	if len(ticket) != TicketSize {
+		return nil, nil, ErrInvalidTicket
+	}
+
+	nonce, ciphertext := ticket[:ticketNonceSize], ticket[ticketNonceSize:]
+	plaintext, err := tk.aead.Open(nil, nonce, ciphertext, []byte(domain))
+	if err != nil {
+		return nil, nil, ErrInvalidTicket
+	}
+
+	secret, qb, expiry := plaintext[:ResumptionSecretSize], plaintext[ResumptionSecretSize:ResumptionSecretSize+32],
+		plaintext[ResumptionSecretSize+32:]
+	if time.Now().After(time.Unix(0, int64(binary.BigEndian.Uint64(expiry)))) {
+		return nil, nil, ErrInvalidTicket
+	}
+
+	// An all-zero key (i.e., the encoding of the identity element) indicates the initiator had no static key.
+	if q, _ = ristretto255.NewIdentityElement().SetCanonicalBytes(qb); q != nil &&
+		q.Equal(ristretto255.NewIdentityElement()) == 1 {
+		q = nil
+	}
+	return secret, q, nil
+}
+
+const ticketNonceSize = 16
@@ -0,0 +1,319 @@
+package handshake_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codahale/newplex/handshake"
+	"github.com/codahale/newplex/internal/testdata"
+	"github.com/gtank/ristretto255"
+)
+
+// complete performs a handshake between the given parties and fails the test if it returns an error.
+func complete(t *testing.T, initiator, responder *handshake.State) {
+	t.Helper()
+
+	if err := run(initiator, responder, func(int, []byte) {}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// resumePair returns initiator and responder states for resuming the session established by the given parties, using a
+// ticket issued by the responder with the given ticket key, and calling configure on each party's configuration.
+func resumePair(
+	t *testing.T, drbg *testdata.DRBG, initiator, responder *handshake.State, tk *handshake.TicketKey,
+	configure func(config *handshake.Config),
+) (*handshake.State, *handshake.State) {
+	t.Helper()
+
+	ticket, err := responder.IssueTicket(tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := len(ticket), handshake.TicketSize; got != want {
+		t.Errorf("len(ticket) = %d, want = %d", got, want)
+	}
+
+	session, err := initiator.Session(ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	initiatorConfig := &handshake.Config{
+		Pattern:   handshake.Resume,
+		Domain:    "example",
+		Initiator: true,
+		Session:   session,
+		Rand:      drbg.Reader(),
+	}
+	configure(initiatorConfig)
+	resumedInitiator, err := handshake.New(initiatorConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responderConfig := &handshake.Config{
+		Pattern:   handshake.Resume,
+		Domain:    "example",
+		TicketKey: tk,
+		Rand:      drbg.Reader(),
+	}
+	configure(responderConfig)
+	resumedResponder, err := handshake.New(responderConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resumedInitiator, resumedResponder
+}
+
+func TestState_Resume(t *testing.T) {
+	drbg := testdata.New("newplex handshake resume")
+	tk := handshake.NewTicketKey(drbg.Data(handshake.TicketKeySize), time.Hour)
+
+	t.Run("successful round trip", func(t *testing.T) {
+		initiator, responder := newPair(t, drbg, handshake.XX)
+		complete(t, initiator, responder)
+		resumedInitiator, resumedResponder := resumePair(t, drbg, initiator, responder, tk,
+			func(*handshake.Config) {})
+
+		if !resumedInitiator.PayloadEncrypted() {
+			t.Error("PayloadEncrypted() = false, want = true")
+		}
+
+		msg, err := resumedInitiator.WriteMessage(nil, []byte("early data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if bytes.Contains(msg, []byte("early data")) {
+			t.Error("message contains plaintext payload")
+		}
+
+		if _, err := resumedResponder.ReadMessage(nil, msg); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := run(resumedResponder, resumedInitiator, func(int, []byte) {}); err != nil {
+			t.Fatal(err)
+		}
+
+		iSend, iRecv, err := resumedInitiator.Split()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rSend, rRecv, err := resumedResponder.Split()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := iSend.Equal(rRecv), 1; got != want {
+			t.Errorf("iSend.Equal(rRecv) = %v, want %v", got, want)
+		}
+
+		if got, want := rSend.Equal(iRecv), 1; got != want {
+			t.Errorf("rSend.Equal(iRecv) = %v, want %v", got, want)
+		}
+
+		assertRemoteStaticKey(t, "initiator", resumedInitiator, initiator.RemoteStaticKey())
+		assertRemoteStaticKey(t, "responder", resumedResponder, responder.RemoteStaticKey())
+	})
+
+	t.Run("chained resumption", func(t *testing.T) {
+		initiator, responder := newPair(t, drbg, handshake.XX)
+		complete(t, initiator, responder)
+
+		for range 3 {
+			resumedInitiator, resumedResponder := resumePair(t, drbg, initiator, responder, tk,
+				func(*handshake.Config) {})
+			complete(t, resumedInitiator, resumedResponder)
+
+			secretA, err := resumedInitiator.ResumptionSecret()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			secretB, err := resumedResponder.ResumptionSecret()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(secretA, secretB) {
+				t.Errorf("ResumptionSecret() = %x/%x, want equal", secretA, secretB)
+			}
+
+			assertRemoteStaticKey(t, "responder", resumedResponder, responder.RemoteStaticKey())
+			initiator, responder = resumedInitiator, resumedResponder
+		}
+	})
+
+	t.Run("anonymous initiator", func(t *testing.T) {
+		initiator, responder := newPair(t, drbg, handshake.NK)
+		complete(t, initiator, responder)
+		resumedInitiator, resumedResponder := resumePair(t, drbg, initiator, responder, tk,
+			func(*handshake.Config) {})
+		complete(t, resumedInitiator, resumedResponder)
+
+		if got := resumedResponder.RemoteStaticKey(); got != nil {
+			t.Errorf("RemoteStaticKey() = %v, want = nil", got)
+		}
+
+		assertRemoteStaticKey(t, "initiator", resumedInitiator, initiator.RemoteStaticKey())
+	})
+
+	t.Run("hybrid", func(t *testing.T) {
+		initiator, responder := newPair(t, drbg, handshake.XX)
+		complete(t, initiator, responder)
+		resumedInitiator, resumedResponder := resumePair(t, drbg, initiator, responder, tk,
+			func(config *handshake.Config) { config.Hybrid = true })
+		complete(t, resumedInitiator, resumedResponder)
+	})
+
+	t.Run("expired ticket", func(t *testing.T) {
+		expired := handshake.NewTicketKey(drbg.Data(handshake.TicketKeySize), -time.Second)
+		initiator, responder := newPair(t, drbg, handshake.XX)
+		complete(t, initiator, responder)
+		resumedInitiator, resumedResponder := resumePair(t, drbg, initiator, responder, expired,
+			func(*handshake.Config) {})
+
+		if got, want := run(resumedInitiator, resumedResponder, func(int, []byte) {}), handshake.ErrInvalidTicket; !errors.Is(got, want) {
+			t.Errorf("err = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("wrong ticket key", func(t *testing.T) {
+		other := handshake.NewTicketKey(drbg.Data(handshake.TicketKeySize), time.Hour)
+		initiator, responder := newPair(t, drbg, handshake.XX)
+		complete(t, initiator, responder)
+		resumedInitiator, resumedResponder := resumePair(t, drbg, initiator, responder, tk,
+			func(config *handshake.Config) {
+				if !config.Initiator {
+					config.TicketKey = other
+				}
+			})
+
+		if got, want := run(resumedInitiator, resumedResponder, func(int, []byte) {}), handshake.ErrInvalidTicket; !errors.Is(got, want) {
+			t.Errorf("err = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("wrong domain", func(t *testing.T) {
+		initiator, responder := newPair(t, drbg, handshake.XX)
+		complete(t, initiator, responder)
+		resumedInitiator, resumedResponder := resumePair(t, drbg, initiator, responder, tk,
+			func(config *handshake.Config) { config.Domain = "other" })
+
+		if got, want := run(resumedInitiator, resumedResponder, func(int, []byte) {}), handshake.ErrInvalidTicket; !errors.Is(got, want) {
+			t.Errorf("err = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("modified ticket", func(t *testing.T) {
+		initiator, responder := newPair(t, drbg, handshake.XX)
+		complete(t, initiator, responder)
+		resumedInitiator, resumedResponder := resumePair(t, drbg, initiator, responder, tk,
+			func(*handshake.Config) {})
+
+		if got, want := run(resumedInitiator, resumedResponder, func(i int, msg []byte) {
+			if i == 0 {
+				msg[20] ^= 1
+			}
+		}), handshake.ErrInvalidTicket; !errors.Is(got, want) {
+			t.Errorf("err = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("wrong resumption secret", func(t *testing.T) {
+		initiator, responder := newPair(t, drbg, handshake.XX)
+		complete(t, initiator, responder)
+		resumedInitiator, resumedResponder := resumePair(t, drbg, initiator, responder, tk,
+			func(config *handshake.Config) {
+				if config.Initiator {
+					config.Session.Secret = drbg.Data(handshake.ResumptionSecretSize)
+				}
+			})
+
+		if got, want := run(resumedInitiator, resumedResponder, func(int, []byte) {}), handshake.ErrInvalidHandshake; !errors.Is(got, want) {
+			t.Errorf("err = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("rejected peer", func(t *testing.T) {
+		initiator, responder := newPair(t, drbg, handshake.XX)
+		complete(t, initiator, responder)
+		resumedInitiator, resumedResponder := resumePair(t, drbg, initiator, responder, tk,
+			func(config *handshake.Config) { config.VerifyPeer = handshake.NewAllowlist().Verify })
+
+		if got, want := run(resumedInitiator, resumedResponder, func(int, []byte) {}), handshake.ErrUnknownPeer; !errors.Is(got, want) {
+			t.Errorf("err = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("incomplete handshake", func(t *testing.T) {
+		initiator, _ := newPair(t, drbg, handshake.XX)
+
+		if _, err := initiator.ResumptionSecret(); !errors.Is(err, handshake.ErrOutOfOrder) {
+			t.Errorf("ResumptionSecret() err = %v, want = %v", err, handshake.ErrOutOfOrder)
+		}
+
+		if _, err := initiator.IssueTicket(tk); !errors.Is(err, handshake.ErrOutOfOrder) {
+			t.Errorf("IssueTicket() err = %v, want = %v", err, handshake.ErrOutOfOrder)
+		}
+
+		if _, err := initiator.Session(make([]byte, handshake.TicketSize)); !errors.Is(err, handshake.ErrOutOfOrder) {
+			t.Errorf("Session() err = %v, want = %v", err, handshake.ErrOutOfOrder)
+		}
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			config *handshake.Config
+			err    error
+		}{
+			"missing session": {
+				&handshake.Config{Pattern: handshake.Resume, Initiator: true},
+				handshake.ErrMissingKey,
+			},
+			"missing ticket key": {
+				&handshake.Config{Pattern: handshake.Resume},
+				handshake.ErrMissingKey,
+			},
+			"invalid ticket": {
+				&handshake.Config{Pattern: handshake.Resume, Initiator: true, Session: &handshake.Session{
+					Ticket: make([]byte, 10),
+					Secret: make([]byte, handshake.ResumptionSecretSize),
+				}},
+				handshake.ErrInvalidTicket,
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				if _, err := handshake.New(tc.config); !errors.Is(err, tc.err) {
+					t.Errorf("New() err = %v, want = %v", err, tc.err)
+				}
+			})
+		}
+	})
+}
+
+func TestNewTicketKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewTicketKey() did not panic")
+		}
+	}()
+
+	handshake.NewTicketKey(make([]byte, 16), time.Hour)
+}
+
+// assertRemoteStaticKey checks that the given party's remote static key is the given key.
+func assertRemoteStaticKey(t *testing.T, name string, hs *handshake.State, want *ristretto255.Element) {
+	t.Helper()
+
+	if got := hs.RemoteStaticKey(); got == nil || want == nil || got.Equal(want) != 1 {
+		t.Errorf("%s.RemoteStaticKey() = %v, want = %v", name, got, want)
+	}
+}
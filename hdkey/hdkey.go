@@ -0,0 +1,286 @@
+// Package hdkey implements hierarchical deterministic key derivation for Ristretto255 keys using Newplex.
+//
+// A master key is derived from a secret seed, and child keys are derived from their parents along labeled paths. This
+// allows a single backed-up seed to produce independent keys for signing, encryption, handshakes, etc. Each key has a
+// 32-byte chain code, which is mixed into the derivation of its children along with the child's label.
+//
+// Hardened children are derived from their parent's private key and cannot be derived from the parent's public key.
+// Non-hardened children are derived from their parent's public key and chain code, so a party with only the parent's
+// PublicKey can derive the public keys of its non-hardened children (e.g., to generate receiving keys on a server which
+// does not hold any private keys).
+//
+// WARNING: If an attacker learns both a non-hardened child's private key and its parent's PublicKey (including the
+// chain code), they can recover the parent's private key and thus every descendant key. Use hardened derivation for
+// any key whose private key might be exposed separately from its siblings, and use non-hardened derivation only where
+// public derivation is required.
+package hdkey
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/transcript"
+	"github.com/gtank/ristretto255"
+)
+
+const (
+	// MinSeedSize is the minimum length of a seed in bytes.
+	MinSeedSize = 16
+
+	// ChainCodeSize is the length of a chain code in bytes.
+	ChainCodeSize = 32
+
+	// PrivateKeySize is the length of an encoded PrivateKey in bytes.
+	PrivateKeySize = 32 + ChainCodeSize
+
+	// PublicKeySize is the length of an encoded PublicKey in bytes.
+	PublicKeySize = 32 + ChainCodeSize
+)
+
+var (
+	// ErrShortSeed is returned when a seed is shorter than MinSeedSize.
+	ErrShortSeed = errors.New("hdkey: seed too short")
+
+	// ErrHardenedPath is returned when a path with a hardened segment is derived from a PublicKey.
+	ErrHardenedPath = errors.New("hdkey: cannot derive hardened child from public key")
+
+	// ErrInvalidPath is returned when a path is empty or contains an empty segment.
+	ErrInvalidPath = errors.New("hdkey: invalid path")
+
+	// ErrInvalidKey is returned when an encoded key is malformed.
+	ErrInvalidKey = errors.New("hdkey: invalid key")
+)
+
+// A PrivateKey is an extended Ristretto255 private key: a private scalar and a chain code.
+type PrivateKey struct {
+	d   *ristretto255.Scalar
+	pub PublicKey
+}
+
+// A PublicKey is an extended Ristretto255 public key: a public element and a chain code.
+type PublicKey struct {
+	q         *ristretto255.Element
+	chainCode [ChainCodeSize]byte
+}
+
+// NewMaster derives a master PrivateKey from the given domain separation string and secret seed. The seed should be at
+// least 32 bytes of uniformly random data.
+//
+// Returns ErrShortSeed if the seed is shorter than MinSeedSize.
+func NewMaster(domain string, seed []byte) (*PrivateKey, error) {
+	if len(seed) < MinSeedSize {
+		return nil, ErrShortSeed
+	}
+
+	p := newplex.NewProtocol(domain)
+	p.Mix("seed", seed)
+	d := transcript.ChallengeScalar(p, "private-key")
+	return newPrivateKey(d, p), nil
+}
+
+// Scalar returns the key's private scalar, for use with other packages (e.g., sig or hpke).
+func (k *PrivateKey) Scalar() *ristretto255.Scalar {
+	return ristretto255.NewScalar().Set(k.d)
+}
+
+// Public returns the key's corresponding PublicKey.
+func (k *PrivateKey) Public() *PublicKey {
+	pub := k.pub
+	return &pub
+}
+
+// Hardened returns the hardened child key with the given label. Hardened children can only be derived from the
+// private key.
+//
+// Panics if the label is not valid. See Path.
+func (k *PrivateKey) Hardened(label string) *PrivateKey {
+	checkLabel(label)
+	p := k.pub.child("hardened")
+	transcript.AppendScalar(p, "private-key", k.d)
+	p.Mix("label", []byte(label))
+	d := transcript.ChallengeScalar(p, "private-key")
+	return newPrivateKey(d, p)
+}
+
+// Child returns the non-hardened child key with the given label. Its public key can also be derived from the parent's
+// PublicKey via PublicKey.Child.
+//
+// Panics if the label is not valid. See Path.
+func (k *PrivateKey) Child(label string) *PrivateKey {
+	checkLabel(label)
+	tweak, p := k.pub.tweak(label)
+	d := ristretto255.NewScalar().Add(k.d, tweak)
+	return newPrivateKey(d, p)
+}
+
+// Path derives a descendant key along the given slash-separated path of labels (e.g., "signing'/service-a"). Segments
+// ending in an apostrophe are derived with Hardened; all other segments are derived with Child. Because of this
+// encoding, a valid label is non-empty, contains no slashes, and does not end in an apostrophe, so every key derived
+// with Hardened and Child can also be derived with Path.
+//
+// Returns ErrInvalidPath if the path is empty or contains an invalid label.
+func (k *PrivateKey) Path(path string) (*PrivateKey, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range segments {
+		if s.hardened {
+			k = k.Hardened(s.label)
+		} else {
+			k = k.Child(s.label)
+		}
+	}
+	return k, nil
+}
+
+// Equal returns true if the two keys have equal private scalars and chain codes.
+func (k *PrivateKey) Equal(k2 *PrivateKey) bool {
+	return k.d.Equal(k2.d) == 1 && k.pub.chainCode == k2.pub.chainCode
+}
+
+// MarshalBinary returns the encoded private scalar and chain code. It implements encoding.BinaryMarshaler.
+func (k *PrivateKey) MarshalBinary() ([]byte, error) {
+	return append(k.d.Bytes(), k.pub.chainCode[:]...), nil
+}
+
+// UnmarshalBinary decodes a private key encoded with MarshalBinary. It implements encoding.BinaryUnmarshaler.
+func (k *PrivateKey) UnmarshalBinary(data []byte) error {
+	if len(data) != PrivateKeySize {
+		return ErrInvalidKey
+	}
+
+	d, _ := ristretto255.NewScalar().SetCanonicalBytes(data[:32])
+	if d == nil {
+		return ErrInvalidKey
+	}
+
+	k.d = d
+	k.pub = PublicKey{q: ristretto255.NewIdentityElement().ScalarBaseMult(d), chainCode: [ChainCodeSize]byte(data[32:])}
+	return nil
+}
+
+// Element returns the key's public element, for use with other packages (e.g., sig or hpke).
+func (pk *PublicKey) Element() *ristretto255.Element {
+	return ristretto255.NewIdentityElement().Set(pk.q)
+}
+
+// Child returns the public key of the non-hardened child with the given label.
+//
+// Panics if the label is not valid. See PrivateKey.Path.
+func (pk *PublicKey) Child(label string) *PublicKey {
+	checkLabel(label)
+	tweak, p := pk.tweak(label)
+	q := ristretto255.NewIdentityElement().ScalarBaseMult(tweak)
+	q.Add(q, pk.q)
+	pub := &PublicKey{q: q}
+	p.Derive("chain-code", pub.chainCode[:0], ChainCodeSize)
+	return pub
+}
+
+// Path derives a descendant public key along the given slash-separated path of labels (e.g., "receiving/0").
+//
+// Returns ErrHardenedPath if any segment of the path is hardened, or ErrInvalidPath if the path is empty or contains
+// an invalid label.
+func (pk *PublicKey) Path(path string) (*PublicKey, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range segments {
+		if s.hardened {
+			return nil, ErrHardenedPath
+		}
+		pk = pk.Child(s.label)
+	}
+	return pk, nil
+}
+
+// Equal returns true if the two keys have equal public elements and chain codes.
+func (pk *PublicKey) Equal(pk2 *PublicKey) bool {
+	return pk.q.Equal(pk2.q) == 1 && pk.chainCode == pk2.chainCode
+}
+
+// MarshalBinary returns the encoded public element and chain code. It implements encoding.BinaryMarshaler.
+func (pk *PublicKey) MarshalBinary() ([]byte, error) {
+	return append(pk.q.Bytes(), pk.chainCode[:]...), nil
+}
+
+// UnmarshalBinary decodes a public key encoded with MarshalBinary. It implements encoding.BinaryUnmarshaler.
+func (pk *PublicKey) UnmarshalBinary(data []byte) error {
+	if len(data) != PublicKeySize {
+		return ErrInvalidKey
+	}
+
+	q, _ := ristretto255.NewIdentityElement().SetCanonicalBytes(data[:32])
+	if q == nil {
+		return ErrInvalidKey
+	}
+
+	pk.q = q
+	pk.chainCode = [ChainCodeSize]byte(data[32:])
+	return nil
+}
+
+// child returns a protocol for deriving a child of the given type from the key's chain code and public element.
+func (pk *PublicKey) child(childType string) *newplex.Protocol {
+	p := newplex.NewProtocol("newplex.hdkey.child")
+	p.Mix("type", []byte(childType))
+	p.Mix("chain-code", pk.chainCode[:])
+	transcript.AppendElement(p, "public-key", pk.q)
+	return p
+}
+
+// tweak returns the scalar tweak for the non-hardened child with the given label, along with the protocol from which
+// the child's chain code is derived.
+func (pk *PublicKey) tweak(label string) (*ristretto255.Scalar, *newplex.Protocol) {
+	p := pk.child("non-hardened")
+	p.Mix("label", []byte(label))
+	return transcript.ChallengeScalar(p, "tweak"), p
+}
+
+// newPrivateKey returns a private key with the given scalar and a chain code derived from the given protocol.
+func newPrivateKey(d *ristretto255.Scalar, p *newplex.Protocol) *PrivateKey {
+	k := &PrivateKey{d: d, pub: PublicKey{q: ristretto255.NewIdentityElement().ScalarBaseMult(d)}}
+	p.Derive("chain-code", k.pub.chainCode[:0], ChainCodeSize)
+	return k
+}
+
+type segment struct {
+	label    string
+	hardened bool
+}
+
+func parsePath(path string) ([]segment, error) {
+	if path == "" {
+		return nil, ErrInvalidPath
+	}
+
+	parts := strings.Split(path, "/")
+	segments := make([]segment, len(parts))
+	for i, part := range parts {
+		label, hardened := strings.CutSuffix(part, "'")
+		if !validLabel(label) {
+			return nil, ErrInvalidPath
+		}
+		segments[i] = segment{label: label, hardened: hardened}
+	}
+	return segments, nil
+}
+
+// validLabel returns true if the label can be encoded as a segment of a path: it must be non-empty, contain no slashes,
+// and not end in an apostrophe.
+func validLabel(label string) bool {
+	return label != "" && !strings.Contains(label, "/") && !strings.HasSuffix(label, "'")
+}
+
+// checkLabel panics if the label is not valid.
+func checkLabel(label string) {
+	if !validLabel(label) {
+		panic("hdkey: invalid label " + strconv.Quote(label))
+	}
+}
@@ -0,0 +1,264 @@
+package hdkey_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/codahale/newplex/hdkey"
+	"github.com/codahale/newplex/internal/testdata"
+	"github.com/codahale/newplex/sig"
+	"github.com/gtank/ristretto255"
+)
+
+func master(t *testing.T) *hdkey.PrivateKey {
+	t.Helper()
+	k, err := hdkey.NewMaster("hdkey", testdata.New("newplex hdkey").Data(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestNewMaster(t *testing.T) {
+	seed := testdata.New("newplex hdkey").Data(32)
+
+	t.Run("deterministic", func(t *testing.T) {
+		k1, _ := hdkey.NewMaster("hdkey", seed)
+		k2, _ := hdkey.NewMaster("hdkey", seed)
+		if !k1.Equal(k2) {
+			t.Error("NewMaster() is not deterministic")
+		}
+	})
+
+	t.Run("domain separation", func(t *testing.T) {
+		k1, _ := hdkey.NewMaster("hdkey", seed)
+		k2, _ := hdkey.NewMaster("other", seed)
+		if k1.Equal(k2) {
+			t.Error("NewMaster() with different domains returned equal keys")
+		}
+
+		if k1.Hardened("a").Equal(k2.Hardened("a")) {
+			t.Error("Hardened() with different domains returned equal keys")
+		}
+	})
+
+	t.Run("short seed", func(t *testing.T) {
+		_, err := hdkey.NewMaster("hdkey", seed[:hdkey.MinSeedSize-1])
+		if got, want := err, hdkey.ErrShortSeed; !errors.Is(got, want) {
+			t.Errorf("NewMaster() err = %v, want = %v", got, want)
+		}
+	})
+}
+
+func TestPrivateKey_Hardened(t *testing.T) {
+	k := master(t)
+
+	if !k.Hardened("a").Equal(k.Hardened("a")) {
+		t.Error("Hardened() is not deterministic")
+	}
+
+	children := []*hdkey.PrivateKey{k, k.Hardened("a"), k.Hardened("b"), k.Child("a"), k.Hardened("a").Hardened("a")}
+	for i := range children {
+		for j := range i {
+			if children[i].Equal(children[j]) {
+				t.Errorf("children[%d] = children[%d]", i, j)
+			}
+		}
+	}
+}
+
+func TestPrivateKey_Child(t *testing.T) {
+	k := master(t)
+
+	t.Run("public derivation", func(t *testing.T) {
+		got := k.Public().Child("a").Child("b")
+		want := k.Child("a").Child("b").Public()
+		if !got.Equal(want) {
+			t.Error("PublicKey.Child() != PrivateKey.Child().Public()")
+		}
+	})
+
+	t.Run("public key", func(t *testing.T) {
+		child := k.Hardened("x").Child("y")
+		if got, want := child.Public().Element().Bytes(), ristretto255.NewIdentityElement().ScalarBaseMult(child.Scalar()).Bytes(); !bytes.Equal(got, want) {
+			t.Errorf("Element() = %x, want = %x", got, want)
+		}
+	})
+}
+
+func TestPath(t *testing.T) {
+	k := master(t)
+
+	t.Run("private", func(t *testing.T) {
+		got, err := k.Path("signing'/service-a/0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := k.Hardened("signing").Child("service-a").Child("0"); !got.Equal(want) {
+			t.Error("Path() did not match Hardened().Child().Child()")
+		}
+	})
+
+	t.Run("public", func(t *testing.T) {
+		got, err := k.Hardened("receiving").Public().Path("a/b")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want, err := k.Path("receiving'/a/b")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !got.Equal(want.Public()) {
+			t.Error("PublicKey.Path() did not match PrivateKey.Path()")
+		}
+	})
+
+	t.Run("hardened public", func(t *testing.T) {
+		_, err := k.Public().Path("a/b'")
+		if got, want := err, hdkey.ErrHardenedPath; !errors.Is(got, want) {
+			t.Errorf("Path() err = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, label := range []string{"a", "it's", "'a", "a'b", "0"} {
+			got, err := k.Path(label + "'/" + label)
+			if err != nil {
+				t.Fatalf("Path(%q) err = %v", label, err)
+			}
+
+			if want := k.Hardened(label).Child(label); !got.Equal(want) {
+				t.Errorf("Path(%q) did not match Hardened().Child()", label)
+			}
+		}
+	})
+
+	for _, label := range []string{"", "a/b", "a'", "a''"} {
+		t.Run("invalid label "+label, func(t *testing.T) {
+			for name, f := range map[string]func(){
+				"Hardened":        func() { k.Hardened(label) },
+				"Child":           func() { k.Child(label) },
+				"PublicKey.Child": func() { k.Public().Child(label) },
+			} {
+				func() {
+					defer func() {
+						if r := recover(); r == nil {
+							t.Errorf("%s(%q) did not panic", name, label)
+						}
+					}()
+					f()
+				}()
+			}
+		})
+	}
+
+	for _, path := range []string{"", "/", "a//b", "a/", "'", "a''", "a''/b"} {
+		t.Run("invalid "+path, func(t *testing.T) {
+			if _, err := k.Path(path); !errors.Is(err, hdkey.ErrInvalidPath) {
+				t.Errorf("PrivateKey.Path(%q) err = %v, want = %v", path, err, hdkey.ErrInvalidPath)
+			}
+
+			if _, err := k.Public().Path(path); !errors.Is(err, hdkey.ErrInvalidPath) {
+				t.Errorf("PublicKey.Path(%q) err = %v, want = %v", path, err, hdkey.ErrInvalidPath)
+			}
+		})
+	}
+}
+
+func TestPrivateKey_MarshalBinary(t *testing.T) {
+	k := master(t).Hardened("a")
+
+	data, err := k.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := len(data), hdkey.PrivateKeySize; got != want {
+		t.Errorf("len(MarshalBinary()) = %d, want = %d", got, want)
+	}
+
+	var k2 hdkey.PrivateKey
+	if err := k2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !k.Equal(&k2) || !k.Public().Equal(k2.Public()) || !k.Child("b").Equal(k2.Child("b")) {
+		t.Error("UnmarshalBinary() did not round trip")
+	}
+
+	if err := k2.UnmarshalBinary(data[1:]); !errors.Is(err, hdkey.ErrInvalidKey) {
+		t.Errorf("UnmarshalBinary(short) err = %v, want = %v", err, hdkey.ErrInvalidKey)
+	}
+
+	bad := bytes.Repeat([]byte{0xff}, hdkey.PrivateKeySize)
+	if err := k2.UnmarshalBinary(bad); !errors.Is(err, hdkey.ErrInvalidKey) {
+		t.Errorf("UnmarshalBinary(non-canonical) err = %v, want = %v", err, hdkey.ErrInvalidKey)
+	}
+}
+
+func TestPublicKey_MarshalBinary(t *testing.T) {
+	pk := master(t).Hardened("a").Public()
+
+	data, err := pk.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := len(data), hdkey.PublicKeySize; got != want {
+		t.Errorf("len(MarshalBinary()) = %d, want = %d", got, want)
+	}
+
+	var pk2 hdkey.PublicKey
+	if err := pk2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !pk.Equal(&pk2) || !pk.Child("b").Equal(pk2.Child("b")) {
+		t.Error("UnmarshalBinary() did not round trip")
+	}
+
+	if err := pk2.UnmarshalBinary(data[1:]); !errors.Is(err, hdkey.ErrInvalidKey) {
+		t.Errorf("UnmarshalBinary(short) err = %v, want = %v", err, hdkey.ErrInvalidKey)
+	}
+
+	bad := bytes.Repeat([]byte{0xff}, hdkey.PublicKeySize)
+	if err := pk2.UnmarshalBinary(bad); !errors.Is(err, hdkey.ErrInvalidKey) {
+		t.Errorf("UnmarshalBinary(non-canonical) err = %v, want = %v", err, hdkey.ErrInvalidKey)
+	}
+}
+
+func Example() {
+	seed := testdata.New("newplex hdkey example").Data(32)
+
+	// Derive a master key from a backed-up seed.
+	master, err := hdkey.NewMaster("com.example.keys", seed)
+	if err != nil {
+		panic(err)
+	}
+
+	// Derive a hardened signing key for a specific service.
+	signing, err := master.Path("signing'/service-a'")
+	if err != nil {
+		panic(err)
+	}
+
+	signature, err := sig.Sign("com.example.sig", signing.Scalar(), nil, strings.NewReader("a message"))
+	if err != nil {
+		panic(err)
+	}
+
+	valid, err := sig.Verify("com.example.sig", signing.Public().Element(), signature, strings.NewReader("a message"))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(valid)
+
+	// Output:
+	// true
+}
@@ -10,15 +10,28 @@
 // the sender's private key but not the receiver's private key cannot read plaintexts. It is not, however,
 // insider-secure for authenticity. An attacker in possession of the receiver's private key can forge messages from any
 // sender whose public key they possess (aka Key Compromise Impersonation).
+//
+// SealHybrid and OpenHybrid additionally encapsulate a shared secret to the receiver's ML-KEM-768 key and mix it into
+// the protocol, so messages remain confidential unless both Ristretto255 and ML-KEM-768 are broken. This protects
+// recorded messages from a future attacker with a quantum computer, but sender authentication still depends only on
+// Ristretto255.
 package hpke
 
 import (
+	"crypto/mlkem"
+
 	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/internal/kem"
 	"github.com/gtank/ristretto255"
 )
 
-// Overhead is the size, in bytes, of the additional data added to a message by Seal.
-const Overhead = 32 + newplex.TagSize
+const (
+	// Overhead is the size, in bytes, of the additional data added to a message by Seal.
+	Overhead = 32 + newplex.TagSize
+
+	// HybridOverhead is the size, in bytes, of the additional data added to a message by SealHybrid.
+	HybridOverhead = Overhead + mlkem.CiphertextSize768
+)
 
 // Seal encrypts the given plaintext for the owner of the given public key, using the given sender's private key and
 // user-provided random data.
@@ -66,3 +79,68 @@ func Open(domain string, dR *ristretto255.Scalar, qS *ristretto255.Element, ciph
 	p.Mix("static ecdh", ssS.Bytes())
 	return p.Open("message", nil, ciphertext[32:])
 }
+
+// SealHybrid encrypts the given plaintext for the owner of the given public key and ML-KEM-768 encapsulation key, using
+// the given sender's private key and user-provided random data. The ML-KEM-768 encapsulation uses crypto/rand.
+//
+// Panics if rand is not exactly 64 bytes.
+func SealHybrid(
+	domain string, qR *ristretto255.Element, ekR *mlkem.EncapsulationKey768, dS *ristretto255.Scalar,
+	rand, plaintext []byte,
+) []byte {
+	// Generate an ephemeral key.
+	dE, err := ristretto255.NewScalar().SetUniformBytes(rand)
+	if err != nil {
+		panic(err)
+	}
+	qE := ristretto255.NewIdentityElement().ScalarBaseMult(dE)
+
+	// Calculate the ephemeral and static shared secrets and encapsulate a KEM shared secret.
+	ssE := ristretto255.NewIdentityElement().ScalarMult(dE, qR)
+	ssS := ristretto255.NewIdentityElement().ScalarMult(dS, qR)
+	ssK, ct := kem.Encapsulate768(ekR)
+
+	p := newplex.NewProtocol(domain)
+	p.Mix("sender", ristretto255.NewIdentityElement().ScalarBaseMult(dS).Bytes())
+	p.Mix("receiver", qR.Bytes())
+	p.Mix("receiver kem", ekR.Bytes())
+	p.Mix("ephemeral", qE.Bytes())
+	p.Mix("kem ciphertext", ct)
+	p.Mix("ephemeral ecdh", ssE.Bytes())
+	p.Mix("static ecdh", ssS.Bytes())
+	p.Mix("kem", ssK)
+	return p.Seal("message", append(qE.Bytes(), ct...), plaintext)
+}
+
+// OpenHybrid decrypts the ciphertext produced by SealHybrid.
+func OpenHybrid(
+	domain string, dR *ristretto255.Scalar, dkR *mlkem.DecapsulationKey768, qS *ristretto255.Element,
+	ciphertext []byte,
+) ([]byte, error) {
+	if len(ciphertext) < HybridOverhead {
+		return nil, newplex.ErrInvalidCiphertext
+	}
+
+	qE, _ := ristretto255.NewIdentityElement().SetCanonicalBytes(ciphertext[:32])
+	if qE == nil {
+		return nil, newplex.ErrInvalidCiphertext
+	}
+	ct := ciphertext[32 : 32+mlkem.CiphertextSize768]
+	ssE := ristretto255.NewIdentityElement().ScalarMult(dR, qE)
+	ssS := ristretto255.NewIdentityElement().ScalarMult(dR, qS)
+	ssK, err := dkR.Decapsulate(ct)
+	if err != nil {
+		return nil, newplex.ErrInvalidCiphertext
+	}
+
+	p := newplex.NewProtocol(domain)
+	p.Mix("sender", qS.Bytes())
+	p.Mix("receiver", ristretto255.NewIdentityElement().ScalarBaseMult(dR).Bytes())
+	p.Mix("receiver kem", dkR.EncapsulationKey().Bytes())
+	p.Mix("ephemeral", qE.Bytes())
+	p.Mix("kem ciphertext", ct)
+	p.Mix("ephemeral ecdh", ssE.Bytes())
+	p.Mix("static ecdh", ssS.Bytes())
+	p.Mix("kem", ssK)
+	return p.Open("message", nil, ciphertext[32+mlkem.CiphertextSize768:])
+}
@@ -2,6 +2,7 @@ package hpke_test
 
 import (
 	"bytes"
+	"crypto/mlkem"
 	"slices"
 	"testing"
 
@@ -75,6 +76,89 @@ func TestOpen(t *testing.T) {
 	})
 }
 
+func TestOpenHybrid(t *testing.T) {
+	drbg := testdata.New("newplex hpke hybrid")
+	dR, qR := drbg.KeyPair()
+	dS, qS := drbg.KeyPair()
+	dX, qX := drbg.KeyPair()
+	dkR, _ := mlkem.NewDecapsulationKey768(drbg.Data(mlkem.SeedSize))
+	dkX, _ := mlkem.NewDecapsulationKey768(drbg.Data(mlkem.SeedSize))
+	r := drbg.Data(64)
+
+	message := []byte("this is a message")
+	ciphertext := hpke.SealHybrid("hpke", qR, dkR.EncapsulationKey(), dS, r, message)
+
+	if got, want := len(ciphertext), len(message)+hpke.HybridOverhead; got != want {
+		t.Errorf("len(ciphertext) = %d, want = %d", got, want)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		plaintext, err := hpke.OpenHybrid("hpke", dR, dkR, qS, ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := plaintext, message; !bytes.Equal(got, want) {
+			t.Errorf("OpenHybrid() = %x, want = %x", got, want)
+		}
+	})
+
+	t.Run("wrong receiver", func(t *testing.T) {
+		plaintext, err := hpke.OpenHybrid("hpke", dX, dkR, qS, ciphertext)
+		if err == nil {
+			t.Errorf("OpenHybrid = %x, want = ErrInvalidCiphertext", plaintext)
+		}
+	})
+
+	t.Run("wrong receiver KEM key", func(t *testing.T) {
+		plaintext, err := hpke.OpenHybrid("hpke", dR, dkX, qS, ciphertext)
+		if err == nil {
+			t.Errorf("OpenHybrid = %x, want = ErrInvalidCiphertext", plaintext)
+		}
+	})
+
+	t.Run("wrong sender", func(t *testing.T) {
+		plaintext, err := hpke.OpenHybrid("hpke", dR, dkR, qX, ciphertext)
+		if err == nil {
+			t.Errorf("OpenHybrid = %x, want = ErrInvalidCiphertext", plaintext)
+		}
+	})
+
+	t.Run("not hybrid", func(t *testing.T) {
+		plaintext, err := hpke.Open("hpke", dR, qS, ciphertext)
+		if err == nil {
+			t.Errorf("Open = %x, want = ErrInvalidCiphertext", plaintext)
+		}
+	})
+
+	for _, tc := range []struct {
+		name string
+		pos  int
+	}{
+		{"bad qE", 2},
+		{"bad KEM ciphertext", 34},
+		{"bad ciphertext", 32 + mlkem.CiphertextSize768 + 2},
+		{"bad tag", len(ciphertext) - 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			bad := slices.Clone(ciphertext)
+			bad[tc.pos] ^= 1
+
+			plaintext, err := hpke.OpenHybrid("hpke", dR, dkR, qS, bad)
+			if err == nil {
+				t.Errorf("OpenHybrid = %x, want = ErrInvalidCiphertext", plaintext)
+			}
+		})
+	}
+
+	t.Run("short ciphertext", func(t *testing.T) {
+		plaintext, err := hpke.OpenHybrid("hpke", dR, dkR, qS, ciphertext[:hpke.HybridOverhead-1])
+		if err == nil {
+			t.Errorf("OpenHybrid = %x, want = ErrInvalidCiphertext", plaintext)
+		}
+	})
+}
+
 func FuzzOpen(f *testing.F) {
 	drbg := testdata.New("newplex hpke fuzz")
 	for range 10 {
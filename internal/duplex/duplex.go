@@ -1,9 +1,11 @@
-// Package duplex implements a cryptographic duplex construction using the Simpira-1024 permutation.
+// Package duplex implements a cryptographic duplex construction using the Simpira-1024 or Keccak-p[1600,12]
+// permutations.
 //
 // This package provides the core duplex state management for the Newplex cryptographic framework. It implements a
-// sponge-like construction with a 1024-bit state, 256-bit capacity, and 752-bit rate, offering 128-bit security against
-// generic attacks. The duplex supports absorption, squeezing, encryption/decryption operations, and includes
-// STROBE-like framing for domain separation.
+// sponge-like construction with a 256-bit capacity, offering 128-bit security against generic attacks. With
+// Simpira-1024, it has a 1024-bit state and a 752-bit rate; with Keccak-p[1600,12], it has a 1600-bit state and a
+// 1328-bit rate. The duplex supports absorption, squeezing, encryption/decryption operations, and includes STROBE-like
+// framing for domain separation.
 package duplex
 
 import (
@@ -11,38 +13,105 @@ import (
 	"encoding"
 	"errors"
 
+	"github.com/codahale/newplex/internal/keccakp1600"
 	"github.com/codahale/newplex/internal/simpira1024"
 )
 
-// A State is the state of a cryptographic duplex, sans padding or framing schemes. It uses the Simpira-1024
+// A State is the state of a cryptographic duplex, sans padding or framing schemes. By default, it uses the Simpira-1024
 // permutation, has a width of 1024 bits, a capacity of 256 bits, 8 bits of framing, 8 bits of padding, and a rate of
 // 752 bits. This offers 128 bits of security for collision resistance, 256 bits of security for state recovery, and 128
-// bits of security for birthday-bound indistinguishability.
+// bits of security for birthday-bound indistinguishability. If initialized with KeccakP1600, it has a width of 1600 bits
+// and a rate of 1328 bits with the same capacity and security levels.
 //
 // In addition to using SHA-3's pad10*1 scheme for each block of permutation input, it also uses a STROBE-like framing
 // mechanism for domain separation of sets of operations.
+//
+// The zero value of a State is an empty duplex using the Simpira-1024 permutation.
+//
+// A State always reserves MaxWidth bytes for the permutation's state, regardless of the permutation it uses, so that it
+// remains a fixed-size value which can be copied (e.g., by Protocol.Clone or by copying a Protocol value) without any
+// allocation or aliasing. A Simpira-1024 state leaves the final 72 bytes unused, which is cheaper than the alternatives:
+// a heap-allocated, permutation-sized buffer would make every copy allocate, and a generic State would make Protocol
+// generic.
 type State struct {
-	state             [width]byte
+	state             [MaxWidth]byte
+	perm              Permutation
 	rateIdx, frameIdx int
 }
 
+// Init resets the duplex to an empty state using the given permutation.
+func (d *State) Init(perm Permutation) {
+	_ = perm.Width() // Panic early on unknown permutations.
+	d.Clear()
+	d.perm = perm
+}
+
+// Permutation returns the duplex's permutation.
+func (d *State) Permutation() Permutation {
+	return d.perm
+}
+
 // Permute applies a Frame-oriented padding scheme to the state by absorbing the Frame index into the rate or
 // potentially overflowing into the first of two padding bytes, then applies SHA-3's pad10*1 padding scheme to the
 // entire, unpadded rate. Finally, it permutes the entire state with Simpira-1024 and resets rateIdx and frameIdx.
 func (d *State) Permute() {
+	d.pad()
+	d.perm.permute(&d.state)
+	d.rateIdx = 0
+	d.frameIdx = 0
+}
+
+// PermuteMany is equivalent to calling Permute on each of the given states, but permutes groups of four independent
+// Simpira-1024 states in parallel where supported.
+func PermuteMany(ds []*State) {
+	var batch [4]*State
+	n := 0
+	for _, d := range ds {
+		if d.perm != Simpira1024 {
+			d.Permute()
+			continue
+		}
+
+		batch[n] = d
+		n++
+		if n < len(batch) {
+			continue
+		}
+		n = 0
+
+		for _, d := range batch {
+			d.pad()
+		}
+		simpira1024.Permute4(
+			(*[simpira1024.Width]byte)(batch[0].state[:simpira1024.Width]),
+			(*[simpira1024.Width]byte)(batch[1].state[:simpira1024.Width]),
+			(*[simpira1024.Width]byte)(batch[2].state[:simpira1024.Width]),
+			(*[simpira1024.Width]byte)(batch[3].state[:simpira1024.Width]),
+		)
+		for _, d := range batch {
+			d.rateIdx = 0
+			d.frameIdx = 0
+		}
+	}
+
+	for _, d := range batch[:n] {
+		d.Permute()
+	}
+}
+
+// pad absorbs the frame index and applies SHA-3's pad10*1 padding scheme to the rate.
+func (d *State) pad() {
 	d.state[d.rateIdx] ^= byte(d.frameIdx)
 	d.rateIdx++
 	d.state[d.rateIdx] ^= 0x01
-	d.state[padByteIdx] ^= 0x80
-	simpira1024.Permute(&d.state)
-	d.rateIdx = 0
-	d.frameIdx = 0
+	d.state[d.padByteIdx()] ^= 0x80
 }
 
 // Absorb updates the duplex's state with the given data, running the permutation as the rate is exhausted.
 //
 // Multiple Absorb calls are effectively the same thing as a single Absorb call with concatenated inputs.
 func (d *State) Absorb(b []byte) {
+	maxRateIdx := d.maxRateIdx()
 	for len(b) > 0 {
 		remain := min(len(b), maxRateIdx-d.rateIdx)
 		absorbBlock(d.state[d.rateIdx:d.rateIdx+remain], b[:remain])
@@ -58,7 +127,7 @@ func (d *State) Absorb(b []byte) {
 func (d *State) AbsorbByte(b byte) {
 	d.state[d.rateIdx] ^= b
 	d.rateIdx++
-	if d.rateIdx == maxRateIdx {
+	if d.rateIdx == d.maxRateIdx() {
 		d.Permute()
 	}
 }
@@ -103,6 +172,7 @@ func (d *State) AbsorbHeader(op byte, label string) {
 	n := len(label)
 	headerLen := 4 + n // frame byte + op + label + frame byte + op|0x80
 
+	maxRateIdx := d.maxRateIdx()
 	if d.rateIdx+headerLen <= maxRateIdx {
 		// Fast path: absorb the entire header without overflow checks.
 		R := d.rateIdx
@@ -144,6 +214,7 @@ func (d *State) Frame(id byte) {
 //
 // Multiple Squeeze calls are effectively the same thing as a single Squeeze call with concatenated outputs.
 func (d *State) Squeeze(out []byte) {
+	maxRateIdx := d.maxRateIdx()
 	for len(out) > 0 {
 		remain := min(len(out), maxRateIdx-d.rateIdx)
 		copy(out[:remain], d.state[d.rateIdx:d.rateIdx+remain])
@@ -161,6 +232,7 @@ func (d *State) Squeeze(out []byte) {
 //
 // Multiple Encrypt calls are effectively the same thing as a single Encrypt call with concatenated inputs.
 func (d *State) Encrypt(ciphertext, plaintext []byte) {
+	maxRateIdx := d.maxRateIdx()
 	for len(plaintext) > 0 {
 		remain := min(len(plaintext), maxRateIdx-d.rateIdx)
 		k := d.state[d.rateIdx : d.rateIdx+remain]
@@ -182,6 +254,7 @@ func (d *State) Encrypt(ciphertext, plaintext []byte) {
 //
 // Multiple Decrypt calls are effectively the same thing as a single Decrypt call with concatenated inputs.
 func (d *State) Decrypt(plaintext, ciphertext []byte) {
+	maxRateIdx := d.maxRateIdx()
 	for len(ciphertext) > 0 {
 		remain := min(len(ciphertext), maxRateIdx-d.rateIdx)
 		k := d.state[d.rateIdx : d.rateIdx+remain]
@@ -200,7 +273,7 @@ func (d *State) Decrypt(plaintext, ciphertext []byte) {
 	}
 }
 
-// Ratchet applies the Simpira-1024 permutation if needed, then zeros out 256 bits of the rate, preventing rollback.
+// Ratchet applies the permutation if needed, then zeros out 256 bits of the rate, preventing rollback.
 func (d *State) Ratchet() {
 	if d.rateIdx > 0 {
 		d.Permute()
@@ -215,6 +288,7 @@ func (d *State) Ratchet() {
 // Equal returns 1 if d and d2 are equal, and 0 otherwise.
 func (d *State) Equal(d2 *State) int {
 	return subtle.ConstantTimeCompare(d.state[:], d2.state[:]) &
+		subtle.ConstantTimeEq(int32(d.width()), int32(d2.width())) &
 		subtle.ConstantTimeEq(int32(d.rateIdx), int32(d2.rateIdx)) &
 		subtle.ConstantTimeEq(int32(d.frameIdx), int32(d2.frameIdx))
 }
@@ -228,28 +302,64 @@ func (d *State) Clear() {
 
 // UnmarshalBinary restores the duplex's state from the given binary representation. It implements
 // encoding.BinaryUnmarshaler.
+//
+// The binary representation must be of a state which uses the same permutation as the duplex.
 func (d *State) UnmarshalBinary(data []byte) error {
-	if len(data) != len(d.state)+2 {
+	// States which use a permutation other than Simpira-1024 begin with the permutation's identifier.
+	if d.perm != Simpira1024 {
+		if len(data) == 0 || Permutation(data[0]) != d.perm {
+			return errors.New("newplex: invalid duplex permutation")
+		}
+		data = data[1:]
+	}
+
+	if len(data) != 2+d.width() {
 		return errors.New("newplex: invalid state length")
 	}
-	if data[0] >= maxRateIdx || data[1] >= maxRateIdx {
+
+	var tmp State
+	tmp.Init(d.perm)
+	if int(data[0]) >= tmp.maxRateIdx() || int(data[1]) >= tmp.maxRateIdx() {
 		return errors.New("newplex: invalid duplex state")
 	}
-	d.rateIdx = int(data[0])
-	d.frameIdx = int(data[1])
-	copy(d.state[:], data[2:])
+	tmp.rateIdx = int(data[0])
+	tmp.frameIdx = int(data[1])
+	copy(tmp.state[:], data[2:])
+	*d = tmp
 	return nil
 }
 
 // AppendBinary appends the binary representation of the duplex's state to the given slice. It implements
 // encoding.BinaryAppender.
+//
+// The binary representation consists of the rate and frame indexes and the permutation's state. If the duplex uses a
+// permutation other than Simpira-1024, it is preceded by the permutation's identifier, so Simpira-1024 states keep
+// their original encoding.
 func (d *State) AppendBinary(b []byte) ([]byte, error) {
-	return append(append(b, byte(d.rateIdx), byte(d.frameIdx)), d.state[:]...), nil
+	if d.perm != Simpira1024 {
+		b = append(b, byte(d.perm))
+	}
+	return append(append(b, byte(d.rateIdx), byte(d.frameIdx)), d.state[:d.width()]...), nil
 }
 
 // MarshalBinary returns the binary representation of the duplex's state. It implements encoding.BinaryMarshaler.
 func (d *State) MarshalBinary() (data []byte, err error) {
-	return d.AppendBinary(make([]byte, 0, 2+len(d.state)))
+	return d.AppendBinary(make([]byte, 0, 3+d.width()))
+}
+
+// width returns the width of the duplex's permutation in bytes.
+func (d *State) width() int {
+	return d.perm.Width()
+}
+
+// maxRateIdx returns the index of the end of the duplex's rate.
+func (d *State) maxRateIdx() int {
+	return d.width() - padding - framing - capacity
+}
+
+// padByteIdx returns the index of the duplex's dedicated padding byte.
+func (d *State) padByteIdx() int {
+	return d.width() - capacity - padding
 }
 
 var (
@@ -259,10 +369,10 @@ var (
 )
 
 const (
-	width      = simpira1024.Width // The width of the permutation in bytes.
-	capacity   = 32                // The duplex's capacity in bytes.
-	padding    = 1                 // The duplex uses a dedicated byte for pad10*1 block padding.
-	framing    = 1                 // The duplex uses a reserved byte for framing.
-	maxRateIdx = width - padding - framing - capacity
-	padByteIdx = width - capacity - padding
+	// MaxWidth is the maximum width of a duplex's permutation in bytes.
+	MaxWidth = keccakp1600.Width
+
+	capacity = 32 // The duplex's capacity in bytes.
+	padding  = 1  // The duplex uses a dedicated byte for pad10*1 block padding.
+	framing  = 1  // The duplex uses a reserved byte for framing.
 )
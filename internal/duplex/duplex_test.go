@@ -73,10 +73,10 @@ func TestDuplex_AbsorbHeader(t *testing.T) {
 	t.Run("slow path - near rate boundary", func(t *testing.T) {
 		var d State
 		// Fill the state close to rate boundary
-		d.rateIdx = maxRateIdx - 5
+		d.rateIdx = d.maxRateIdx() - 5
 		d.AbsorbHeader(0x02, "longer-label-test")
 		// Should have triggered permutation and reset
-		if d.rateIdx == maxRateIdx-5 {
+		if d.rateIdx == d.maxRateIdx()-5 {
 			t.Error("AbsorbHeader didn't handle rate boundary correctly")
 		}
 	})
@@ -95,11 +95,11 @@ func TestDuplex_AbsorbHeader(t *testing.T) {
 	t.Run("triggers slow path", func(t *testing.T) {
 		var d State
 		// Set up state so the header won't fit in the remaining rate, triggering the slow path
-		d.rateIdx = maxRateIdx - 3 // Only 3 bytes left, but the header needs at least 4
+		d.rateIdx = d.maxRateIdx() - 3 // Only 3 bytes left, but the header needs at least 4
 		d.AbsorbHeader(0x04, "test")
 		// The slow path should have been used, triggering permutation(s)
 		// Just verify the operation completed successfully
-		if d.frameIdx == 0 && d.rateIdx == maxRateIdx-3 {
+		if d.frameIdx == 0 && d.rateIdx == d.maxRateIdx()-3 {
 			t.Error("AbsorbHeader should have modified the state")
 		}
 	})
@@ -116,7 +116,7 @@ func TestDuplex_AbsorbHeader(t *testing.T) {
 
 	t.Run("at rate boundary", func(t *testing.T) {
 		var d State
-		d.rateIdx = maxRateIdx - 1
+		d.rateIdx = d.maxRateIdx() - 1
 		oldState := d.state
 		d.AbsorbByte(0xAA)
 		// Should absorb and then trigger permutation
@@ -131,7 +131,7 @@ func TestDuplex_AbsorbHeader(t *testing.T) {
 
 	t.Run("multiple bytes crossing boundary", func(t *testing.T) {
 		var d State
-		d.rateIdx = maxRateIdx - 2
+		d.rateIdx = d.maxRateIdx() - 2
 		d.AbsorbByte(0x11)
 		d.AbsorbByte(0x22) // This should trigger permutation
 		d.AbsorbByte(0x33)
@@ -210,6 +210,151 @@ func TestDuplex_Permute(t *testing.T) {
 	}
 }
 
+func TestPermuteMany(t *testing.T) {
+	for _, n := range []int{0, 1, 4, 7} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			states := make([]State, n)
+			want := make([]State, n)
+			ptrs := make([]*State, n)
+			for i := range states {
+				states[i].Absorb([]byte{byte(i), 1, 2, 3})
+				want[i] = states[i]
+				want[i].Permute()
+				ptrs[i] = &states[i]
+			}
+
+			PermuteMany(ptrs)
+
+			for i := range states {
+				if got, want := debugDuplex(&states[i]), debugDuplex(&want[i]); got != want {
+					t.Errorf("states[%d] = %s, want = %s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPermuteMany_mixed(t *testing.T) {
+	states := make([]State, 9)
+	want := make([]State, len(states))
+	ptrs := make([]*State, len(states))
+	for i := range states {
+		if i%3 == 0 {
+			states[i].Init(KeccakP1600)
+		}
+		states[i].Absorb([]byte{byte(i), 1, 2, 3})
+		want[i] = states[i]
+		want[i].Permute()
+		ptrs[i] = &states[i]
+	}
+
+	PermuteMany(ptrs)
+
+	for i := range states {
+		if got, want := debugDuplex(&states[i]), debugDuplex(&want[i]); got != want {
+			t.Errorf("states[%d] = %s, want = %s", i, got, want)
+		}
+	}
+}
+
+func TestDuplex_KeccakP1600(t *testing.T) {
+	t.Run("permutation", func(t *testing.T) {
+		var d State
+		if got, want := d.Permutation(), Simpira1024; got != want {
+			t.Errorf("Permutation() = %v, want = %v", got, want)
+		}
+
+		d.Init(KeccakP1600)
+		if got, want := d.Permutation(), KeccakP1600; got != want {
+			t.Errorf("Permutation() = %v, want = %v", got, want)
+		}
+
+		d.Init(Simpira1024)
+		if got, want := d.Permutation(), Simpira1024; got != want {
+			t.Errorf("Permutation() = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		var d State
+		d.Init(KeccakP1600)
+		d.Absorb(make([]byte, 165))
+		if got, want := d.rateIdx, 165; got != want {
+			t.Errorf("rateIdx = %d, want = %d", got, want)
+		}
+
+		d.AbsorbByte(1)
+		if got, want := d.rateIdx, 0; got != want {
+			t.Errorf("rateIdx = %d, want = %d", got, want)
+		}
+	})
+
+	t.Run("distinct from Simpira-1024", func(t *testing.T) {
+		var s, k State
+		k.Init(KeccakP1600)
+		s.Absorb([]byte("message"))
+		k.Absorb([]byte("message"))
+		if s.Equal(&k) == 1 {
+			t.Fatal("Simpira-1024 and Keccak-p[1600,12] states are equal")
+		}
+
+		s.Permute()
+		k.Permute()
+		a, b := make([]byte, 32), make([]byte, 32)
+		s.Squeeze(a)
+		k.Squeeze(b)
+		if slices.Equal(a, b) {
+			t.Errorf("Squeeze() = %x for both permutations", a)
+		}
+	})
+
+	t.Run("marshal round trip", func(t *testing.T) {
+		var d State
+		d.Init(KeccakP1600)
+		d.Absorb([]byte("message"))
+		d.Permute()
+		d.Absorb([]byte("more"))
+
+		data, err := d.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(data), 3+KeccakP1600.Width(); got != want {
+			t.Errorf("len(MarshalBinary()) = %d, want = %d", got, want)
+		}
+
+		var simpira State
+		if err := simpira.UnmarshalBinary(data); err == nil {
+			t.Error("UnmarshalBinary() into a Simpira-1024 duplex = nil, want = error")
+		}
+
+		var d2 State
+		d2.Init(KeccakP1600)
+		if err := d2.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := d2.Permutation(), KeccakP1600; got != want {
+			t.Errorf("Permutation() = %v, want = %v", got, want)
+		}
+
+		if d.Equal(&d2) != 1 {
+			t.Errorf("UnmarshalBinary() = %s, want = %s", debugDuplex(&d2), debugDuplex(&d))
+		}
+	})
+
+	t.Run("invalid rateIdx", func(t *testing.T) {
+		data := make([]byte, 3+KeccakP1600.Width())
+		data[0], data[1] = byte(KeccakP1600), 166
+
+		var d State
+		d.Init(KeccakP1600)
+		if err := d.UnmarshalBinary(data); err == nil {
+			t.Error("UnmarshalBinary() = nil, want = error")
+		}
+	})
+}
+
 func TestDuplex_Ratchet(t *testing.T) {
 	t.Run("simple", func(t *testing.T) {
 		d := exampleDuplex()
@@ -478,7 +623,7 @@ func TestDuplex_UnmarshalBinary(t *testing.T) {
 }
 
 func debugDuplex(d *State) string {
-	return fmt.Sprintf("%d_%d_%x", d.frameIdx, d.rateIdx, d.state[:])
+	return fmt.Sprintf("%d_%d_%x", d.frameIdx, d.rateIdx, d.state[:d.width()])
 }
 
 func exampleDuplex() State {
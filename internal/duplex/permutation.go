@@ -0,0 +1,41 @@
+package duplex
+
+import (
+	"github.com/codahale/newplex/internal/keccakp1600"
+	"github.com/codahale/newplex/internal/simpira1024"
+)
+
+// A Permutation identifies the fixed-width cryptographic permutation used by a duplex.
+type Permutation uint8
+
+const (
+	// Simpira1024 is the Simpira-1024 permutation. It is the default permutation of a duplex.
+	Simpira1024 Permutation = iota
+
+	// KeccakP1600 is the Keccak-p[1600,12] permutation.
+	KeccakP1600
+)
+
+// Width returns the width of the permutation in bytes.
+func (perm Permutation) Width() int {
+	switch perm {
+	case Simpira1024:
+		return simpira1024.Width
+	case KeccakP1600:
+		return keccakp1600.Width
+	default:
+		panic("duplex: unknown permutation")
+	}
+}
+
+// permute applies the permutation to the first Width bytes of the given state.
+func (perm Permutation) permute(state *[MaxWidth]byte) {
+	switch perm {
+	case Simpira1024:
+		simpira1024.Permute((*[simpira1024.Width]byte)(state[:simpira1024.Width]))
+	case KeccakP1600:
+		keccakp1600.Permute(state)
+	default:
+		panic("duplex: unknown permutation")
+	}
+}
@@ -0,0 +1,186 @@
+// Package keccakp1600 provides an implementation of the Keccak-p[1600,12] permutation, as used in [TurboSHAKE] and
+// [KangarooTwelve].
+//
+// It uses a portable, constant-time Go implementation which is fast on processors without hardware AES support.
+//
+// [TurboSHAKE]: https://www.rfc-editor.org/rfc/rfc9861.html
+// [KangarooTwelve]: https://keccak.team/kangarootwelve.html
+package keccakp1600
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	// Width is the permutation's width in bytes.
+	Width = 200
+
+	// Rounds is the number of rounds of Keccak-f[1600] applied by Permute.
+	Rounds = 12
+)
+
+// Permute applies the Keccak-p[1600,12] permutation (i.e., the last 12 rounds of Keccak-f[1600]) to a 1600-bit state.
+func Permute(state *[Width]byte) {
+	permute(state, Rounds)
+}
+
+// permute applies the last n rounds of Keccak-f[1600] to the state. The state is interpreted as 25 little-endian
+// 64-bit lanes, with lane (x, y) stored in aXY, where XY = x+5y.
+func permute(state *[Width]byte, n int) {
+	a00 := binary.LittleEndian.Uint64(state[0:])
+	a01 := binary.LittleEndian.Uint64(state[8:])
+	a02 := binary.LittleEndian.Uint64(state[16:])
+	a03 := binary.LittleEndian.Uint64(state[24:])
+	a04 := binary.LittleEndian.Uint64(state[32:])
+	a05 := binary.LittleEndian.Uint64(state[40:])
+	a06 := binary.LittleEndian.Uint64(state[48:])
+	a07 := binary.LittleEndian.Uint64(state[56:])
+	a08 := binary.LittleEndian.Uint64(state[64:])
+	a09 := binary.LittleEndian.Uint64(state[72:])
+	a10 := binary.LittleEndian.Uint64(state[80:])
+	a11 := binary.LittleEndian.Uint64(state[88:])
+	a12 := binary.LittleEndian.Uint64(state[96:])
+	a13 := binary.LittleEndian.Uint64(state[104:])
+	a14 := binary.LittleEndian.Uint64(state[112:])
+	a15 := binary.LittleEndian.Uint64(state[120:])
+	a16 := binary.LittleEndian.Uint64(state[128:])
+	a17 := binary.LittleEndian.Uint64(state[136:])
+	a18 := binary.LittleEndian.Uint64(state[144:])
+	a19 := binary.LittleEndian.Uint64(state[152:])
+	a20 := binary.LittleEndian.Uint64(state[160:])
+	a21 := binary.LittleEndian.Uint64(state[168:])
+	a22 := binary.LittleEndian.Uint64(state[176:])
+	a23 := binary.LittleEndian.Uint64(state[184:])
+	a24 := binary.LittleEndian.Uint64(state[192:])
+
+	for _, rc := range roundConstants[len(roundConstants)-n:] {
+		// θ step
+		c0 := a00 ^ a05 ^ a10 ^ a15 ^ a20
+		c1 := a01 ^ a06 ^ a11 ^ a16 ^ a21
+		c2 := a02 ^ a07 ^ a12 ^ a17 ^ a22
+		c3 := a03 ^ a08 ^ a13 ^ a18 ^ a23
+		c4 := a04 ^ a09 ^ a14 ^ a19 ^ a24
+		d0 := c4 ^ bits.RotateLeft64(c1, 1)
+		d1 := c0 ^ bits.RotateLeft64(c2, 1)
+		d2 := c1 ^ bits.RotateLeft64(c3, 1)
+		d3 := c2 ^ bits.RotateLeft64(c4, 1)
+		d4 := c3 ^ bits.RotateLeft64(c0, 1)
+		a00 ^= d0
+		a01 ^= d1
+		a02 ^= d2
+		a03 ^= d3
+		a04 ^= d4
+		a05 ^= d0
+		a06 ^= d1
+		a07 ^= d2
+		a08 ^= d3
+		a09 ^= d4
+		a10 ^= d0
+		a11 ^= d1
+		a12 ^= d2
+		a13 ^= d3
+		a14 ^= d4
+		a15 ^= d0
+		a16 ^= d1
+		a17 ^= d2
+		a18 ^= d3
+		a19 ^= d4
+		a20 ^= d0
+		a21 ^= d1
+		a22 ^= d2
+		a23 ^= d3
+		a24 ^= d4
+
+		// ρ and π steps
+		b00 := a00
+		b01 := bits.RotateLeft64(a06, 44)
+		b02 := bits.RotateLeft64(a12, 43)
+		b03 := bits.RotateLeft64(a18, 21)
+		b04 := bits.RotateLeft64(a24, 14)
+		b05 := bits.RotateLeft64(a03, 28)
+		b06 := bits.RotateLeft64(a09, 20)
+		b07 := bits.RotateLeft64(a10, 3)
+		b08 := bits.RotateLeft64(a16, 45)
+		b09 := bits.RotateLeft64(a22, 61)
+		b10 := bits.RotateLeft64(a01, 1)
+		b11 := bits.RotateLeft64(a07, 6)
+		b12 := bits.RotateLeft64(a13, 25)
+		b13 := bits.RotateLeft64(a19, 8)
+		b14 := bits.RotateLeft64(a20, 18)
+		b15 := bits.RotateLeft64(a04, 27)
+		b16 := bits.RotateLeft64(a05, 36)
+		b17 := bits.RotateLeft64(a11, 10)
+		b18 := bits.RotateLeft64(a17, 15)
+		b19 := bits.RotateLeft64(a23, 56)
+		b20 := bits.RotateLeft64(a02, 62)
+		b21 := bits.RotateLeft64(a08, 55)
+		b22 := bits.RotateLeft64(a14, 39)
+		b23 := bits.RotateLeft64(a15, 41)
+		b24 := bits.RotateLeft64(a21, 2)
+
+		// χ and ι steps
+		a00 = b00 ^ (^b01 & b02)
+		a01 = b01 ^ (^b02 & b03)
+		a02 = b02 ^ (^b03 & b04)
+		a03 = b03 ^ (^b04 & b00)
+		a04 = b04 ^ (^b00 & b01)
+		a05 = b05 ^ (^b06 & b07)
+		a06 = b06 ^ (^b07 & b08)
+		a07 = b07 ^ (^b08 & b09)
+		a08 = b08 ^ (^b09 & b05)
+		a09 = b09 ^ (^b05 & b06)
+		a10 = b10 ^ (^b11 & b12)
+		a11 = b11 ^ (^b12 & b13)
+		a12 = b12 ^ (^b13 & b14)
+		a13 = b13 ^ (^b14 & b10)
+		a14 = b14 ^ (^b10 & b11)
+		a15 = b15 ^ (^b16 & b17)
+		a16 = b16 ^ (^b17 & b18)
+		a17 = b17 ^ (^b18 & b19)
+		a18 = b18 ^ (^b19 & b15)
+		a19 = b19 ^ (^b15 & b16)
+		a20 = b20 ^ (^b21 & b22)
+		a21 = b21 ^ (^b22 & b23)
+		a22 = b22 ^ (^b23 & b24)
+		a23 = b23 ^ (^b24 & b20)
+		a24 = b24 ^ (^b20 & b21)
+		a00 ^= rc
+	}
+
+	binary.LittleEndian.PutUint64(state[0:], a00)
+	binary.LittleEndian.PutUint64(state[8:], a01)
+	binary.LittleEndian.PutUint64(state[16:], a02)
+	binary.LittleEndian.PutUint64(state[24:], a03)
+	binary.LittleEndian.PutUint64(state[32:], a04)
+	binary.LittleEndian.PutUint64(state[40:], a05)
+	binary.LittleEndian.PutUint64(state[48:], a06)
+	binary.LittleEndian.PutUint64(state[56:], a07)
+	binary.LittleEndian.PutUint64(state[64:], a08)
+	binary.LittleEndian.PutUint64(state[72:], a09)
+	binary.LittleEndian.PutUint64(state[80:], a10)
+	binary.LittleEndian.PutUint64(state[88:], a11)
+	binary.LittleEndian.PutUint64(state[96:], a12)
+	binary.LittleEndian.PutUint64(state[104:], a13)
+	binary.LittleEndian.PutUint64(state[112:], a14)
+	binary.LittleEndian.PutUint64(state[120:], a15)
+	binary.LittleEndian.PutUint64(state[128:], a16)
+	binary.LittleEndian.PutUint64(state[136:], a17)
+	binary.LittleEndian.PutUint64(state[144:], a18)
+	binary.LittleEndian.PutUint64(state[152:], a19)
+	binary.LittleEndian.PutUint64(state[160:], a20)
+	binary.LittleEndian.PutUint64(state[168:], a21)
+	binary.LittleEndian.PutUint64(state[176:], a22)
+	binary.LittleEndian.PutUint64(state[184:], a23)
+	binary.LittleEndian.PutUint64(state[192:], a24)
+}
+
+// roundConstants are the ι step constants for the 24 rounds of Keccak-f[1600].
+var roundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
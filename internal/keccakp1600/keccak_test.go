@@ -0,0 +1,67 @@
+package keccakp1600
+
+import (
+	"bytes"
+	"crypto/sha3"
+	"encoding/hex"
+	"testing"
+
+	"github.com/codahale/newplex/internal/testdata"
+)
+
+func TestPermute(t *testing.T) {
+	// KT128(M="", C="", 32) from https://www.rfc-editor.org/rfc/rfc9861.html#section-5
+	if got, want := hex.EncodeToString(turboSHAKE128([]byte{0x00}, 0x07, 32)), "1ac2d450fc3b4205d19da7bfca1b37513c0803577ac7167f06fe2ce1f0ef39e5"; got != want {
+		t.Errorf("KT128('') = %s, want = %s", got, want)
+	}
+
+	// TurboSHAKE128(M="", D=0x1F, 32) from https://www.rfc-editor.org/rfc/rfc9861.html#section-5
+	if got, want := hex.EncodeToString(turboSHAKE128(nil, 0x1f, 32)), "1e415f1c5983aff2169217277d17bb538cd945a397ddec541f1ce41af2c1b74c"; got != want {
+		t.Errorf("TurboSHAKE128('') = %s, want = %s", got, want)
+	}
+}
+
+func TestPermute_fullRounds(t *testing.T) {
+	drbg := testdata.New("keccak-p[1600]")
+	for _, n := range []int{0, 1, 135, 136, 137, 1000} {
+		msg := drbg.Data(n)
+
+		// Compare a minimal SHA3-256 sponge using the full 24 rounds against crypto/sha3.
+		const rate = 136
+		var state [Width]byte
+		padded := append(append([]byte(nil), msg...), 0x06)
+		padded = append(padded, make([]byte, (rate-len(padded)%rate)%rate)...)
+		padded[len(padded)-1] ^= 0x80
+		for len(padded) > 0 {
+			for i := range rate {
+				state[i] ^= padded[i]
+			}
+			permute(&state, 24)
+			padded = padded[rate:]
+		}
+
+		if got, want := state[:32], sha3.Sum256(msg); !bytes.Equal(got, want[:]) {
+			t.Errorf("SHA3-256(%x) = %x, want = %x", msg, got, want)
+		}
+	}
+}
+
+func BenchmarkPermute(b *testing.B) {
+	var state [Width]byte
+	b.ReportAllocs()
+	b.SetBytes(int64(len(state)))
+	for b.Loop() {
+		Permute(&state)
+	}
+}
+
+// turboSHAKE128 is a minimal implementation of TurboSHAKE128 for single-block messages and outputs.
+func turboSHAKE128(msg []byte, d byte, n int) []byte {
+	const rate = 168
+	var state [Width]byte
+	copy(state[:], msg)
+	state[len(msg)] ^= d
+	state[rate-1] ^= 0x80
+	Permute(&state)
+	return state[:n]
+}
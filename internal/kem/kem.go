@@ -0,0 +1,38 @@
+// Package kem encapsulates ML-KEM-768 shared secrets, with a hook for deterministic known-answer tests.
+package kem
+
+import (
+	"crypto/mlkem"
+	"crypto/mlkem/mlkemtest"
+	"io"
+)
+
+// testRand, if not nil, is the source of randomness for derandomized encapsulation. It is only set by SetTestRand.
+var testRand io.Reader
+
+// Encapsulate768 encapsulates a shared secret to the given encapsulation key with ek.Encapsulate, unless a test has set
+// a source of randomness with SetTestRand.
+func Encapsulate768(ek *mlkem.EncapsulationKey768) (sharedKey, ciphertext []byte) {
+	if testRand == nil {
+		return ek.Encapsulate()
+	}
+
+	var m [32]byte
+	if _, err := io.ReadFull(testRand, m[:]); err != nil {
+		panic(err)
+	}
+	sharedKey, ciphertext, err := mlkemtest.Encapsulate768(ek, m[:])
+	if err != nil {
+		panic(err)
+	}
+	return sharedKey, ciphertext
+}
+
+// SetTestRand makes Encapsulate768 read 32 bytes of randomness per encapsulation from r until the returned function is
+// called. It is only for known-answer tests and the vector generator, is not safe for concurrent use with
+// Encapsulate768, and does not work in FIPS 140-only mode.
+func SetTestRand(r io.Reader) (restore func()) {
+	prev := testRand
+	testRand = r
+	return func() { testRand = prev }
+}
@@ -0,0 +1,37 @@
+package kem_test
+
+import (
+	"bytes"
+	"crypto/mlkem"
+	"testing"
+
+	"github.com/codahale/newplex/internal/kem"
+	"github.com/codahale/newplex/internal/testdata"
+)
+
+func TestEncapsulate768(t *testing.T) {
+	drbg := testdata.New("newplex kem")
+	dk, _ := mlkem.NewDecapsulationKey768(drbg.Data(mlkem.SeedSize))
+	ek, m := dk.EncapsulationKey(), drbg.Data(32)
+
+	encapsulate := func(rand []byte) []byte {
+		if rand != nil {
+			restore := kem.SetTestRand(bytes.NewReader(rand))
+			defer restore()
+		}
+
+		ss, ct := kem.Encapsulate768(ek)
+		if got, err := dk.Decapsulate(ct); err != nil || !bytes.Equal(got, ss) {
+			t.Fatalf("Decapsulate() = %x, %v, want = %x, nil", got, err, ss)
+		}
+		return ct
+	}
+
+	if a, b := encapsulate(m), encapsulate(m); !bytes.Equal(a, b) {
+		t.Error("derandomized encapsulations differ")
+	}
+
+	if a, b := encapsulate(nil), encapsulate(nil); bytes.Equal(a, b) {
+		t.Error("randomized encapsulations are equal")
+	}
+}
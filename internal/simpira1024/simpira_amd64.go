@@ -2,6 +2,23 @@
 
 package simpira1024
 
+import "golang.org/x/sys/cpu"
+
+// useAsm is true if the host processor supports the AES-NI instructions used by the assembly implementation.
+var useAsm = cpu.X86.HasAES
+
+// useAsm4 is true if the host processor supports the AVX-512 and VAES instructions used by the interleaved assembly
+// implementation.
+var useAsm4 = useAsm && cpu.X86.HasAVX512F && cpu.X86.HasAVX512VAES
+
+const asmImplementation = "amd64-aesni"
+
 //go:noescape
 //goland:noinspection GoUnusedParameter
 func permute(state *[Width]byte)
+
+// permute4 applies the permutation to four independent states, interleaving them in the lanes of AVX-512 registers.
+//
+//go:noescape
+//goland:noinspection GoUnusedParameter
+func permute4(s0, s1, s2, s3 *[Width]byte)
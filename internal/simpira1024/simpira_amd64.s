@@ -77,6 +77,89 @@ TEXT ·permute(SB), NOSPLIT, $0
 	MOVOU X7, 112(DI)
 	RET
 
+// LOAD_X4 loads the block at the given offset of each of the four states in AX, BX, CX, and DX into the four 128-bit
+// lanes of a ZMM register.
+#define LOAD_X4(off, z, x) \
+	VMOVDQU off(AX), x; \
+	VINSERTI32X4 $1, off(BX), z, z; \
+	VINSERTI32X4 $2, off(CX), z, z; \
+	VINSERTI32X4 $3, off(DX), z, z
+
+// STORE_X4 stores the four 128-bit lanes of a ZMM register to the block at the given offset of each of the four
+// states in AX, BX, CX, and DX.
+#define STORE_X4(off, z, x) \
+	VMOVDQU x, off(AX); \
+	VEXTRACTI32X4 $1, z, off(BX); \
+	VEXTRACTI32X4 $2, z, off(CX); \
+	VEXTRACTI32X4 $3, z, off(DX)
+
+// ROUND_QUAD_X4 performs 4 independent Feistel steps on four interleaved states using VAES (AVX-512).
+// Each step: dst = AESRound(AESRound(src, roundKey), dst)
+// Each 128-bit lane of a ZMM register holds the corresponding block of a different state, so each VAESENC performs an
+// AES round on all four states at once. Round keys are broadcast to all lanes from fixed offsets k0..k3 relative to SI.
+#define ROUND_QUAD_X4(src0, dst0, src1, dst1, src2, dst2, src3, dst3, k0, k1, k2, k3) \
+	VBROADCASTI32X4 k0(SI), Z8; \
+	VBROADCASTI32X4 k1(SI), Z9; \
+	VBROADCASTI32X4 k2(SI), Z10; \
+	VBROADCASTI32X4 k3(SI), Z11; \
+	VAESENC Z8, src0, Z12; \
+	VAESENC Z9, src1, Z13; \
+	VAESENC Z10, src2, Z14; \
+	VAESENC Z11, src3, Z15; \
+	VAESENC dst0, Z12, dst0; \
+	VAESENC dst1, Z13, dst1; \
+	VAESENC dst2, Z14, dst2; \
+	VAESENC dst3, Z15, dst3
+
+// func permute4(s0, s1, s2, s3 *[128]byte)
+//
+// Requires AVX-512F and VAES.
+TEXT ·permute4(SB), NOSPLIT, $0-32
+	MOVQ s0+0(FP), AX
+	MOVQ s1+8(FP), BX
+	MOVQ s2+16(FP), CX
+	MOVQ s3+24(FP), DX
+	LEAQ ·roundKeys(SB), SI
+
+	LOAD_X4(0, Z0, X0)
+	LOAD_X4(16, Z1, X1)
+	LOAD_X4(32, Z2, X2)
+	LOAD_X4(48, Z3, X3)
+	LOAD_X4(64, Z4, X4)
+	LOAD_X4(80, Z5, X5)
+	LOAD_X4(96, Z6, X6)
+	LOAD_X4(112, Z7, X7)
+
+	ROUND_QUAD_X4(Z0, Z1, Z2, Z3, Z4, Z5, Z6, Z7, 0, 16, 32, 48) // Round 0
+	ROUND_QUAD_X4(Z1, Z6, Z7, Z0, Z3, Z4, Z5, Z2, 64, 80, 96, 112) // Round 1
+	ROUND_QUAD_X4(Z6, Z5, Z2, Z1, Z0, Z3, Z4, Z7, 128, 144, 160, 176) // Round 2
+	ROUND_QUAD_X4(Z5, Z4, Z7, Z6, Z1, Z0, Z3, Z2, 192, 208, 224, 240) // Round 3
+	ROUND_QUAD_X4(Z4, Z3, Z2, Z5, Z6, Z1, Z0, Z7, 256, 272, 288, 304) // Round 4
+	ROUND_QUAD_X4(Z3, Z0, Z7, Z4, Z5, Z6, Z1, Z2, 320, 336, 352, 368) // Round 5
+	ROUND_QUAD_X4(Z0, Z1, Z2, Z3, Z4, Z5, Z6, Z7, 384, 400, 416, 432) // Round 6
+	ROUND_QUAD_X4(Z1, Z6, Z7, Z0, Z3, Z4, Z5, Z2, 448, 464, 480, 496) // Round 7
+	ROUND_QUAD_X4(Z6, Z5, Z2, Z1, Z0, Z3, Z4, Z7, 512, 528, 544, 560) // Round 8
+	ROUND_QUAD_X4(Z5, Z4, Z7, Z6, Z1, Z0, Z3, Z2, 576, 592, 608, 624) // Round 9
+	ROUND_QUAD_X4(Z4, Z3, Z2, Z5, Z6, Z1, Z0, Z7, 640, 656, 672, 688) // Round 10
+	ROUND_QUAD_X4(Z3, Z0, Z7, Z4, Z5, Z6, Z1, Z2, 704, 720, 736, 752) // Round 11
+	ROUND_QUAD_X4(Z0, Z1, Z2, Z3, Z4, Z5, Z6, Z7, 768, 784, 800, 816) // Round 12
+	ROUND_QUAD_X4(Z1, Z6, Z7, Z0, Z3, Z4, Z5, Z2, 832, 848, 864, 880) // Round 13
+	ROUND_QUAD_X4(Z6, Z5, Z2, Z1, Z0, Z3, Z4, Z7, 896, 912, 928, 944) // Round 14
+	ROUND_QUAD_X4(Z5, Z4, Z7, Z6, Z1, Z0, Z3, Z2, 960, 976, 992, 1008) // Round 15
+	ROUND_QUAD_X4(Z4, Z3, Z2, Z5, Z6, Z1, Z0, Z7, 1024, 1040, 1056, 1072) // Round 16
+	ROUND_QUAD_X4(Z3, Z0, Z7, Z4, Z5, Z6, Z1, Z2, 1088, 1104, 1120, 1136) // Round 17
+
+	STORE_X4(0, Z0, X0)
+	STORE_X4(16, Z1, X1)
+	STORE_X4(32, Z2, X2)
+	STORE_X4(48, Z3, X3)
+	STORE_X4(64, Z4, X4)
+	STORE_X4(80, Z5, X5)
+	STORE_X4(96, Z6, X6)
+	STORE_X4(112, Z7, X7)
+	VZEROUPPER
+	RET
+
 // Precomputed round keys for 18 rounds × 4 F-calls = 72 keys.
 // Each key is [c^0x08, c^0x18, c^0x28, c^0x38] for c = 1..72.
 GLOBL ·roundKeys(SB), (NOPTR+RODATA), $1152
@@ -2,6 +2,13 @@
 
 package simpira1024
 
+import "golang.org/x/sys/cpu"
+
+// useAsm is true if the host processor supports the ARMv8 Crypto Extensions used by the assembly implementation.
+var useAsm = cpu.ARM64.HasAES
+
+const asmImplementation = "arm64-aes"
+
 //go:noescape
 //goland:noinspection GoUnusedParameter
 func permute(state *[Width]byte)
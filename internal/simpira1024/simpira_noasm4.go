@@ -0,0 +1,11 @@
+//go:build !amd64 || purego
+
+package simpira1024
+
+// useAsm4 is always false, as there is no interleaved assembly implementation for this platform.
+var useAsm4 = false
+
+// permute4 is never called, as useAsm4 is always false on this platform.
+func permute4(_, _, _, _ *[Width]byte) {
+	panic("newplex/simpira1024: no interleaved implementation")
+}
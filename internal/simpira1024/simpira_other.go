@@ -2,6 +2,11 @@
 
 package simpira1024
 
+// useAsm is always false, as there is no assembly implementation for this platform.
+var useAsm = false
+
+const asmImplementation = genericImplementation
+
 func permute(state *[Width]byte) {
 	permuteGeneric(state)
 }
@@ -18,6 +18,55 @@ func TestPermute(t *testing.T) {
 	}
 }
 
+func TestPermute4(t *testing.T) {
+	drbg := testdata.New("simpira-1024-v2 x4")
+	var states, want [4][Width]byte
+	for i := range states {
+		copy(states[i][:], drbg.Data(Width))
+		want[i] = states[i]
+		Permute(&want[i])
+	}
+
+	Permute4(&states[0], &states[1], &states[2], &states[3])
+
+	for i := range states {
+		if got, want := states[i][:], want[i][:]; !bytes.Equal(got, want) {
+			t.Errorf("Permute4()[%d] = %x, want = %x", i, got, want)
+		}
+	}
+}
+
+func TestImplementation(t *testing.T) {
+	switch got := Implementation(); got {
+	case "amd64-aesni", "arm64-aes", "generic":
+	default:
+		t.Errorf("Implementation() = %q, want a known implementation", got)
+	}
+
+	t.Run("fallback", func(t *testing.T) {
+		defer func(b, b4 bool) { useAsm, useAsm4 = b, b4 }(useAsm, useAsm4)
+		useAsm, useAsm4 = false, false
+
+		if got, want := Implementation(), "generic"; got != want {
+			t.Errorf("Implementation() = %q, want = %q", got, want)
+		}
+
+		var state [Width]byte
+		Permute(&state)
+		if got, want := hex.EncodeToString(state[:16]), "5a7d4c12b2c4483055c5125c73c98edd"; got != want {
+			t.Errorf("Permute(0x00)[:16] = %s, want = %s", got, want)
+		}
+
+		var states [4][Width]byte
+		Permute4(&states[0], &states[1], &states[2], &states[3])
+		for i := range states {
+			if got, want := hex.EncodeToString(states[i][:16]), "5a7d4c12b2c4483055c5125c73c98edd"; got != want {
+				t.Errorf("Permute4(0x00)[%d][:16] = %s, want = %s", i, got, want)
+			}
+		}
+	})
+}
+
 func FuzzPermute(f *testing.F) {
 	drbg := testdata.New("simpira-1024-v2")
 	for range 10 {
@@ -25,14 +74,14 @@ func FuzzPermute(f *testing.F) {
 	}
 
 	f.Fuzz(func(t *testing.T, data []byte) {
-		if len(data) != Width {
+		if len(data) != Width || !useAsm {
 			t.Skip()
 		}
 
 		var state1, state2 [Width]byte
 		copy(state1[:], data)
 		copy(state2[:], data)
-		Permute(&state1)
+		permute(&state1)
 		permuteGeneric(&state2)
 
 		if got, want := state1[:], state2[:]; !bytes.Equal(got, want) {
@@ -49,3 +98,12 @@ func BenchmarkPermute(b *testing.B) {
 		Permute(&state)
 	}
 }
+
+func BenchmarkPermute4(b *testing.B) {
+	var states [4][128]byte
+	b.ReportAllocs()
+	b.SetBytes(int64(len(states) * len(states[0])))
+	for b.Loop() {
+		Permute4(&states[0], &states[1], &states[2], &states[3])
+	}
+}
@@ -0,0 +1,763 @@
+package vectors
+
+import (
+	"bytes"
+	"crypto/mlkem"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/frost"
+	"github.com/codahale/newplex/handshake"
+	"github.com/codahale/newplex/hpke"
+	"github.com/codahale/newplex/internal/kem"
+	"github.com/codahale/newplex/internal/testdata"
+	"github.com/codahale/newplex/oprf"
+	"github.com/codahale/newplex/pake"
+	"github.com/codahale/newplex/sig"
+	"github.com/codahale/newplex/signcrypt"
+	"github.com/codahale/newplex/vrf"
+	"github.com/gtank/ristretto255"
+)
+
+const (
+	sigDescription = "Each vector signs the message with sig.Sign(domain, private_key, rand, message). Private keys " +
+		"and public keys are canonical Ristretto255 scalar and element encodings."
+	hpkeDescription = "Each vector encrypts the plaintext with hpke.Seal(domain, receiver_public_key, " +
+		"sender_private_key, rand, plaintext)."
+	hpkeHybridDescription = "Each vector encrypts the plaintext with hpke.SealHybrid(domain, receiver_public_key, " +
+		"receiver_kem_encapsulation_key, sender_private_key, rand, plaintext). The receiver's ML-KEM-768 decapsulation " +
+		"key is generated from receiver_kem_seed, and the ML-KEM-768 encapsulation is derandomized with kem_rand."
+	signcryptDescription = "Each vector encrypts the message with signcrypt.Seal(domain, sender_private_key, " +
+		"receiver_public_key, rand, plaintext)."
+	handshakePatternsDescription = "Each vector performs a handshake.State handshake with the given pattern between an " +
+		"initiator and a responder, using the given random values as the parties' sources of randomness. Static keys " +
+		"which the pattern requires to be known in advance are the public keys of the given private keys. Each message " +
+		"carries the corresponding payload. If pre_shared_key is present, both parties use it as their pre-shared key. " +
+		"If hybrid is true, both parties use hybrid mode, and the responder's ML-KEM-768 encapsulation is derandomized " +
+		"with kem_rand. Messages are listed in order, starting with the initiator's. " +
+		"The outputs are derived as for the handshake vectors."
+	handshakeDescription = "Each vector performs a handshake with handshake.Initiate and handshake.Respond, using " +
+		"the given random values as the parties' sources of randomness. After the handshake, initiator_output is 32 " +
+		"bytes derived with the label \"vector\" from the initiator's send protocol (and the responder's receive " +
+		"protocol), and responder_output is the same from the responder's send protocol (and the initiator's receive " +
+		"protocol)."
+	pakeDescription = "Each vector performs a key exchange between an initiator and a responder. After the exchange, " +
+		"output is 32 bytes derived with the label \"vector\" from the shared protocol."
+	vrfDescription  = "Each vector calculates vrf.Prove(domain, private_key, rand, message, length)."
+	oprfDescription = "Each vector blinds the input with the given blind scalar, evaluates the blinded element with " +
+		"the private key, and finalizes the evaluated element. The output is equal to oprf.Evaluate(domain, " +
+		"private_key, input, length). VOPRF proofs are randomized and are not included."
+	frostDescription = "Each vector generates keys with frost.KeyGen(domain, max_signers, threshold, keygen_rand), " +
+		"commits each of the given signers with the corresponding commit_rands value, signs the message with each " +
+		"signer, and aggregates the signature shares."
+)
+
+var (
+	errInvalidSignature = errors.New("invalid signature")
+	errOutputMismatch   = errors.New("outputs differ")
+	errInvalidRand      = errors.New("invalid rand length")
+)
+
+type sigVector struct {
+	Domain     string   `json:"domain"`
+	PrivateKey hexBytes `json:"private_key"`
+	Rand       hexBytes `json:"rand"`
+	Message    hexBytes `json:"message"`
+	PublicKey  hexBytes `json:"public_key"`
+	Signature  hexBytes `json:"signature"`
+}
+
+func (v sigVector) compute() (sigVector, error) {
+	d, err := scalar(v.PrivateKey)
+	if err != nil {
+		return v, err
+	}
+	v.PublicKey = publicKey(d)
+
+	v.Signature, err = sig.Sign(v.Domain, d, v.Rand, bytes.NewReader(v.Message))
+	if err != nil {
+		return v, err
+	}
+
+	q, _ := element(v.PublicKey)
+	if valid, err := sig.Verify(v.Domain, q, v.Signature, bytes.NewReader(v.Message)); err != nil || !valid {
+		return v, errInvalidSignature
+	}
+	return v, nil
+}
+
+func sigVectors() []sigVector {
+	drbg := testdata.New("newplex vectors sig")
+
+	var vectors []sigVector
+	for _, n := range []int{0, 1, 100, 1000} {
+		d, _ := drbg.KeyPair()
+		vectors = append(vectors, sigVector{
+			Domain:     "newplex.vectors.sig",
+			PrivateKey: d.Bytes(),
+			Rand:       drbg.Data(64),
+			Message:    drbg.Data(n),
+		})
+	}
+	return vectors
+}
+
+type hpkeVector struct {
+	Domain             string   `json:"domain"`
+	SenderPrivateKey   hexBytes `json:"sender_private_key"`
+	ReceiverPrivateKey hexBytes `json:"receiver_private_key"`
+	Rand               hexBytes `json:"rand"`
+	Plaintext          hexBytes `json:"plaintext"`
+	SenderPublicKey    hexBytes `json:"sender_public_key"`
+	ReceiverPublicKey  hexBytes `json:"receiver_public_key"`
+	Ciphertext         hexBytes `json:"ciphertext"`
+}
+
+func (v hpkeVector) compute() (hpkeVector, error) {
+	dS, err := scalar(v.SenderPrivateKey)
+	if err != nil {
+		return v, err
+	}
+	dR, err := scalar(v.ReceiverPrivateKey)
+	if err != nil {
+		return v, err
+	}
+	v.SenderPublicKey, v.ReceiverPublicKey = publicKey(dS), publicKey(dR)
+	qS, _ := element(v.SenderPublicKey)
+	qR, _ := element(v.ReceiverPublicKey)
+
+	v.Ciphertext = hpke.Seal(v.Domain, qR, dS, v.Rand, v.Plaintext)
+	if _, err := hpke.Open(v.Domain, dR, qS, v.Ciphertext); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func hpkeVectors() []hpkeVector {
+	return hpkeInputs(testdata.New("newplex vectors hpke"), "newplex.vectors.hpke")
+}
+
+type hpkeHybridVector struct {
+	Domain                      string   `json:"domain"`
+	SenderPrivateKey            hexBytes `json:"sender_private_key"`
+	ReceiverPrivateKey          hexBytes `json:"receiver_private_key"`
+	ReceiverKEMSeed             hexBytes `json:"receiver_kem_seed"`
+	Rand                        hexBytes `json:"rand"`
+	KEMRand                     hexBytes `json:"kem_rand"`
+	Plaintext                   hexBytes `json:"plaintext"`
+	SenderPublicKey             hexBytes `json:"sender_public_key"`
+	ReceiverPublicKey           hexBytes `json:"receiver_public_key"`
+	ReceiverKEMEncapsulationKey hexBytes `json:"receiver_kem_encapsulation_key"`
+	Ciphertext                  hexBytes `json:"ciphertext"`
+}
+
+func (v hpkeHybridVector) compute() (hpkeHybridVector, error) {
+	dS, err := scalar(v.SenderPrivateKey)
+	if err != nil {
+		return v, err
+	}
+	dR, err := scalar(v.ReceiverPrivateKey)
+	if err != nil {
+		return v, err
+	}
+	dkR, err := mlkem.NewDecapsulationKey768(v.ReceiverKEMSeed)
+	if err != nil {
+		return v, err
+	}
+	if len(v.Rand) != 64 || len(v.KEMRand) != 32 {
+		return v, errInvalidRand
+	}
+	v.SenderPublicKey, v.ReceiverPublicKey = publicKey(dS), publicKey(dR)
+	v.ReceiverKEMEncapsulationKey = dkR.EncapsulationKey().Bytes()
+	qS, _ := element(v.SenderPublicKey)
+	qR, _ := element(v.ReceiverPublicKey)
+
+	restore := kem.SetTestRand(bytes.NewReader(v.KEMRand))
+	defer restore()
+
+	v.Ciphertext = hpke.SealHybrid(v.Domain, qR, dkR.EncapsulationKey(), dS, v.Rand, v.Plaintext)
+	if _, err := hpke.OpenHybrid(v.Domain, dR, dkR, qS, v.Ciphertext); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func hpkeHybridVectors() []hpkeHybridVector {
+	drbg := testdata.New("newplex vectors hpke-hybrid")
+
+	var vectors []hpkeHybridVector
+	for _, n := range []int{0, 1, 100, 1000} {
+		dS, _ := drbg.KeyPair()
+		dR, _ := drbg.KeyPair()
+		vectors = append(vectors, hpkeHybridVector{
+			Domain:             "newplex.vectors.hpke-hybrid",
+			SenderPrivateKey:   dS.Bytes(),
+			ReceiverPrivateKey: dR.Bytes(),
+			ReceiverKEMSeed:    drbg.Data(mlkem.SeedSize),
+			Rand:               drbg.Data(64),
+			KEMRand:            drbg.Data(32),
+			Plaintext:          drbg.Data(n),
+		})
+	}
+	return vectors
+}
+
+type signcryptVector hpkeVector
+
+func (v signcryptVector) compute() (signcryptVector, error) {
+	dS, err := scalar(v.SenderPrivateKey)
+	if err != nil {
+		return v, err
+	}
+	dR, err := scalar(v.ReceiverPrivateKey)
+	if err != nil {
+		return v, err
+	}
+	v.SenderPublicKey, v.ReceiverPublicKey = publicKey(dS), publicKey(dR)
+	qS, _ := element(v.SenderPublicKey)
+	qR, _ := element(v.ReceiverPublicKey)
+
+	v.Ciphertext = signcrypt.Seal(v.Domain, dS, qR, v.Rand, v.Plaintext)
+	if _, err := signcrypt.Open(v.Domain, dR, qS, v.Ciphertext); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func signcryptVectors() []signcryptVector {
+	var vectors []signcryptVector
+	for _, v := range hpkeInputs(testdata.New("newplex vectors signcrypt"), "newplex.vectors.signcrypt") {
+		vectors = append(vectors, signcryptVector(v))
+	}
+	return vectors
+}
+
+func hpkeInputs(drbg *testdata.DRBG, domain string) []hpkeVector {
+	var vectors []hpkeVector
+	for _, n := range []int{0, 1, 100, 1000} {
+		dS, _ := drbg.KeyPair()
+		dR, _ := drbg.KeyPair()
+		vectors = append(vectors, hpkeVector{
+			Domain:             domain,
+			SenderPrivateKey:   dS.Bytes(),
+			ReceiverPrivateKey: dR.Bytes(),
+			Rand:               drbg.Data(64),
+			Plaintext:          drbg.Data(n),
+		})
+	}
+	return vectors
+}
+
+type handshakeVector struct {
+	Domain                    string   `json:"domain"`
+	InitiatorStaticPrivateKey hexBytes `json:"initiator_static_private_key"`
+	ResponderStaticPrivateKey hexBytes `json:"responder_static_private_key"`
+	InitiatorRand             hexBytes `json:"initiator_rand"`
+	ResponderRand             hexBytes `json:"responder_rand"`
+	Request                   hexBytes `json:"request"`
+	Response                  hexBytes `json:"response"`
+	Confirmation              hexBytes `json:"confirmation"`
+	InitiatorOutput           hexBytes `json:"initiator_output"`
+	ResponderOutput           hexBytes `json:"responder_output"`
+}
+
+func (v handshakeVector) compute() (handshakeVector, error) {
+	dIS, err := scalar(v.InitiatorStaticPrivateKey)
+	if err != nil {
+		return v, err
+	}
+	dRS, err := scalar(v.ResponderStaticPrivateKey)
+	if err != nil {
+		return v, err
+	}
+
+	initiatorFinish, request, err := handshake.Initiate(v.Domain, dIS, bytes.NewReader(v.InitiatorRand))
+	if err != nil {
+		return v, err
+	}
+	responderFinish, response, err := handshake.Respond(v.Domain, bytes.NewReader(v.ResponderRand), dRS, request)
+	if err != nil {
+		return v, err
+	}
+	iSend, iRecv, _, confirmation, err := initiatorFinish(response)
+	if err != nil {
+		return v, err
+	}
+	rSend, rRecv, _, err := responderFinish(confirmation)
+	if err != nil {
+		return v, err
+	}
+
+	v.Request, v.Response, v.Confirmation = request, response, confirmation
+	if v.InitiatorOutput, err = output(iSend, rRecv); err != nil {
+		return v, err
+	}
+	if v.ResponderOutput, err = output(rSend, iRecv); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func handshakeVectors() []handshakeVector {
+	drbg := testdata.New("newplex vectors handshake")
+
+	var vectors []handshakeVector
+	for range 4 {
+		dIS, _ := drbg.KeyPair()
+		dRS, _ := drbg.KeyPair()
+		vectors = append(vectors, handshakeVector{
+			Domain:                    "newplex.vectors.handshake",
+			InitiatorStaticPrivateKey: dIS.Bytes(),
+			ResponderStaticPrivateKey: dRS.Bytes(),
+			InitiatorRand:             drbg.Data(64),
+			ResponderRand:             drbg.Data(64),
+		})
+	}
+	return vectors
+}
+
+type handshakePatternVector struct {
+	Pattern                   string     `json:"pattern"`
+	Domain                    string     `json:"domain"`
+	InitiatorStaticPrivateKey hexBytes   `json:"initiator_static_private_key,omitempty"`
+	ResponderStaticPrivateKey hexBytes   `json:"responder_static_private_key"`
+	PreSharedKey              hexBytes   `json:"pre_shared_key,omitempty"`
+	Hybrid                    bool       `json:"hybrid,omitempty"`
+	KEMRand                   hexBytes   `json:"kem_rand,omitempty"`
+	InitiatorRand             hexBytes   `json:"initiator_rand"`
+	ResponderRand             hexBytes   `json:"responder_rand"`
+	Payloads                  []hexBytes `json:"payloads"`
+	Messages                  []hexBytes `json:"messages"`
+	InitiatorOutput           hexBytes   `json:"initiator_output"`
+	ResponderOutput           hexBytes   `json:"responder_output"`
+}
+
+var handshakePatterns = []handshake.Pattern{
+	handshake.XX, handshake.IK, handshake.XK, handshake.NK, handshake.KK, handshake.N,
+}
+
+func (v handshakePatternVector) compute() (handshakePatternVector, error) {
+	i := slices.IndexFunc(handshakePatterns, func(p handshake.Pattern) bool { return p.String() == v.Pattern })
+	if i < 0 {
+		return v, fmt.Errorf("unknown pattern %q", v.Pattern)
+	}
+	pattern := handshakePatterns[i]
+	if len(v.Payloads) != pattern.Messages() {
+		return v, fmt.Errorf("got %d payloads, want %d", len(v.Payloads), pattern.Messages())
+	}
+
+	initiatorConfig := &handshake.Config{
+		Pattern:   pattern,
+		Domain:    v.Domain,
+		Initiator: true,
+		Hybrid:    v.Hybrid,
+		Rand:      bytes.NewReader(v.InitiatorRand),
+	}
+	responderConfig := &handshake.Config{
+		Pattern: pattern,
+		Domain:  v.Domain,
+		Hybrid:  v.Hybrid,
+		Rand:    bytes.NewReader(v.ResponderRand),
+	}
+	if v.Hybrid {
+		restore := kem.SetTestRand(bytes.NewReader(v.KEMRand))
+		defer restore()
+	}
+
+	if len(v.PreSharedKey) > 0 {
+		initiatorConfig.PreSharedKey = v.PreSharedKey
+		responderConfig.PreSharedKey = v.PreSharedKey
+	}
+
+	if len(v.InitiatorStaticPrivateKey) > 0 {
+		dIS, err := scalar(v.InitiatorStaticPrivateKey)
+		if err != nil {
+			return v, err
+		}
+		initiatorConfig.StaticKey = dIS
+		responderConfig.RemoteStaticKey, _ = element(publicKey(dIS))
+	}
+
+	dRS, err := scalar(v.ResponderStaticPrivateKey)
+	if err != nil {
+		return v, err
+	}
+	responderConfig.StaticKey = dRS
+	initiatorConfig.RemoteStaticKey, _ = element(publicKey(dRS))
+
+	initiator, err := handshake.New(initiatorConfig)
+	if err != nil {
+		return v, err
+	}
+	responder, err := handshake.New(responderConfig)
+	if err != nil {
+		return v, err
+	}
+
+	v.Messages = nil
+	writer, reader := initiator, responder
+	for _, payload := range v.Payloads {
+		msg, err := writer.WriteMessage(nil, payload)
+		if err != nil {
+			return v, err
+		}
+		if got, err := reader.ReadMessage(nil, msg); err != nil {
+			return v, err
+		} else if !bytes.Equal(got, payload) {
+			return v, errOutputMismatch
+		}
+		v.Messages = append(v.Messages, msg)
+		writer, reader = reader, writer
+	}
+
+	iSend, iRecv, err := initiator.Split()
+	if err != nil {
+		return v, err
+	}
+	rSend, rRecv, err := responder.Split()
+	if err != nil {
+		return v, err
+	}
+
+	if v.InitiatorOutput, err = output(iSend, rRecv); err != nil {
+		return v, err
+	}
+	if v.ResponderOutput, err = output(rSend, iRecv); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func handshakePatternVectors() []handshakePatternVector {
+	drbg := testdata.New("newplex vectors handshake-patterns")
+
+	var vectors []handshakePatternVector
+	for _, psk := range []bool{false, true} {
+		for _, pattern := range handshakePatterns {
+			dIS, _ := drbg.KeyPair()
+			dRS, _ := drbg.KeyPair()
+			v := handshakePatternVector{
+				Pattern:                   pattern.String(),
+				Domain:                    "newplex.vectors.handshake-patterns",
+				ResponderStaticPrivateKey: dRS.Bytes(),
+				InitiatorRand:             drbg.Data(64),
+				ResponderRand:             drbg.Data(64),
+			}
+			for i := range pattern.Messages() {
+				v.Payloads = append(v.Payloads, drbg.Data(i*10))
+			}
+			if pattern != handshake.NK && pattern != handshake.N {
+				v.InitiatorStaticPrivateKey = dIS.Bytes()
+			}
+			if psk {
+				v.PreSharedKey = drbg.Data(handshake.PreSharedKeySize)
+			}
+			vectors = append(vectors, v)
+		}
+	}
+
+	// Hybrid handshakes need 64 more bytes of randomness for the initiator's ML-KEM-768 key and 32 bytes for the
+	// responder's derandomized encapsulation.
+	for _, pattern := range handshakePatterns {
+		if pattern == handshake.N {
+			continue
+		}
+		dIS, _ := drbg.KeyPair()
+		dRS, _ := drbg.KeyPair()
+		v := handshakePatternVector{
+			Pattern:                   pattern.String(),
+			Domain:                    "newplex.vectors.handshake-patterns",
+			ResponderStaticPrivateKey: dRS.Bytes(),
+			Hybrid:                    true,
+			InitiatorRand:             drbg.Data(128),
+			ResponderRand:             drbg.Data(64),
+			KEMRand:                   drbg.Data(32),
+		}
+		for i := range pattern.Messages() {
+			v.Payloads = append(v.Payloads, drbg.Data(i*10))
+		}
+		if pattern != handshake.NK {
+			v.InitiatorStaticPrivateKey = dIS.Bytes()
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors
+}
+
+type pakeVector struct {
+	Domain           string   `json:"domain"`
+	InitiatorID      hexBytes `json:"initiator_id"`
+	ResponderID      hexBytes `json:"responder_id"`
+	SessionID        hexBytes `json:"session_id"`
+	Password         hexBytes `json:"password"`
+	InitiatorRand    hexBytes `json:"initiator_rand"`
+	ResponderRand    hexBytes `json:"responder_rand"`
+	InitiatorMessage hexBytes `json:"initiator_message"`
+	ResponderMessage hexBytes `json:"responder_message"`
+	Output           hexBytes `json:"output"`
+}
+
+func (v pakeVector) compute() (pakeVector, error) {
+	finish, initiatorMessage := pake.Initiate(v.Domain, v.InitiatorID, v.ResponderID, v.SessionID, v.Password, v.InitiatorRand)
+	responder, responderMessage, err := pake.Respond(v.Domain, v.InitiatorID, v.ResponderID, v.SessionID, v.Password, v.ResponderRand, initiatorMessage)
+	if err != nil {
+		return v, err
+	}
+	initiator, err := finish(responderMessage)
+	if err != nil {
+		return v, err
+	}
+
+	v.InitiatorMessage, v.ResponderMessage = initiatorMessage, responderMessage
+	if v.Output, err = output(initiator, responder); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func pakeVectors() []pakeVector {
+	drbg := testdata.New("newplex vectors pake")
+
+	var vectors []pakeVector
+	for _, password := range []string{"", "password", "correct horse battery staple"} {
+		vectors = append(vectors, pakeVector{
+			Domain:        "newplex.vectors.pake",
+			InitiatorID:   []byte("initiator"),
+			ResponderID:   []byte("responder"),
+			SessionID:     drbg.Data(16),
+			Password:      []byte(password),
+			InitiatorRand: drbg.Data(64),
+			ResponderRand: drbg.Data(64),
+		})
+	}
+	return vectors
+}
+
+// output derives 32 bytes from each of two protocols which should be in the same state, returning an error if they
+// differ.
+func output(a, b *newplex.Protocol) (hexBytes, error) {
+	x, y := a.Derive("vector", nil, 32), b.Derive("vector", nil, 32)
+	if !bytes.Equal(x, y) {
+		return nil, errOutputMismatch
+	}
+	return x, nil
+}
+
+type vrfVector struct {
+	Domain     string   `json:"domain"`
+	PrivateKey hexBytes `json:"private_key"`
+	Rand       hexBytes `json:"rand"`
+	Message    hexBytes `json:"message"`
+	Length     int      `json:"length"`
+	PublicKey  hexBytes `json:"public_key"`
+	Output     hexBytes `json:"output"`
+	Proof      hexBytes `json:"proof"`
+}
+
+func (v vrfVector) compute() (vrfVector, error) {
+	d, err := scalar(v.PrivateKey)
+	if err != nil {
+		return v, err
+	}
+	v.PublicKey = publicKey(d)
+	v.Output, v.Proof = vrf.Prove(v.Domain, d, v.Rand, v.Message, v.Length)
+
+	q, _ := element(v.PublicKey)
+	if valid, prf := vrf.Verify(v.Domain, q, v.Message, v.Proof, v.Length); !valid || !bytes.Equal(prf, v.Output) {
+		return v, errInvalidSignature
+	}
+	return v, nil
+}
+
+func vrfVectors() []vrfVector {
+	drbg := testdata.New("newplex vectors vrf")
+
+	var vectors []vrfVector
+	for _, n := range []int{0, 1, 100} {
+		d, _ := drbg.KeyPair()
+		vectors = append(vectors, vrfVector{
+			Domain:     "newplex.vectors.vrf",
+			PrivateKey: d.Bytes(),
+			Rand:       drbg.Data(64),
+			Message:    drbg.Data(n),
+			Length:     32,
+		})
+	}
+	return vectors
+}
+
+type oprfVector struct {
+	Domain           string   `json:"domain"`
+	PrivateKey       hexBytes `json:"private_key"`
+	Blind            hexBytes `json:"blind"`
+	Input            hexBytes `json:"input"`
+	Length           int      `json:"length"`
+	PublicKey        hexBytes `json:"public_key"`
+	BlindedElement   hexBytes `json:"blinded_element"`
+	EvaluatedElement hexBytes `json:"evaluated_element"`
+	Output           hexBytes `json:"output"`
+}
+
+func (v oprfVector) compute() (oprfVector, error) {
+	d, err := scalar(v.PrivateKey)
+	if err != nil {
+		return v, err
+	}
+	blind, err := scalar(v.Blind)
+	if err != nil {
+		return v, err
+	}
+	v.PublicKey = publicKey(d)
+
+	// oprf.Blind uses a random blind, so unblind its output to recover the input element and re-blind it with the
+	// vector's blind.
+	r, blinded, err := oprf.Blind(v.Domain, v.Input)
+	if err != nil {
+		return v, err
+	}
+	blinded.ScalarMult(ristretto255.NewScalar().Invert(r), blinded)
+	blinded.ScalarMult(blind, blinded)
+	v.BlindedElement = blinded.Bytes()
+
+	evaluated, err := oprf.BlindEvaluate(d, blinded)
+	if err != nil {
+		return v, err
+	}
+	v.EvaluatedElement = evaluated.Bytes()
+
+	if v.Output, err = oprf.Finalize(v.Domain, v.Input, blind, evaluated, v.Length); err != nil {
+		return v, err
+	}
+
+	prf, err := oprf.Evaluate(v.Domain, d, v.Input, v.Length)
+	if err != nil {
+		return v, err
+	}
+	if !bytes.Equal(prf, v.Output) {
+		return v, errOutputMismatch
+	}
+	return v, nil
+}
+
+func oprfVectors() []oprfVector {
+	drbg := testdata.New("newplex vectors oprf")
+
+	var vectors []oprfVector
+	for _, n := range []int{0, 1, 100} {
+		d, _ := drbg.KeyPair()
+		blind, _ := drbg.KeyPair()
+		vectors = append(vectors, oprfVector{
+			Domain:     "newplex.vectors.oprf",
+			PrivateKey: d.Bytes(),
+			Blind:      blind.Bytes(),
+			Input:      drbg.Data(n),
+			Length:     32,
+		})
+	}
+	return vectors
+}
+
+type frostVector struct {
+	Domain          string            `json:"domain"`
+	MaxSigners      int               `json:"max_signers"`
+	Threshold       int               `json:"threshold"`
+	KeyGenRand      hexBytes          `json:"keygen_rand"`
+	Signers         []uint16          `json:"signers"`
+	CommitRands     []hexBytes        `json:"commit_rands"`
+	Message         hexBytes          `json:"message"`
+	GroupKey        hexBytes          `json:"group_key"`
+	VerifyingShares []hexBytes        `json:"verifying_shares"`
+	Commitments     []frostCommitment `json:"commitments"`
+	SignatureShares []hexBytes        `json:"signature_shares"`
+	Signature       hexBytes          `json:"signature"`
+}
+
+type frostCommitment struct {
+	Identifier uint16   `json:"identifier"`
+	Hiding     hexBytes `json:"hiding"`
+	Binding    hexBytes `json:"binding"`
+}
+
+func (v frostVector) compute() (frostVector, error) {
+	groupKey, signers, verifyingShares, err := frost.KeyGen(v.Domain, v.MaxSigners, v.Threshold, v.KeyGenRand)
+	if err != nil {
+		return v, err
+	}
+	if len(v.Signers) != len(v.CommitRands) {
+		return v, frost.ErrInvalidParameters
+	}
+
+	v.GroupKey = groupKey.Bytes()
+	v.VerifyingShares = nil
+	for _, vs := range verifyingShares {
+		v.VerifyingShares = append(v.VerifyingShares, vs.Bytes())
+	}
+
+	nonces := make([]frost.Nonce, len(v.Signers))
+	commitments := make([]frost.Commitment, len(v.Signers))
+	v.Commitments = nil
+	for i, id := range v.Signers {
+		if id < 1 || int(id) > len(signers) {
+			return v, frost.ErrInvalidParameters
+		}
+		nonces[i], commitments[i] = signers[id-1].Commit(v.CommitRands[i])
+		v.Commitments = append(v.Commitments, frostCommitment{
+			Identifier: id,
+			Hiding:     commitments[i].Hiding,
+			Binding:    commitments[i].Binding,
+		})
+	}
+
+	shares := make([][]byte, len(v.Signers))
+	v.SignatureShares = nil
+	for i, id := range v.Signers {
+		shares[i], err = signers[id-1].Sign(v.Domain, nonces[i], v.Message, commitments)
+		if err != nil {
+			return v, err
+		}
+		if !frost.VerifyShare(v.Domain, verifyingShares[id-1], groupKey, id, v.Message, commitments, shares[i]) {
+			return v, errInvalidSignature
+		}
+		v.SignatureShares = append(v.SignatureShares, shares[i])
+	}
+
+	v.Signature, err = frost.Aggregate(v.Domain, groupKey, v.Message, commitments, shares)
+	if err != nil {
+		return v, err
+	}
+	if !frost.Verify(v.Domain, groupKey, v.Message, v.Signature) {
+		return v, errInvalidSignature
+	}
+	return v, nil
+}
+
+func frostVectors() []frostVector {
+	drbg := testdata.New("newplex vectors frost")
+
+	var vectors []frostVector
+	for _, params := range []struct {
+		maxSigners, threshold int
+		signers               []uint16
+	}{
+		{3, 2, []uint16{1, 3}},
+		{5, 3, []uint16{2, 4, 5}},
+		{5, 5, []uint16{1, 2, 3, 4, 5}},
+	} {
+		commitRands := make([]hexBytes, len(params.signers))
+		for i := range commitRands {
+			commitRands[i] = drbg.Data(64)
+		}
+		vectors = append(vectors, frostVector{
+			Domain:      "newplex.vectors.frost",
+			MaxSigners:  params.maxSigners,
+			Threshold:   params.threshold,
+			KeyGenRand:  drbg.Data(64),
+			Signers:     params.signers,
+			CommitRands: commitRands,
+			Message:     drbg.Data(100),
+		})
+	}
+	return vectors
+}
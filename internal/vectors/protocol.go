@@ -0,0 +1,141 @@
+package vectors
+
+import (
+	"fmt"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/internal/testdata"
+)
+
+const protocolDescription = "Each vector initializes a protocol with the given permutation and domain separation string, " +
+	"then performs the given operations in order. Mix, Mask, Unmask, Seal, and Open take an input; Derive takes an " +
+	"output length; Fork takes a list of branch values and continues with the given branch, or with the receiver if " +
+	"the branch is omitted; Ratchet takes no input."
+
+type protocolVector struct {
+	Permutation string       `json:"permutation"`
+	Domain      string       `json:"domain"`
+	Ops         []protocolOp `json:"ops"`
+}
+
+type protocolOp struct {
+	Op     string     `json:"op"`
+	Label  string     `json:"label"`
+	Input  hexBytes   `json:"input,omitempty"`
+	Length int        `json:"length,omitempty"`
+	Values []hexBytes `json:"values,omitempty"`
+	Branch *int       `json:"branch,omitempty"`
+	Output hexBytes   `json:"output,omitempty"`
+}
+
+var permutations = map[string]newplex.Permutation{
+	newplex.Simpira1024.String(): newplex.Simpira1024,
+	newplex.KeccakP1600.String(): newplex.KeccakP1600,
+}
+
+func (v protocolVector) compute() (protocolVector, error) {
+	perm, ok := permutations[v.Permutation]
+	if !ok {
+		return v, fmt.Errorf("unknown permutation %q", v.Permutation)
+	}
+
+	p := newplex.NewProtocolWith(v.Domain, perm)
+	out := v
+	out.Ops = make([]protocolOp, len(v.Ops))
+	for i, op := range v.Ops {
+		op.Output = nil
+		switch op.Op {
+		case "Mix":
+			p.Mix(op.Label, op.Input)
+		case "Derive":
+			op.Output = p.Derive(op.Label, nil, op.Length)
+		case "Mask":
+			op.Output = p.Mask(op.Label, nil, op.Input)
+		case "Unmask":
+			op.Output = p.Unmask(op.Label, nil, op.Input)
+		case "Seal":
+			op.Output = p.Seal(op.Label, nil, op.Input)
+		case "Open":
+			plaintext, err := p.Open(op.Label, nil, op.Input)
+			if err != nil {
+				return v, fmt.Errorf("op %d: %w", i, err)
+			}
+			op.Output = plaintext
+		case "Fork":
+			values := make([][]byte, len(op.Values))
+			for j, value := range op.Values {
+				values[j] = value
+			}
+			branches := p.ForkN(op.Label, values...)
+			if op.Branch != nil {
+				if *op.Branch < 0 || *op.Branch >= len(branches) {
+					return v, fmt.Errorf("op %d: invalid branch %d", i, *op.Branch)
+				}
+				p = branches[*op.Branch]
+			}
+		case "Ratchet":
+			p.Ratchet(op.Label)
+		default:
+			return v, fmt.Errorf("op %d: unknown operation %q", i, op.Op)
+		}
+		out.Ops[i] = op
+	}
+	return out, nil
+}
+
+func protocolVectors() []protocolVector {
+	drbg := testdata.New("newplex vectors protocol")
+	branch := func(i int) *int { return &i }
+
+	var vectors []protocolVector
+	for _, perm := range []newplex.Permutation{newplex.Simpira1024, newplex.KeccakP1600} {
+		// A single Derive, to check initialization.
+		vectors = append(vectors, protocolVector{
+			Permutation: perm.String(),
+			Domain:      "newplex.vectors.empty",
+			Ops: []protocolOp{
+				{Op: "Derive", Label: "output", Length: 32},
+			},
+		})
+
+		// Every operation, with inputs which span multiple blocks.
+		for _, n := range []int{0, 1, 94, 95, 200, 1000} {
+			// Seal on a clone to produce a valid ciphertext for Open.
+			sealer := newplex.NewProtocolWith("newplex.vectors.operations", perm)
+			key := drbg.Data(32)
+			sealer.Mix("key", key)
+			ciphertext := sealer.Seal("message", nil, drbg.Data(n))
+
+			vectors = append(vectors, protocolVector{
+				Permutation: perm.String(),
+				Domain:      "newplex.vectors.operations",
+				Ops: []protocolOp{
+					{Op: "Mix", Label: "key", Input: key},
+					{Op: "Open", Label: "message", Input: ciphertext},
+					{Op: "Mix", Label: "data", Input: drbg.Data(n)},
+					{Op: "Derive", Label: "prf", Length: n},
+					{Op: "Mask", Label: "masked", Input: drbg.Data(n)},
+					{Op: "Unmask", Label: "unmasked", Input: drbg.Data(n)},
+					{Op: "Seal", Label: "sealed", Input: drbg.Data(n)},
+					{Op: "Ratchet", Label: "ratchet"},
+					{Op: "Fork", Label: "role", Values: []hexBytes{[]byte("left"), []byte("right")}, Branch: branch(1)},
+					{Op: "Derive", Label: "output", Length: 32},
+				},
+			})
+		}
+
+		// Forks which continue on the receiver and on each branch.
+		for _, b := range []*int{nil, branch(0), branch(2)} {
+			vectors = append(vectors, protocolVector{
+				Permutation: perm.String(),
+				Domain:      "newplex.vectors.fork",
+				Ops: []protocolOp{
+					{Op: "Mix", Label: "key", Input: drbg.Data(32)},
+					{Op: "Fork", Label: "branch", Values: []hexBytes{[]byte("a"), []byte("b"), {}}, Branch: b},
+					{Op: "Derive", Label: "output", Length: 32},
+				},
+			})
+		}
+	}
+	return vectors
+}
@@ -0,0 +1,275 @@
+package vectors
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"io"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/aead"
+	"github.com/codahale/newplex/aestream"
+	"github.com/codahale/newplex/digest"
+	"github.com/codahale/newplex/internal/testdata"
+	"github.com/codahale/newplex/mhf"
+	"github.com/codahale/newplex/oae2"
+	"github.com/codahale/newplex/siv"
+)
+
+const (
+	aeadDescription = "Each vector creates an AEAD with aead.New(domain, key, len(nonce)) and seals the plaintext " +
+		"with the nonce and additional data."
+	sivDescription = "Each vector creates an AEAD with siv.New(domain, key, len(nonce)) and seals the plaintext with " +
+		"the nonce and additional data."
+	digestDescription = "Each vector hashes the message with digest.New(domain), or with digest.NewKeyed(domain, key) " +
+		"if a key is present."
+	aestreamDescription = "Each vector initializes a protocol with the domain separation string, mixes the key with " +
+		"the label \"key\", and encrypts the plaintext with an aestream.Writer in a single write."
+	aestreamParallelDescription = "Each vector initializes a protocol with the domain separation string, mixes the " +
+		"key with the label \"key\", and encrypts the plaintext with an aestream.ParallelWriter in a single write."
+	oae2Description = "Each vector initializes a protocol with the domain separation string, mixes the key with the " +
+		"label \"key\", and encrypts the plaintext with an oae2.Writer with the given block size in a single write."
+	mhfDescription = "Each vector calculates mhf.Hash(domain, cost, salt, password, nil, length)."
+)
+
+type aeadVector struct {
+	Domain         string   `json:"domain"`
+	Key            hexBytes `json:"key"`
+	Nonce          hexBytes `json:"nonce"`
+	AdditionalData hexBytes `json:"additional_data"`
+	Plaintext      hexBytes `json:"plaintext"`
+	Ciphertext     hexBytes `json:"ciphertext"`
+}
+
+func (v aeadVector) computeWith(newAEAD func(domain string, key []byte, nonceSize int) cipher.AEAD) (aeadVector, error) {
+	a := newAEAD(v.Domain, v.Key, len(v.Nonce))
+	v.Ciphertext = a.Seal(nil, v.Nonce, v.Plaintext, v.AdditionalData)
+	if _, err := a.Open(nil, v.Nonce, v.Ciphertext, v.AdditionalData); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func (v aeadVector) compute() (aeadVector, error) {
+	return v.computeWith(aead.New)
+}
+
+func aeadVectors() []aeadVector {
+	return aeadInputs(testdata.New("newplex vectors aead"), "newplex.vectors.aead")
+}
+
+type sivVector aeadVector
+
+func (v sivVector) compute() (sivVector, error) {
+	out, err := aeadVector(v).computeWith(siv.New)
+	return sivVector(out), err
+}
+
+func sivVectors() []sivVector {
+	var vectors []sivVector
+	for _, v := range aeadInputs(testdata.New("newplex vectors siv"), "newplex.vectors.siv") {
+		vectors = append(vectors, sivVector(v))
+	}
+	return vectors
+}
+
+func aeadInputs(drbg *testdata.DRBG, domain string) []aeadVector {
+	var vectors []aeadVector
+	for _, n := range []int{0, 1, 15, 16, 100, 1000} {
+		for _, adLen := range []int{0, 33} {
+			vectors = append(vectors, aeadVector{
+				Domain:         domain,
+				Key:            drbg.Data(32),
+				Nonce:          drbg.Data(16),
+				AdditionalData: drbg.Data(adLen),
+				Plaintext:      drbg.Data(n),
+			})
+		}
+	}
+	return vectors
+}
+
+type digestVector struct {
+	Domain  string   `json:"domain"`
+	Key     hexBytes `json:"key,omitempty"`
+	Message hexBytes `json:"message"`
+	Digest  hexBytes `json:"digest"`
+}
+
+func (v digestVector) compute() (digestVector, error) {
+	h := digest.New(v.Domain)
+	if len(v.Key) > 0 {
+		h = digest.NewKeyed(v.Domain, v.Key)
+	}
+	_, _ = h.Write(v.Message)
+	v.Digest = h.Sum(nil)
+	return v, nil
+}
+
+func digestVectors() []digestVector {
+	drbg := testdata.New("newplex vectors digest")
+
+	var vectors []digestVector
+	for _, n := range []int{0, 1, 94, 95, 1000, 10_000} {
+		vectors = append(vectors,
+			digestVector{Domain: "newplex.vectors.digest", Message: drbg.Data(n)},
+			digestVector{Domain: "newplex.vectors.digest", Key: drbg.Data(32), Message: drbg.Data(n)},
+		)
+	}
+	return vectors
+}
+
+type aestreamVector struct {
+	Domain     string   `json:"domain"`
+	Key        hexBytes `json:"key"`
+	Plaintext  hexBytes `json:"plaintext"`
+	Ciphertext hexBytes `json:"ciphertext"`
+}
+
+func (v aestreamVector) compute() (aestreamVector, error) {
+	buf := new(bytes.Buffer)
+	w := aestream.NewWriter(v.protocol(), buf)
+	if _, err := w.Write(v.Plaintext); err != nil {
+		return v, err
+	}
+	if err := w.Close(); err != nil {
+		return v, err
+	}
+	v.Ciphertext = buf.Bytes()
+
+	if _, err := io.ReadAll(aestream.NewReader(v.protocol(), bytes.NewReader(v.Ciphertext))); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func (v aestreamVector) protocol() *newplex.Protocol {
+	p := newplex.NewProtocol(v.Domain)
+	p.Mix("key", v.Key)
+	return p
+}
+
+func aestreamVectors() []aestreamVector {
+	drbg := testdata.New("newplex vectors aestream")
+
+	var vectors []aestreamVector
+	for _, n := range []int{0, 1, 100, 1000, aestream.MaxBlockSize + 1} {
+		vectors = append(vectors, aestreamVector{
+			Domain:    "newplex.vectors.aestream",
+			Key:       drbg.Data(32),
+			Plaintext: drbg.Data(n),
+		})
+	}
+	return vectors
+}
+
+type aestreamParallelVector aestreamVector
+
+func (v aestreamParallelVector) compute() (aestreamParallelVector, error) {
+	buf := new(bytes.Buffer)
+	w := aestream.NewParallelWriter(aestreamVector(v).protocol(), buf, 0)
+	if _, err := w.Write(v.Plaintext); err != nil {
+		return v, err
+	}
+	if err := w.Close(); err != nil {
+		return v, err
+	}
+	v.Ciphertext = buf.Bytes()
+
+	r := aestream.NewParallelReader(aestreamVector(v).protocol(), bytes.NewReader(v.Ciphertext), 0)
+	if _, err := io.ReadAll(r); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func aestreamParallelVectors() []aestreamParallelVector {
+	drbg := testdata.New("newplex vectors aestream-parallel")
+
+	var vectors []aestreamParallelVector
+	for _, n := range []int{0, 1, 100, 1000, aestream.MaxBlockSize + 1} {
+		vectors = append(vectors, aestreamParallelVector{
+			Domain:    "newplex.vectors.aestream-parallel",
+			Key:       drbg.Data(32),
+			Plaintext: drbg.Data(n),
+		})
+	}
+	return vectors
+}
+
+type oae2Vector struct {
+	Domain     string   `json:"domain"`
+	Key        hexBytes `json:"key"`
+	BlockSize  int      `json:"block_size"`
+	Plaintext  hexBytes `json:"plaintext"`
+	Ciphertext hexBytes `json:"ciphertext"`
+}
+
+func (v oae2Vector) compute() (oae2Vector, error) {
+	buf := new(bytes.Buffer)
+	w := oae2.NewWriter(v.protocol(), buf, v.BlockSize)
+	if _, err := w.Write(v.Plaintext); err != nil {
+		return v, err
+	}
+	if err := w.Close(); err != nil {
+		return v, err
+	}
+	v.Ciphertext = buf.Bytes()
+
+	if _, err := io.ReadAll(oae2.NewReader(v.protocol(), bytes.NewReader(v.Ciphertext), v.BlockSize)); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+func (v oae2Vector) protocol() *newplex.Protocol {
+	p := newplex.NewProtocol(v.Domain)
+	p.Mix("key", v.Key)
+	return p
+}
+
+func oae2Vectors() []oae2Vector {
+	drbg := testdata.New("newplex vectors oae2")
+
+	var vectors []oae2Vector
+	for _, blockSize := range []int{1, 64, 1024} {
+		for _, n := range []int{0, 1, 63, 64, 65, 1000} {
+			vectors = append(vectors, oae2Vector{
+				Domain:    "newplex.vectors.oae2",
+				Key:       drbg.Data(32),
+				BlockSize: blockSize,
+				Plaintext: drbg.Data(n),
+			})
+		}
+	}
+	return vectors
+}
+
+type mhfVector struct {
+	Domain   string   `json:"domain"`
+	Cost     uint8    `json:"cost"`
+	Salt     hexBytes `json:"salt"`
+	Password hexBytes `json:"password"`
+	Length   int      `json:"length"`
+	Output   hexBytes `json:"output"`
+}
+
+func (v mhfVector) compute() (mhfVector, error) {
+	v.Output = mhf.Hash(v.Domain, v.Cost, v.Salt, v.Password, nil, v.Length)
+	return v, nil
+}
+
+func mhfVectors() []mhfVector {
+	drbg := testdata.New("newplex vectors mhf")
+
+	var vectors []mhfVector
+	for _, cost := range []uint8{0, 1, 4, 8} {
+		vectors = append(vectors, mhfVector{
+			Domain:   "newplex.vectors.mhf",
+			Cost:     cost,
+			Salt:     drbg.Data(16),
+			Password: []byte("correct horse battery staple"),
+			Length:   32,
+		})
+	}
+	return vectors
+}
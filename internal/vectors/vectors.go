@@ -0,0 +1,203 @@
+// Package vectors generates and verifies known-answer test vectors for Newplex and the schemes built on it.
+//
+// Each scheme's vectors are encoded as a JSON object with the scheme's name, a description of how the vectors were
+// produced, and a list of vectors. Byte strings are hex-encoded. All inputs (including random values) are generated
+// with a deterministic DRBG, so the vectors are reproducible, and all outputs are calculated with this module's
+// implementations. Verification recalculates every vector's outputs from its inputs, checks that they match, and checks
+// that the inverse operations (e.g., Open, Verify) accept them.
+package vectors
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/gtank/ristretto255"
+)
+
+// ErrMismatch is returned when a vector's outputs do not match those calculated from its inputs.
+var ErrMismatch = errors.New("vectors: mismatch")
+
+// A File is the JSON encoding of a scheme's vectors.
+type File[V any] struct {
+	Scheme      string `json:"scheme"`
+	Description string `json:"description"`
+	Vectors     []V    `json:"vectors"`
+}
+
+// Names returns the names of all schemes with vectors, in the order in which they are generated.
+func Names() []string {
+	names := make([]string, len(schemes))
+	for i, s := range schemes {
+		names[i] = s.name
+	}
+	return names
+}
+
+// Generate returns the JSON encoding of the vectors for the scheme with the given name.
+func Generate(name string) ([]byte, error) {
+	s, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.generate()
+}
+
+// Verify checks the JSON-encoded vectors for the scheme with the given name against this module's implementation.
+func Verify(name string, data []byte) error {
+	s, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	return s.verify(data)
+}
+
+// A vector is a single test vector. Its compute method returns a copy of the vector with all outputs recalculated
+// from its inputs, or an error if an inverse operation failed.
+type vector[V any] interface {
+	compute() (V, error)
+}
+
+type scheme struct {
+	name     string
+	generate func() ([]byte, error)
+	verify   func(data []byte) error
+}
+
+var schemes = []scheme{
+	newScheme("protocol", protocolDescription, protocolVectors),
+	newScheme("aead", aeadDescription, aeadVectors),
+	newScheme("siv", sivDescription, sivVectors),
+	newScheme("digest", digestDescription, digestVectors),
+	newScheme("aestream", aestreamDescription, aestreamVectors),
+	newScheme("aestream-parallel", aestreamParallelDescription, aestreamParallelVectors),
+	newScheme("oae2", oae2Description, oae2Vectors),
+	newScheme("mhf", mhfDescription, mhfVectors),
+	newScheme("sig", sigDescription, sigVectors),
+	newScheme("hpke", hpkeDescription, hpkeVectors),
+	newScheme("hpke-hybrid", hpkeHybridDescription, hpkeHybridVectors),
+	newScheme("signcrypt", signcryptDescription, signcryptVectors),
+	newScheme("handshake", handshakeDescription, handshakeVectors),
+	newScheme("handshake-patterns", handshakePatternsDescription, handshakePatternVectors),
+	newScheme("pake", pakeDescription, pakeVectors),
+	newScheme("vrf", vrfDescription, vrfVectors),
+	newScheme("oprf", oprfDescription, oprfVectors),
+	newScheme("frost", frostDescription, frostVectors),
+}
+
+func newScheme[V vector[V]](name, description string, inputs func() []V) scheme {
+	return scheme{
+		name: name,
+		generate: func() ([]byte, error) {
+			f := File[V]{Scheme: name, Description: description}
+			for i, v := range inputs() {
+				v, err := v.compute()
+				if err != nil {
+					return nil, fmt.Errorf("vectors: %s vector %d: %w", name, i, err)
+				}
+				f.Vectors = append(f.Vectors, v)
+			}
+			return encode(f)
+		},
+		verify: func(data []byte) error {
+			var f File[V]
+			d := json.NewDecoder(bytes.NewReader(data))
+			d.DisallowUnknownFields()
+			if err := d.Decode(&f); err != nil {
+				return fmt.Errorf("vectors: %s: %w", name, err)
+			}
+
+			if f.Scheme != name {
+				return fmt.Errorf("vectors: %s: unexpected scheme %q", name, f.Scheme)
+			}
+
+			if len(f.Vectors) == 0 {
+				return fmt.Errorf("vectors: %s: no vectors", name)
+			}
+
+			for i, want := range f.Vectors {
+				got, err := want.compute()
+				if err != nil {
+					return fmt.Errorf("vectors: %s vector %d: %w", name, i, err)
+				}
+
+				// Compare the encoded vectors, which normalizes nil and empty byte strings.
+				a, err := json.Marshal(got)
+				if err != nil {
+					return err
+				}
+				b, err := json.Marshal(want)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(a, b) {
+					return fmt.Errorf("vectors: %s vector %d: %w: got %s, want %s", name, i, ErrMismatch, a, b)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func lookup(name string) (scheme, error) {
+	i := slices.IndexFunc(schemes, func(s scheme) bool { return s.name == name })
+	if i < 0 {
+		return scheme{}, fmt.Errorf("vectors: unknown scheme %q", name)
+	}
+	return schemes[i], nil
+}
+
+func encode(v any) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// hexBytes is a byte string which is hex-encoded in JSON.
+type hexBytes []byte
+
+func (h hexBytes) MarshalText() ([]byte, error) {
+	return hex.AppendEncode(nil, h), nil
+}
+
+func (h *hexBytes) UnmarshalText(text []byte) error {
+	b, err := hex.AppendDecode(nil, text)
+	if err != nil {
+		return err
+	}
+	*h = b
+	return nil
+}
+
+var (
+	errInvalidScalar  = errors.New("invalid scalar")
+	errInvalidElement = errors.New("invalid element")
+)
+
+// scalar decodes a canonically-encoded private key.
+func scalar(b hexBytes) (*ristretto255.Scalar, error) {
+	d, _ := ristretto255.NewScalar().SetCanonicalBytes(b)
+	if d == nil {
+		return nil, errInvalidScalar
+	}
+	return d, nil
+}
+
+// element decodes a canonically-encoded public key.
+func element(b hexBytes) (*ristretto255.Element, error) {
+	q, _ := ristretto255.NewIdentityElement().SetCanonicalBytes(b)
+	if q == nil {
+		return nil, errInvalidElement
+	}
+	return q, nil
+}
+
+// publicKey returns the encoded public key of the given private key.
+func publicKey(d *ristretto255.Scalar) hexBytes {
+	return ristretto255.NewIdentityElement().ScalarBaseMult(d).Bytes()
+}
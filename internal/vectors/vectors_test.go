@@ -0,0 +1,67 @@
+package vectors_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codahale/newplex/internal/vectors"
+)
+
+func TestVectors(t *testing.T) {
+	for _, name := range vectors.Names() {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("..", "..", "vectors", name+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := vectors.Verify(name, data); err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := vectors.Generate(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(data, want) {
+				t.Errorf("%s.json is out of date; run go run ./cmd/newplex-vectors", name)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	data, err := vectors.Generate("sig")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("modified output", func(t *testing.T) {
+		i := bytes.Index(data, []byte(`"signature": "`)) + len(`"signature": "`)
+		modified := bytes.Clone(data)
+		modified[i] = '0'
+		if data[i] == '0' {
+			modified[i] = '1'
+		}
+
+		if got, want := vectors.Verify("sig", modified), vectors.ErrMismatch; !errors.Is(got, want) {
+			t.Errorf("Verify() err = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("wrong scheme", func(t *testing.T) {
+		if err := vectors.Verify("hpke", data); err == nil {
+			t.Error("Verify() err = nil, want = error")
+		}
+	})
+
+	t.Run("unknown scheme", func(t *testing.T) {
+		if _, err := vectors.Generate("nope"); err == nil {
+			t.Error("Generate() err = nil, want = error")
+		}
+	})
+}
@@ -3,6 +3,13 @@
 // OAE2 allows for secure streaming of data with a fixed block size, providing confidentiality, integrity, and
 // authenticity. It protects against truncation, tampering, and block-reordering attacks by using a stateful
 // cryptographic protocol to authenticate each block in sequence.
+//
+// Streams written by NewSeekableWriter seal each block with a key derived from its index instead, and can be decrypted
+// with random access by a SeekableReader (e.g., to serve HTTP range requests of encrypted objects). Because their
+// blocks are independent, they can also be sealed and opened on multiple cores by a ParallelWriter and ParallelReader.
+//
+// To hide the length of the plaintext beyond the block size, a Writer may pad the stream according to a padding.Policy
+// (see Writer.SetPadding). The padding may span multiple blocks, and is removed transparently by a Reader.
 package oae2
 
 import (
@@ -10,6 +17,7 @@ import (
 	"io"
 
 	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/padding"
 )
 
 // A Writer buffers and encrypts data into discrete blocks, writing them to an underlying io.Writer.
@@ -23,6 +31,10 @@ type Writer struct {
 	buf       []byte // plaintext accumulator, flushed when it reaches blockSize
 	closed    bool   // true after Close returns, makes Close idempotent
 	err       error  // sticky write error; once set, all further operations fail
+	seekable  bool   // true if each block is sealed with a clone of p keyed with its index
+	index     uint64 // index of the next block, if seekable
+	padding   padding.Policy
+	n         int64 // total plaintext bytes written
 }
 
 // NewWriter returns an io.WriteCloser that buffers written data into blocks of the given size.
@@ -66,6 +78,7 @@ func (w *Writer) Write(data []byte) (n int, err error) {
 		w.buf = append(w.buf, toCopy...)
 		data = data[len(toCopy):]
 		written += len(toCopy)
+		w.n += int64(len(toCopy))
 
 		// Seal and emit each full block as an intermediate "block".
 		if len(w.buf) == w.blockSize {
@@ -91,15 +104,46 @@ func (w *Writer) Close() error {
 		return w.err
 	}
 	// Apply 0x80 bit padding to encode the plaintext length.
-	w.buf = pad(w.buf, w.blockSize)
+	w.buf = append(w.buf, 0x80)
+
+	// If the stream is padded, fill and seal blocks of zeros until the remaining padding fits in the final block.
+	if w.padding != nil {
+		total := w.n + 1
+		for extra := max(int64(w.padding(int(total))), total) - total; extra > int64(w.blockSize-len(w.buf)); {
+			extra -= int64(w.blockSize - len(w.buf))
+			w.buf = append(w.buf, make([]byte, w.blockSize-len(w.buf))...)
+			if err := w.flushBlock("block"); err != nil {
+				return err
+			}
+		}
+	}
+	w.buf = append(w.buf, make([]byte, w.blockSize-len(w.buf))...)
 
 	// Seal the padded final block with a distinct label to prevent truncation.
 	return w.flushBlock("final")
 }
 
+// SetPadding configures the writer to pad the stream according to the given policy when it is closed, or disables
+// padding if the policy is nil. The policy is applied to the total length of the plaintext plus the 0x80 padding byte,
+// and the padded length is rounded up to a multiple of the block size. Padding is disabled by default.
+//
+// Panics if the writer is seekable, since a SeekableReader only removes padding from the final block.
+func (w *Writer) SetPadding(policy padding.Policy) {
+	if w.seekable {
+		panic("oae2: seekable streams do not support padding")
+	}
+	w.padding = policy
+}
+
 // flushBlock seals the buffer with the given label and writes the ciphertext.
 func (w *Writer) flushBlock(label string) error {
-	ciphertext := w.p.Seal(label, nil, w.buf)
+	p := w.p
+	if w.seekable {
+		bp := blockProtocol(w.p, w.index)
+		p = &bp
+		w.index++
+	}
+	ciphertext := p.Seal(label, nil, w.buf)
 	_, err := w.w.Write(ciphertext)
 	if err != nil {
 		w.err = err
@@ -119,6 +163,10 @@ type Reader struct {
 	blockSize int
 	buf       []byte // decrypted plaintext not yet returned to the caller
 	err       error
+	marker    bool   // true if a released 0x80 byte must be returned before zeros and buf
+	zeros     int64  // released zero bytes which must be returned before buf
+	held      bool   // true if a trailing 0x80 byte has been held back as possible padding
+	heldZeros int64  // zero bytes following the held 0x80 byte
 	next      []byte // current ciphertext block buffer (reused across fills)
 	ahead     []byte // one-block-ahead lookahead buffer (swapped with next)
 	nextN     int    // valid bytes in next; 0 means next is empty
@@ -154,32 +202,30 @@ func (r *Reader) Read(p []byte) (int, error) {
 		return 0, nil
 	}
 
-	// Drain any buffered plaintext before reading more ciphertext.
-	if len(r.buf) > 0 {
-		n := copy(p, r.buf)
-		r.buf = r.buf[n:]
-		return n, nil
-	}
-	if r.err != nil {
-		return 0, r.err
-	}
-
-	// Decrypt the next block. An error is deferred if plaintext is available.
-	err := r.fill()
-	if err != nil {
-		r.err = err
-		if len(r.buf) == 0 {
-			return 0, err
+	// Decrypt blocks until plaintext is available. An error is deferred if plaintext is available.
+	for !r.marker && r.zeros == 0 && len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
 		}
+		r.err = r.fill()
 	}
 
-	if len(r.buf) > 0 {
-		n := copy(p, r.buf)
-		r.buf = r.buf[n:]
-		return n, nil
+	// Drain any released padding bytes, then the buffered plaintext.
+	n := 0
+	if r.marker {
+		p[0], r.marker, n = 0x80, false, 1
 	}
-
-	return 0, r.err
+	if k := int(min(r.zeros, int64(len(p)-n))); k > 0 {
+		clear(p[n : n+k])
+		r.zeros -= int64(k)
+		n += k
+	}
+	if r.zeros == 0 {
+		k := copy(p[n:], r.buf)
+		r.buf = r.buf[k:]
+		n += k
+	}
+	return n, nil
 }
 
 // fill decrypts one block from the underlying reader into r.buf.
@@ -235,16 +281,9 @@ func (r *Reader) fill() error {
 		return err
 	}
 
-	if isFinal {
-		// Strip 0x80 bit padding: scan backwards past zero bytes to find the 0x80 marker. Any other non-zero byte means
-		// invalid padding.
-		plaintext, err = unpad(plaintext)
-		if err != nil {
-			return newplex.ErrInvalidCiphertext
-		}
+	if err := r.unpad(plaintext, isFinal); err != nil {
+		return newplex.ErrInvalidCiphertext
 	}
-
-	r.buf = plaintext
 	if isFinal {
 		r.nextN = 0
 		r.final = true
@@ -257,6 +296,53 @@ func (r *Reader) fill() error {
 
 }
 
+// unpad buffers the given block, stripping the 0x80 bit padding from the end of the stream. Because a padded stream may
+// end with blocks of zeros, a 0x80 byte at the end of the non-zero bytes of a block, and any zero bytes following it,
+// are held back until a later block shows whether they are padding or plaintext.
+func (r *Reader) unpad(block []byte, isFinal bool) error {
+	// Find the last non-zero byte.
+	i := len(block) - 1
+	for i >= 0 && block[i] == 0x00 {
+		i--
+	}
+
+	switch {
+	case i < 0 && r.held:
+		// A block of zeros following a held 0x80 byte is either more padding or plaintext. If it's the final block, it
+		// is padding.
+		r.heldZeros += int64(len(block))
+		r.buf = nil
+		if isFinal {
+			r.held, r.heldZeros = false, 0
+		}
+	case i < 0 && isFinal:
+		return errInvalidPadding
+	case i < 0:
+		r.buf = block
+	case block[i] == 0x80:
+		// The held bytes, if any, are plaintext, and this 0x80 byte may be padding.
+		r.release()
+		r.buf = block[:i]
+		if !isFinal {
+			r.held, r.heldZeros = true, int64(len(block)-i-1)
+		}
+	case isFinal:
+		return errInvalidPadding
+	default:
+		r.release()
+		r.buf = block
+	}
+	return nil
+}
+
+// release marks the held 0x80 byte and zero bytes, if any, as plaintext.
+func (r *Reader) release() {
+	if r.held {
+		r.marker, r.zeros = true, r.heldZeros
+		r.held, r.heldZeros = false, 0
+	}
+}
+
 // pad appends 0x80 followed by zero bytes to buf until it reaches blockSize.
 func pad(buf []byte, blockSize int) []byte {
 	buf = append(buf, 0x80)
@@ -7,10 +7,12 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/codahale/newplex"
 	"github.com/codahale/newplex/internal/testdata"
 	"github.com/codahale/newplex/oae2"
+	"github.com/codahale/newplex/padding"
 )
 
 func TestNewReader(t *testing.T) {
@@ -240,6 +242,74 @@ func TestWriter_Write(t *testing.T) {
 	})
 }
 
+func TestWriter_SetPadding(t *testing.T) {
+	p := newplex.NewProtocol("test")
+	p.Mix("key", []byte("it's a key"))
+	drbg := testdata.New("newplex oae2 padding")
+	cipherLen := 64 + newplex.TagSize
+
+	// Plaintexts ending with bytes which look like padding, and blocks of zeros.
+	plaintexts := [][]byte{
+		nil,
+		[]byte("hello"),
+		drbg.Data(1000),
+		append(drbg.Data(63), 0x80),
+		append(append(drbg.Data(10), 0x80), make([]byte, 200)...),
+		append(append(drbg.Data(10), 0x80), make([]byte, 117)...),
+		make([]byte, 300),
+	}
+
+	for name, policy := range map[string]padding.Policy{
+		"none":    nil,
+		"pow2":    padding.PowerOfTwo,
+		"padme":   padding.Padme,
+		"buckets": padding.Buckets(1024, 4096),
+		"random":  padding.Random(1000),
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, plaintext := range plaintexts {
+				var buf bytes.Buffer
+				w := oae2.NewWriter(p.Clone(), &buf, 64)
+				w.SetPadding(policy)
+				if _, err := w.Write(plaintext); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+
+				padded := len(plaintext) + 1
+				if policy != nil && name != "random" {
+					padded = max(policy(padded), padded)
+				}
+				if got, want := buf.Len(), (padded+63)/64*cipherLen; name != "random" && got != want {
+					t.Errorf("len(ciphertext) = %d, want = %d", got, want)
+				}
+
+				// Read with small buffers to exercise draining held padding bytes.
+				r := oae2.NewReader(p.Clone(), &buf, 64)
+				got, err := io.ReadAll(iotest.OneByteReader(r))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(got, plaintext) {
+					t.Errorf("ReadAll() = %x, want = %x", got, plaintext)
+				}
+			}
+		})
+	}
+
+	t.Run("seekable", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected panic for seekable writer")
+			}
+		}()
+		oae2.NewSeekableWriter(p.Clone(), io.Discard, 64).SetPadding(padding.Padme)
+	})
+}
+
 func TestWriter_Close(t *testing.T) {
 	t.Run("idempotent close", func(t *testing.T) {
 		var buf bytes.Buffer
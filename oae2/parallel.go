@@ -0,0 +1,284 @@
+package oae2
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/codahale/newplex"
+)
+
+// A ParallelWriter encrypts data into a seekable stream, sealing batches of blocks on multiple goroutines.
+//
+// Because each block of a seekable stream is sealed with a key derived from its index, blocks can be sealed
+// independently of each other. The ParallelWriter buffers a batch of blocks for each goroutine, seals the batches
+// concurrently, and writes the ciphertext in order, so its output is identical to that of a Writer returned by
+// NewSeekableWriter. It can be read by a ParallelReader or a SeekableReader.
+type ParallelWriter struct {
+	p           *newplex.Protocol
+	w           io.Writer
+	blockSize   int
+	concurrency int
+	index       uint64 // index of the first block in buf
+	buf         []byte // plaintext accumulator, sealed when it holds a full batch
+	out         []byte // ciphertext buffer
+	closed      bool
+	err         error
+}
+
+// NewParallelWriter returns a ParallelWriter which seals blocks of the given size with the given number of goroutines.
+// If concurrency is less than 1, runtime.GOMAXPROCS(0) is used.
+//
+// The protocol's prior state must be probabilistic to ensure OAE2 security. The returned ParallelWriter MUST be closed
+// for the encrypted stream to be valid. The provided newplex.Protocol MUST NOT be used while the writer is open.
+func NewParallelWriter(p *newplex.Protocol, w io.Writer, blockSize, concurrency int) *ParallelWriter {
+	if blockSize < 1 {
+		panic("oae2: block size must be at least 1")
+	}
+	concurrency = concurrencyOrDefault(concurrency)
+	blocks := concurrency * batchBlocks(blockSize)
+	return &ParallelWriter{
+		p:           p,
+		w:           w,
+		blockSize:   blockSize,
+		concurrency: concurrency,
+		buf:         make([]byte, 0, blocks*blockSize),
+		out:         make([]byte, blocks*(blockSize+newplex.TagSize)),
+	}
+}
+
+// Write buffers data until a full batch of blocks is available, then seals the batch and writes it to the underlying
+// io.Writer.
+func (w *ParallelWriter) Write(data []byte) (n int, err error) {
+	if w.closed {
+		return 0, errors.New("oae2: Writer closed")
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	written := 0
+	for len(data) > 0 {
+		toCopy := data[:min(len(data), cap(w.buf)-len(w.buf))]
+		w.buf = append(w.buf, toCopy...)
+		data = data[len(toCopy):]
+		written += len(toCopy)
+
+		// Seal and emit the batch once it's full.
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close seals any remaining buffered data, along with the padded final block, and writes it to the underlying
+// io.Writer.
+func (w *ParallelWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.err != nil {
+		return w.err
+	}
+	return w.flush(true)
+}
+
+// flush seals the full blocks in the buffer, plus the padded final block if final is true, and writes them.
+func (w *ParallelWriter) flush(final bool) error {
+	blocks := len(w.buf) / w.blockSize
+	var tail []byte
+	if final {
+		tail = pad(w.buf[blocks*w.blockSize:], w.blockSize)
+		blocks++
+	}
+
+	cipherLen := w.blockSize + newplex.TagSize
+	parallelize(blocks, w.concurrency, func(i int) {
+		label, block := "block", w.buf[i*w.blockSize:(i+1)*w.blockSize]
+		if final && i == blocks-1 {
+			label, block = "final", tail
+		}
+		p := blockProtocol(w.p, w.index+uint64(i))
+		p.Seal(label, w.out[i*cipherLen:i*cipherLen], block)
+	})
+
+	if _, err := w.w.Write(w.out[:blocks*cipherLen]); err != nil {
+		// Discard the batch's plaintext and ciphertext, since the stream is unusable.
+		clear(w.buf[:cap(w.buf)])
+		clear(tail)
+		clear(w.out)
+		w.buf = w.buf[:0]
+		w.err = err
+		return err
+	}
+	w.index += uint64(blocks)
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// A ParallelReader reads and authenticates a seekable stream from an underlying io.Reader, opening batches of blocks
+// on multiple goroutines.
+//
+// Plaintext is only returned once every block in its batch has been authenticated. As with Reader, modified,
+// reordered, or truncated streams result in an error.
+type ParallelReader struct {
+	p           *newplex.Protocol
+	r           io.Reader
+	blockSize   int
+	concurrency int
+	index       uint64 // index of the first block in the next batch
+	in          []byte // ciphertext buffer, with one byte of lookahead past the batch
+	inN         int    // valid bytes in in, i.e. the lookahead byte from the previous batch
+	out         []byte // plaintext buffer
+	buf         []byte // decrypted plaintext not yet returned to the caller
+	final       bool   // true after the final block has been opened
+	err         error
+}
+
+// NewParallelReader returns a ParallelReader which opens blocks of the given size, written by a seekable Writer or a
+// ParallelWriter, with the given number of goroutines. If concurrency is less than 1, runtime.GOMAXPROCS(0) is used.
+//
+// The protocol state provided must be exactly synchronized with the protocol state used to initialize the writer. The
+// provided newplex.Protocol MUST NOT be used while the reader is open.
+func NewParallelReader(p *newplex.Protocol, r io.Reader, blockSize, concurrency int) *ParallelReader {
+	if blockSize < 1 {
+		panic("oae2: block size must be at least 1")
+	}
+	concurrency = concurrencyOrDefault(concurrency)
+	blocks := concurrency * batchBlocks(blockSize)
+	return &ParallelReader{
+		p:           p,
+		r:           r,
+		blockSize:   blockSize,
+		concurrency: concurrency,
+		in:          make([]byte, blocks*(blockSize+newplex.TagSize)+1),
+		out:         make([]byte, blocks*blockSize),
+	}
+}
+
+// Read reads and decrypts data from the underlying stream.
+//
+// It returns io.EOF when the stream is fully read and authenticated. If the stream is tampered with, truncated, or
+// incorrectly formatted, it returns newplex.ErrInvalidCiphertext.
+func (r *ParallelReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.fill()
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// fill reads and opens the next batch of blocks into r.buf.
+func (r *ParallelReader) fill() error {
+	if r.final {
+		return io.EOF
+	}
+
+	// Read a batch of blocks and one byte past it. A short read means the batch contains the final block.
+	n, err := io.ReadFull(r.r, r.in[r.inN:])
+	n += r.inN
+	switch {
+	case err == nil:
+		r.inN = 1
+		n--
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		r.final = true
+	default:
+		return err
+	}
+
+	cipherLen := r.blockSize + newplex.TagSize
+	if n == 0 || n%cipherLen != 0 {
+		return newplex.ErrInvalidCiphertext
+	}
+
+	blocks := n / cipherLen
+	var invalid atomic.Bool
+	parallelize(blocks, r.concurrency, func(i int) {
+		label := "block"
+		if r.final && i == blocks-1 {
+			label = "final"
+		}
+
+		p := blockProtocol(r.p, r.index+uint64(i))
+		if _, err := p.Open(label, r.out[i*r.blockSize:i*r.blockSize], r.in[i*cipherLen:(i+1)*cipherLen]); err != nil {
+			invalid.Store(true)
+		}
+	})
+	if invalid.Load() {
+		return newplex.ErrInvalidCiphertext
+	}
+
+	size := blocks * r.blockSize
+	if r.final {
+		plaintext, err := unpad(r.out[(blocks-1)*r.blockSize : size])
+		if err != nil {
+			return newplex.ErrInvalidCiphertext
+		}
+		size = (blocks-1)*r.blockSize + len(plaintext)
+	} else {
+		// Move the lookahead byte to the start of the buffer for the next batch.
+		r.in[0] = r.in[n]
+	}
+
+	r.index += uint64(blocks)
+	r.buf = r.out[:size]
+	return nil
+}
+
+// parallelize calls f for each index in [0, n), dividing the indexes into contiguous ranges across at most the given
+// number of goroutines, and waits for them to return.
+func parallelize(n, concurrency int, f func(i int)) {
+	per := (n + concurrency - 1) / concurrency
+	if per == 0 || n <= per {
+		for i := range n {
+			f(i)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for start := 0; start < n; start += per {
+		wg.Go(func() {
+			for i := start; i < min(start+per, n); i++ {
+				f(i)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// concurrencyOrDefault returns the given concurrency, or runtime.GOMAXPROCS(0) if it is less than 1.
+func concurrencyOrDefault(concurrency int) int {
+	if concurrency < 1 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return concurrency
+}
+
+// batchBlocks returns the number of blocks of the given size each goroutine processes per batch, which is enough to
+// make up at least 64 KiB of plaintext.
+func batchBlocks(blockSize int) int {
+	return max(1, (64*1024+blockSize-1)/blockSize)
+}
+
+var (
+	_ io.WriteCloser = (*ParallelWriter)(nil)
+	_ io.Reader      = (*ParallelReader)(nil)
+)
@@ -0,0 +1,275 @@
+package oae2_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"testing"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/internal/testdata"
+	"github.com/codahale/newplex/oae2"
+)
+
+// parallelSizes are plaintext sizes around the block and batch boundaries of a ParallelWriter with a 4 KiB block size
+// and a concurrency of 2, which has batches of 128 KiB.
+var parallelSizes = []int{0, 1, 4095, 4096, 4097, 128*1024 - 1, 128 * 1024, 128*1024 + 1, 300 * 1024}
+
+func TestParallelWriter(t *testing.T) {
+	p := newplex.NewProtocol("test")
+	p.Mix("key", []byte("it's a key"))
+	drbg := testdata.New("newplex oae2 parallel")
+
+	for _, n := range parallelSizes {
+		t.Run(fmt.Sprintf("%d bytes", n), func(t *testing.T) {
+			plaintext := drbg.Data(n)
+
+			var parallel bytes.Buffer
+			w := oae2.NewParallelWriter(p.Clone(), &parallel, 4096, 2)
+			// Write in uneven chunks to exercise buffering.
+			for chunk := range chunks(plaintext, 5000) {
+				if _, err := w.Write(chunk); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var sequential bytes.Buffer
+			sw := oae2.NewSeekableWriter(p.Clone(), &sequential, 4096)
+			if _, err := sw.Write(plaintext); err != nil {
+				t.Fatal(err)
+			}
+			if err := sw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(parallel.Bytes(), sequential.Bytes()) {
+				t.Fatal("ParallelWriter and seekable Writer output differ")
+			}
+
+			for _, concurrency := range []int{0, 1, 3} {
+				r := oae2.NewParallelReader(p.Clone(), bytes.NewReader(parallel.Bytes()), 4096, concurrency)
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatalf("ReadAll(concurrency=%d) err = %v", concurrency, err)
+				}
+
+				if !bytes.Equal(got, plaintext) {
+					t.Errorf("ReadAll(concurrency=%d) = %d bytes, want = %d bytes", concurrency, len(got), n)
+				}
+			}
+		})
+	}
+
+	t.Run("underlying writer error", func(t *testing.T) {
+		ew := &testdata.ErrWriter{Err: errors.New("write failed")}
+		w := oae2.NewParallelWriter(p.Clone(), ew, 64, 2)
+
+		if err := w.Close(); !errors.Is(err, ew.Err) {
+			t.Errorf("expected %v, got %v", ew.Err, err)
+		}
+
+		if _, err := w.Write([]byte("hello")); err == nil {
+			t.Error("Write() after Close() err = nil, want = err")
+		}
+	})
+
+	t.Run("underlying writer error on a full batch", func(t *testing.T) {
+		ew := &testdata.ErrWriter{Err: errors.New("write failed")}
+		w := oae2.NewParallelWriter(p.Clone(), ew, 64, 2)
+
+		if _, err := w.Write(make([]byte, 1<<20)); !errors.Is(err, ew.Err) {
+			t.Errorf("Write() err = %v, want = %v", err, ew.Err)
+		}
+
+		if _, err := w.Write([]byte("hello")); !errors.Is(err, ew.Err) {
+			t.Errorf("Write() err = %v, want = %v", err, ew.Err)
+		}
+
+		if err := w.Close(); !errors.Is(err, ew.Err) {
+			t.Errorf("Close() err = %v, want = %v", err, ew.Err)
+		}
+	})
+
+	t.Run("invalid block size", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected panic for blockSize=0")
+			}
+		}()
+		oae2.NewParallelWriter(p.Clone(), nil, 0, 2)
+	})
+}
+
+func TestParallelWriter_traced(t *testing.T) {
+	p := newplex.NewProtocol("test")
+	p.Mix("key", []byte("it's a key"))
+	plaintext := testdata.New("newplex oae2 parallel").Data(300 * 1024)
+
+	var writerLog, readerLog newplex.TraceLog
+	wp, rp := p.Clone(), p.Clone()
+	wp.SetTracer(&writerLog)
+	rp.SetTracer(&readerLog)
+
+	var buf bytes.Buffer
+	w := oae2.NewParallelWriter(wp, &buf, 4096, 4)
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := io.ReadAll(oae2.NewParallelReader(rp, &buf, 4096, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("ReadAll() = %d bytes, want = %d bytes", len(got), len(plaintext))
+	}
+
+	// Only the Start events are traced.
+	if got, want := len(writerLog)+len(readerLog), 2; got != want {
+		t.Errorf("len(events) = %d, want = %d", got, want)
+	}
+}
+
+func TestParallelReader(t *testing.T) {
+	p := newplex.NewProtocol("test")
+	p.Mix("key", []byte("it's a key"))
+	plaintext := testdata.New("newplex oae2 parallel").Data(300 * 1024)
+	cipherLen := 4096 + newplex.TagSize
+
+	var buf bytes.Buffer
+	w := oae2.NewParallelWriter(p.Clone(), &buf, 4096, 2)
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := buf.Bytes()
+
+	t.Run("seekable reader", func(t *testing.T) {
+		r, err := oae2.NewSeekableReader(p.Clone(), bytes.NewReader(ciphertext), int64(len(ciphertext)), 4096)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make([]byte, 10)
+		if _, err := r.ReadAt(got, 200*1024); err != nil {
+			t.Fatal(err)
+		}
+
+		if want := plaintext[200*1024 : 200*1024+10]; !bytes.Equal(got, want) {
+			t.Errorf("ReadAt() = %x, want = %x", got, want)
+		}
+	})
+
+	for name, modified := range map[string][]byte{
+		"empty":               nil,
+		"modified block":      modify(ciphertext, 40*cipherLen+10),
+		"reordered blocks":    swap(ciphertext, cipherLen),
+		"truncated mid-block": ciphertext[:len(ciphertext)-10],
+		"dropped final block": ciphertext[:len(ciphertext)-cipherLen],
+		"dropped batch":       ciphertext[32*cipherLen:],
+		"extra byte":          append(bytes.Clone(ciphertext), 0),
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := oae2.NewParallelReader(p.Clone(), bytes.NewReader(modified), 4096, 2)
+			if _, err := io.ReadAll(r); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+				t.Errorf("ReadAll() err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+			}
+		})
+	}
+
+	t.Run("underlying reader error", func(t *testing.T) {
+		er := &testdata.ErrReader{Err: errors.New("read failed")}
+		r := oae2.NewParallelReader(p.Clone(), er, 64, 2)
+
+		if _, err := r.Read(make([]byte, 100)); !errors.Is(err, er.Err) {
+			t.Errorf("expected %v, got %v", er.Err, err)
+		}
+	})
+}
+
+// chunks returns an iterator over consecutive chunks of b of at most the given size.
+func chunks(b []byte, size int) iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		for len(b) > 0 {
+			n := min(len(b), size)
+			if !yield(b[:n]) {
+				return
+			}
+			b = b[n:]
+		}
+	}
+}
+
+// modify returns a copy of b with the byte at the given index flipped.
+func modify(b []byte, i int) []byte {
+	b = bytes.Clone(b)
+	b[i] ^= 1
+	return b
+}
+
+// swap returns a copy of b with its first two blocks of the given size swapped.
+func swap(b []byte, size int) []byte {
+	swapped := bytes.Clone(b)
+	copy(swapped[:size], b[size:2*size])
+	copy(swapped[size:2*size], b[:size])
+	return swapped
+}
+
+func BenchmarkParallelWriter(b *testing.B) {
+	p := newplex.NewProtocol("example")
+	p.Mix("key", []byte("it's a key"))
+	plaintext := make([]byte, 16*1024*1024)
+
+	for _, concurrency := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("%d", concurrency), func(b *testing.B) {
+			b.SetBytes(int64(len(plaintext)))
+			b.ReportAllocs()
+			for b.Loop() {
+				w := oae2.NewParallelWriter(p.Clone(), io.Discard, 4096, concurrency)
+				if _, err := w.Write(plaintext); err != nil {
+					b.Fatal(err)
+				}
+				if err := w.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParallelReader(b *testing.B) {
+	p := newplex.NewProtocol("example")
+	p.Mix("key", []byte("it's a key"))
+	var ciphertext bytes.Buffer
+	w := oae2.NewParallelWriter(p.Clone(), &ciphertext, 4096, 0)
+	if _, err := w.Write(make([]byte, 16*1024*1024)); err != nil {
+		b.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, concurrency := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("%d", concurrency), func(b *testing.B) {
+			b.SetBytes(16 * 1024 * 1024)
+			b.ReportAllocs()
+			for b.Loop() {
+				r := oae2.NewParallelReader(p.Clone(), bytes.NewReader(ciphertext.Bytes()), 4096, concurrency)
+				if _, err := io.Copy(io.Discard, r); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package oae2
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/codahale/newplex"
+)
+
+// NewSeekableWriter returns a Writer whose output can be decrypted with random access by a SeekableReader.
+//
+// Unlike NewWriter, in which each block is sealed with the protocol's chained state, each block is sealed with a clone
+// of the protocol into which the block's index has been mixed, as in the STREAM construction. Blocks can therefore be
+// opened independently of each other, while reordered or spliced blocks still fail to open. As with NewWriter, the
+// final block is sealed with a distinct label, and the protocol's prior state must be probabilistic.
+//
+// The returned Writer MUST be closed for the encrypted stream to be valid. The provided newplex.Protocol MUST NOT be
+// used while the writer is open.
+func NewSeekableWriter(p *newplex.Protocol, w io.Writer, blockSize int) *Writer {
+	sw := NewWriter(p, w, blockSize)
+	sw.seekable = true
+	return sw
+}
+
+// A SeekableReader decrypts and authenticates arbitrary ranges of a stream written by a seekable Writer.
+//
+// It implements io.ReaderAt, io.ReadSeeker, and a Size method, so it can be used with http.ServeContent to serve range
+// requests of an encrypted object. ReadAt is safe for concurrent use; Read and Seek are not.
+type SeekableReader struct {
+	p         *newplex.Protocol
+	r         io.ReaderAt
+	blockSize int
+	blocks    int64 // number of ciphertext blocks, including the final block
+	size      int64 // plaintext size
+	off       int64 // offset of the next Read
+}
+
+// NewSeekableReader returns a SeekableReader which reads the stream of the given size, in bytes, from r.
+//
+// The protocol state provided must be exactly synchronized with the protocol state used to initialize the Writer. The
+// final block is opened to determine the plaintext size, so a stream which has been truncated to a whole number of
+// blocks is detected here. The provided newplex.Protocol MUST NOT be used while the reader is open.
+//
+// Returns newplex.ErrInvalidCiphertext if the size is not a positive multiple of the ciphertext block size or the final
+// block cannot be opened.
+func NewSeekableReader(p *newplex.Protocol, r io.ReaderAt, size int64, blockSize int) (*SeekableReader, error) {
+	if blockSize < 1 {
+		panic("oae2: block size must be at least 1")
+	}
+
+	cipherLen := int64(blockSize + newplex.TagSize)
+	if size <= 0 || size%cipherLen != 0 {
+		return nil, newplex.ErrInvalidCiphertext
+	}
+
+	sr := &SeekableReader{
+		p:         p,
+		r:         r,
+		blockSize: blockSize,
+		blocks:    size / cipherLen,
+	}
+
+	final, err := sr.openBlock(make([]byte, cipherLen), sr.blocks-1)
+	if err != nil {
+		return nil, err
+	}
+	sr.size = (sr.blocks-1)*int64(blockSize) + int64(len(final))
+
+	return sr, nil
+}
+
+// Size returns the size of the plaintext, in bytes.
+func (sr *SeekableReader) Size() int64 {
+	return sr.size
+}
+
+// ReadAt decrypts len(b) bytes of plaintext starting at the given offset into b, opening only the blocks which contain
+// them.
+//
+// It returns io.EOF if fewer than len(b) bytes remain after the offset. If any of the blocks have been modified, it
+// returns newplex.ErrInvalidCiphertext.
+func (sr *SeekableReader) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	buf := make([]byte, sr.blockSize+newplex.TagSize)
+	n := 0
+	for n < len(b) && off < sr.size {
+		index, start := off/int64(sr.blockSize), off%int64(sr.blockSize)
+		plaintext, err := sr.openBlock(buf, index)
+		if err != nil {
+			return n, err
+		}
+
+		copied := copy(b[n:], plaintext[start:])
+		n += copied
+		off += int64(copied)
+	}
+
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Read reads and decrypts data from the current offset.
+//
+// It returns io.EOF when the end of the stream is reached. If the stream is tampered with, it returns
+// newplex.ErrInvalidCiphertext.
+func (sr *SeekableReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	n, err := sr.ReadAt(p, sr.off)
+	sr.off += int64(n)
+	if n > 0 && errors.Is(err, io.EOF) {
+		return n, nil
+	}
+	return n, err
+}
+
+// Seek sets the offset of the next Read to offset, interpreted according to whence, and returns the new offset.
+func (sr *SeekableReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += sr.off
+	case io.SeekEnd:
+		offset += sr.size
+	default:
+		return 0, errInvalidWhence
+	}
+
+	if offset < 0 {
+		return 0, errNegativeOffset
+	}
+	sr.off = offset
+	return offset, nil
+}
+
+// openBlock reads the block with the given index into buf and opens it in place, returning the plaintext.
+func (sr *SeekableReader) openBlock(buf []byte, index int64) ([]byte, error) {
+	// ReadAt may return io.EOF along with a full block at the end of the input.
+	if n, err := sr.r.ReadAt(buf, index*int64(len(buf))); n < len(buf) {
+		if err == nil || errors.Is(err, io.EOF) {
+			return nil, newplex.ErrInvalidCiphertext
+		}
+		return nil, err
+	}
+
+	isFinal := index == sr.blocks-1
+	label := "block"
+	if isFinal {
+		label = "final"
+	}
+
+	bp := blockProtocol(sr.p, uint64(index))
+	plaintext, err := bp.Open(label, buf[:0], buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if isFinal {
+		plaintext, err = unpad(plaintext)
+		if err != nil {
+			return nil, newplex.ErrInvalidCiphertext
+		}
+	}
+	return plaintext, nil
+}
+
+// blockProtocol returns a clone of p with the given block index mixed in. The clone does not inherit p's tracer, so
+// blocks can be sealed and opened concurrently.
+func blockProtocol(p *newplex.Protocol, index uint64) newplex.Protocol {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], index)
+	bp := *p.Clone()
+	bp.Mix("index", b[:])
+	return bp
+}
+
+var (
+	errNegativeOffset = errors.New("oae2: negative offset")
+	errInvalidWhence  = errors.New("oae2: invalid whence")
+)
+
+var (
+	_ io.ReaderAt   = (*SeekableReader)(nil)
+	_ io.ReadSeeker = (*SeekableReader)(nil)
+)
@@ -0,0 +1,309 @@
+package oae2_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/internal/testdata"
+	"github.com/codahale/newplex/oae2"
+)
+
+// sealSeekable returns the plaintext and the seekable stream of it, written with the given block size.
+func sealSeekable(t *testing.T, p *newplex.Protocol, n, blockSize int) ([]byte, []byte) {
+	t.Helper()
+
+	plaintext := testdata.New("newplex oae2 seekable").Data(n)
+	var buf bytes.Buffer
+	w := oae2.NewSeekableWriter(p, &buf, blockSize)
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return plaintext, buf.Bytes()
+}
+
+func TestSeekableReader_ReadAt(t *testing.T) {
+	p := newplex.NewProtocol("test")
+	p.Mix("key", []byte("it's a key"))
+
+	for _, n := range []int{0, 1, 63, 64, 65, 200} {
+		plaintext, ciphertext := sealSeekable(t, p.Clone(), n, 64)
+		r, err := oae2.NewSeekableReader(p.Clone(), bytes.NewReader(ciphertext), int64(len(ciphertext)), 64)
+		if err != nil {
+			t.Fatalf("NewSeekableReader(n=%d) err = %v", n, err)
+		}
+
+		if got, want := r.Size(), int64(n); got != want {
+			t.Errorf("Size() = %d, want = %d", got, want)
+		}
+
+		for off := range n {
+			for end := off; end <= n; end++ {
+				b := make([]byte, end-off)
+				if _, err := r.ReadAt(b, int64(off)); err != nil {
+					t.Fatalf("ReadAt(n=%d, off=%d, len=%d) err = %v", n, off, len(b), err)
+				}
+
+				if got, want := b, plaintext[off:end]; !bytes.Equal(got, want) {
+					t.Fatalf("ReadAt(n=%d, off=%d, len=%d) = %x, want = %x", n, off, len(b), got, want)
+				}
+			}
+		}
+
+		b := make([]byte, 10)
+		off := max(n-5, 0)
+		if got, err := r.ReadAt(b, int64(off)); !errors.Is(err, io.EOF) || got != n-off {
+			t.Errorf("ReadAt(n=%d, past end) = %d/%v, want = %d/%v", n, got, err, n-off, io.EOF)
+		}
+	}
+}
+
+func TestSeekableReader_ReadAt_traced(t *testing.T) {
+	p := newplex.NewProtocol("test")
+	p.Mix("key", []byte("it's a key"))
+	plaintext, ciphertext := sealSeekable(t, p.Clone(), 1000, 64)
+
+	var log newplex.TraceLog
+	p.SetTracer(&log)
+	r, err := oae2.NewSeekableReader(p, bytes.NewReader(ciphertext), int64(len(ciphertext)), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for off := range 10 {
+		wg.Go(func() {
+			b := make([]byte, 100)
+			if _, err := r.ReadAt(b, int64(off*100)); err != nil {
+				t.Error(err)
+			} else if !bytes.Equal(b, plaintext[off*100:off*100+100]) {
+				t.Errorf("ReadAt(off=%d) = %x, want = %x", off*100, b, plaintext[off*100:off*100+100])
+			}
+		})
+	}
+	wg.Wait()
+
+	// Only the Start event is traced.
+	if got, want := len(log), 1; got != want {
+		t.Errorf("len(log) = %d, want = %d", got, want)
+	}
+}
+
+func TestSeekableReader_Read(t *testing.T) {
+	p := newplex.NewProtocol("test")
+	p.Mix("key", []byte("it's a key"))
+	plaintext, ciphertext := sealSeekable(t, p.Clone(), 200, 64)
+
+	r, err := oae2.NewSeekableReader(p.Clone(), bytes.NewReader(ciphertext), int64(len(ciphertext)), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("sequential", func(t *testing.T) {
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := plaintext; !bytes.Equal(got, want) {
+			t.Errorf("ReadAll() = %x, want = %x", got, want)
+		}
+	})
+
+	t.Run("seek", func(t *testing.T) {
+		for _, tc := range []struct {
+			offset int64
+			whence int
+			want   int64
+		}{
+			{100, io.SeekStart, 100},
+			{-10, io.SeekCurrent, 90},
+			{-20, io.SeekEnd, 180},
+		} {
+			pos, err := r.Seek(tc.offset, tc.whence)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if pos != tc.want {
+				t.Errorf("Seek(%d, %d) = %d, want = %d", tc.offset, tc.whence, pos, tc.want)
+			}
+		}
+
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := plaintext[180:]; !bytes.Equal(got, want) {
+			t.Errorf("ReadAll() = %x, want = %x", got, want)
+		}
+
+		if _, err := r.Seek(-1, io.SeekStart); err == nil {
+			t.Error("Seek(-1) err = nil, want = err")
+		}
+	})
+
+	t.Run("http range request", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			http.ServeContent(w, req, "", time.Time{}, r)
+		}))
+		defer s.Close()
+
+		req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Range", "bytes=60-129")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		got, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := plaintext[60:130]; resp.StatusCode != http.StatusPartialContent || !bytes.Equal(got, want) {
+			t.Errorf("GET %s = %d %x, want = %d %x", req.Header.Get("Range"), resp.StatusCode, got,
+				http.StatusPartialContent, want)
+		}
+	})
+}
+
+func TestNewSeekableReader(t *testing.T) {
+	p := newplex.NewProtocol("test")
+	p.Mix("key", []byte("it's a key"))
+	_, ciphertext := sealSeekable(t, p.Clone(), 200, 64)
+	cipherLen := 64 + newplex.TagSize
+
+	t.Run("invalid size", func(t *testing.T) {
+		for _, size := range []int{0, len(ciphertext) - 1} {
+			_, err := oae2.NewSeekableReader(p.Clone(), bytes.NewReader(ciphertext), int64(size), 64)
+			if !errors.Is(err, newplex.ErrInvalidCiphertext) {
+				t.Errorf("NewSeekableReader(size=%d) err = %v, want = %v", size, err, newplex.ErrInvalidCiphertext)
+			}
+		}
+	})
+
+	t.Run("short input", func(t *testing.T) {
+		_, err := oae2.NewSeekableReader(p.Clone(), bytes.NewReader(ciphertext[:cipherLen]), int64(len(ciphertext)), 64)
+		if !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("NewSeekableReader() err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+		}
+	})
+
+	t.Run("dropped final block", func(t *testing.T) {
+		truncated := ciphertext[:len(ciphertext)-cipherLen]
+		_, err := oae2.NewSeekableReader(p.Clone(), bytes.NewReader(truncated), int64(len(truncated)), 64)
+		if !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("NewSeekableReader() err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+		}
+	})
+
+	t.Run("sequential stream", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := oae2.NewWriter(p.Clone(), &buf, 64)
+		if _, err := w.Write(make([]byte, 200)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := oae2.NewSeekableReader(p.Clone(), bytes.NewReader(buf.Bytes()), int64(buf.Len()), 64)
+		if !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("NewSeekableReader() err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+		}
+	})
+
+	t.Run("invalid block size", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected panic for blockSize=0")
+			}
+		}()
+		_, _ = oae2.NewSeekableReader(p.Clone(), bytes.NewReader(ciphertext), int64(len(ciphertext)), 0)
+	})
+}
+
+func TestSeekableReader_ReadAt_modified(t *testing.T) {
+	p := newplex.NewProtocol("test")
+	p.Mix("key", []byte("it's a key"))
+	_, ciphertext := sealSeekable(t, p.Clone(), 200, 64)
+	cipherLen := 64 + newplex.TagSize
+
+	t.Run("modified block", func(t *testing.T) {
+		modified := bytes.Clone(ciphertext)
+		modified[cipherLen+5] ^= 1
+		r, err := oae2.NewSeekableReader(p.Clone(), bytes.NewReader(modified), int64(len(modified)), 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Blocks other than the modified one can still be read.
+		if _, err := r.ReadAt(make([]byte, 64), 0); err != nil {
+			t.Errorf("ReadAt(block 0) err = %v", err)
+		}
+
+		if _, err := r.ReadAt(make([]byte, 10), 70); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("ReadAt(block 1) err = %v, want = %v", err, newplex.ErrInvalidCiphertext)
+		}
+	})
+
+	t.Run("reordered blocks", func(t *testing.T) {
+		reordered := bytes.Clone(ciphertext)
+		copy(reordered[:cipherLen], ciphertext[cipherLen:2*cipherLen])
+		copy(reordered[cipherLen:2*cipherLen], ciphertext[:cipherLen])
+		r, err := oae2.NewSeekableReader(p.Clone(), bytes.NewReader(reordered), int64(len(reordered)), 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, off := range []int64{0, 64} {
+			if _, err := r.ReadAt(make([]byte, 10), off); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+				t.Errorf("ReadAt(off=%d) err = %v, want = %v", off, err, newplex.ErrInvalidCiphertext)
+			}
+		}
+	})
+}
+
+func BenchmarkSeekableReader_ReadAt(b *testing.B) {
+	p := newplex.NewProtocol("example")
+	p.Mix("key", []byte("it's a key"))
+
+	var ciphertext bytes.Buffer
+	w := oae2.NewSeekableWriter(p.Clone(), &ciphertext, 4096)
+	if _, err := w.Write(make([]byte, 1024*1024)); err != nil {
+		b.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	r, err := oae2.NewSeekableReader(p, bytes.NewReader(ciphertext.Bytes()), int64(ciphertext.Len()), 4096)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	buf := make([]byte, 16*1024)
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := r.ReadAt(buf, 512*1024+100); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
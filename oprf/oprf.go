@@ -6,6 +6,7 @@ import (
 	"errors"
 
 	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/transcript"
 	"github.com/gtank/ristretto255"
 )
 
@@ -68,7 +69,7 @@ func Finalize(domain string, input []byte, blind *ristretto255.Scalar, evaluated
 	p := newplex.NewProtocol(domain)
 	p.Mix("input", input)
 	_, prf := p.Fork("output", []byte("element"), []byte("prf"))
-	prf.Mix("unblinded-element", unblindedElement.Bytes())
+	transcript.AppendElement(prf, "unblinded-element", unblindedElement)
 	return prf.Derive("prf", nil, n), nil
 }
 
@@ -94,6 +95,6 @@ func Evaluate(domain string, d *ristretto255.Scalar, input []byte, n int) ([]byt
 	}
 
 	// Derive a bytestring from the input and the unblinded element.
-	prf.Mix("unblinded-element", evaluatedElement.Bytes())
+	transcript.AppendElement(prf, "unblinded-element", evaluatedElement)
 	return prf.Derive("prf", nil, n), nil
 }
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 
 	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/transcript"
 	"github.com/gtank/ristretto255"
 )
 
@@ -13,11 +14,11 @@ func computeCompositesFast(domain string, k *ristretto255.Scalar, b *ristretto25
 	}
 	m = ristretto255.NewIdentityElement()
 	p := newplex.NewProtocol(domain)
-	p.Mix("b", b.Bytes())
+	transcript.AppendElement(p, "b", b)
 	for i := range cM {
-		p.Mix("c", cM[i].Bytes())
-		p.Mix("d", dM[i].Bytes())
-		dI, _ := ristretto255.NewScalar().SetUniformBytes(p.Derive("scalar", nil, 64))
+		transcript.AppendElement(p, "c", cM[i])
+		transcript.AppendElement(p, "d", dM[i])
+		dI := transcript.ChallengeScalar(p, "scalar")
 		m.Add(m, ristretto255.NewIdentityElement().ScalarMult(dI, cM[i]))
 	}
 	z = ristretto255.NewIdentityElement().ScalarMult(k, m)
@@ -36,12 +37,12 @@ func generateProof(domain string, k *ristretto255.Scalar, a, b *ristretto255.Ele
 	t3 := ristretto255.NewIdentityElement().ScalarMult(r, m)
 
 	p := newplex.NewProtocol(domain)
-	p.Mix("b", b.Bytes())
-	p.Mix("m", m.Bytes())
-	p.Mix("z", z.Bytes())
-	p.Mix("t2", t2.Bytes())
-	p.Mix("t3", t3.Bytes())
-	c, _ = ristretto255.NewScalar().SetUniformBytes(p.Derive("challenge", nil, 64))
+	transcript.AppendElement(p, "b", b)
+	transcript.AppendElement(p, "m", m)
+	transcript.AppendElement(p, "z", z)
+	transcript.AppendElement(p, "t2", t2)
+	transcript.AppendElement(p, "t3", t3)
+	c = transcript.ChallengeScalar(p, "challenge")
 	s = ristretto255.NewScalar().Subtract(r, ristretto255.NewScalar().Multiply(c, k))
 	return c, s
 }
@@ -53,11 +54,11 @@ func computeComposites(domain string, b *ristretto255.Element, cM, dM []*ristret
 	m = ristretto255.NewIdentityElement()
 	z = ristretto255.NewIdentityElement()
 	p := newplex.NewProtocol(domain)
-	p.Mix("b", b.Bytes())
+	transcript.AppendElement(p, "b", b)
 	for i := range cM {
-		p.Mix("c", cM[i].Bytes())
-		p.Mix("d", dM[i].Bytes())
-		dI, _ := ristretto255.NewScalar().SetUniformBytes(p.Derive("scalar", nil, 64))
+		transcript.AppendElement(p, "c", cM[i])
+		transcript.AppendElement(p, "d", dM[i])
+		dI := transcript.ChallengeScalar(p, "scalar")
 		m.Add(m, ristretto255.NewIdentityElement().ScalarMult(dI, cM[i]))
 		z.Add(z, ristretto255.NewIdentityElement().ScalarMult(dI, dM[i]))
 	}
@@ -69,11 +70,11 @@ func verifyProof(domain string, a, b *ristretto255.Element, cM, dM []*ristretto2
 	t2 := ristretto255.NewIdentityElement().VarTimeMultiScalarMult([]*ristretto255.Scalar{s, c}, []*ristretto255.Element{a, b})
 	t3 := ristretto255.NewIdentityElement().VarTimeMultiScalarMult([]*ristretto255.Scalar{s, c}, []*ristretto255.Element{m, z})
 	p := newplex.NewProtocol(domain)
-	p.Mix("b", b.Bytes())
-	p.Mix("m", m.Bytes())
-	p.Mix("z", z.Bytes())
-	p.Mix("t2", t2.Bytes())
-	p.Mix("t3", t3.Bytes())
-	expectedC, _ := ristretto255.NewScalar().SetUniformBytes(p.Derive("challenge", nil, 64))
+	transcript.AppendElement(p, "b", b)
+	transcript.AppendElement(p, "m", m)
+	transcript.AppendElement(p, "z", z)
+	transcript.AppendElement(p, "t2", t2)
+	transcript.AppendElement(p, "t3", t3)
+	expectedC := transcript.ChallengeScalar(p, "challenge")
 	return c.Equal(expectedC) == 1
 }
@@ -0,0 +1,153 @@
+// Package padding implements length-hiding padding policies for aestream and oae2.
+//
+// A padding policy determines the length to which a message is padded before it is sealed, so that its ciphertext
+// reveals less about its exact length. Padding is applied inside the sealed block as a 0x80 byte followed by zero bytes
+// and stripped by the reader, so the reader does not need to know the policy.
+//
+// The policies trade bandwidth for privacy differently: PowerOfTwo reveals only the order of magnitude of a message's
+// length at a cost of up to 100% overhead, Padme reveals O(log log n) bits of a length n at a cost of at most 12%
+// overhead, Buckets reveals only which of a fixed set of sizes a message fits into, and Random adds a random amount of
+// padding, which hides small differences in length from an adversary who observes few messages.
+package padding
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A Policy returns the padded length of a message of n bytes, which is at least n.
+type Policy func(n int) int
+
+// PowerOfTwo pads a message to the next power of two.
+func PowerOfTwo(n int) int {
+	if n <= 1 {
+		return n
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// Padme pads a message using the Padmé scheme from Nikitin et al.'s "Reducing Metadata Leakage from Encrypted Files
+// and Communication with PURBs" (PETS 2019), which zeroes all but the most significant O(log log n) bits of its length.
+func Padme(n int) int {
+	if n <= 2 {
+		return n
+	}
+	e := bits.Len(uint(n)) - 1 // floor(log2(n))
+	s := bits.Len(uint(e))     // floor(log2(e)) + 1
+	mask := 1<<(e-s) - 1
+	return (n + mask) &^ mask
+}
+
+// Buckets returns a Policy which pads a message to the smallest of the given sizes which can hold it. Messages larger
+// than the largest size are padded to a multiple of it.
+//
+// Panics if no sizes are given or any size is not positive.
+func Buckets(sizes ...int) Policy {
+	if len(sizes) == 0 {
+		panic("newplex/padding: no bucket sizes")
+	}
+
+	sizes = slices.Sorted(slices.Values(sizes))
+	if sizes[0] < 1 {
+		panic("newplex/padding: bucket sizes must be positive")
+	}
+
+	return func(n int) int {
+		if i, _ := slices.BinarySearch(sizes, n); i < len(sizes) {
+			return sizes[i]
+		}
+		largest := sizes[len(sizes)-1]
+		return (n + largest - 1) / largest * largest
+	}
+}
+
+// Random returns a Policy which pads a message with a uniformly random number of bytes between 0 and limit, inclusive,
+// using crypto/rand.
+//
+// Panics if limit is negative.
+func Random(limit int) Policy {
+	if limit < 0 {
+		panic("newplex/padding: maximum random padding must not be negative")
+	}
+
+	return func(n int) int {
+		var b [8]byte
+		_, _ = rand.Read(b[:])
+		// The bias of the modulo is negligible for any reasonable maximum.
+		return n + int(binary.LittleEndian.Uint64(b[:])%uint64(limit+1))
+	}
+}
+
+// ErrInvalidPolicy is returned by Parse when the policy is not recognized.
+var ErrInvalidPolicy = errors.New("newplex/padding: invalid policy")
+
+// Parse returns the Policy described by the given string, for use in command line flags:
+//
+//   - "none" or "" returns nil, for no padding.
+//   - "pow2" returns PowerOfTwo.
+//   - "padme" returns Padme.
+//   - "buckets:256,1024,4096" returns Buckets with the given sizes.
+//   - "random:255" returns Random with the given maximum.
+func Parse(s string) (Policy, error) {
+	name, arg, _ := strings.Cut(s, ":")
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "pow2":
+		return PowerOfTwo, nil
+	case "padme":
+		return Padme, nil
+	case "buckets":
+		var sizes []int
+		for size := range strings.SplitSeq(arg, ",") {
+			n, err := strconv.Atoi(size)
+			if err != nil || n < 1 {
+				return nil, ErrInvalidPolicy
+			}
+			sizes = append(sizes, n)
+		}
+		return Buckets(sizes...), nil
+	case "random":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, ErrInvalidPolicy
+		}
+		return Random(n), nil
+	default:
+		return nil, ErrInvalidPolicy
+	}
+}
+
+// ErrInvalidPadding is returned by Unpad when a message is not padded correctly.
+var ErrInvalidPadding = errors.New("newplex/padding: invalid padding")
+
+// Pad appends a 0x80 byte to b, followed by zero bytes until it is n bytes long, and returns the resulting slice. If b
+// is n or more bytes long, only the 0x80 byte is appended.
+func Pad(b []byte, n int) []byte {
+	b = append(b, 0x80)
+	if len(b) < n {
+		b = append(b, make([]byte, n-len(b))...)
+	}
+	return b
+}
+
+// Unpad returns b with the trailing zero bytes and 0x80 byte appended by Pad removed.
+//
+// Returns ErrInvalidPadding if b does not end with a 0x80 byte followed by zero or more zero bytes.
+func Unpad(b []byte) ([]byte, error) {
+	for i := len(b) - 1; i >= 0; i-- {
+		switch b[i] {
+		case 0x80:
+			return b[:i], nil
+		case 0x00:
+		default:
+			return nil, ErrInvalidPadding
+		}
+	}
+	return nil, ErrInvalidPadding
+}
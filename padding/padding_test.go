@@ -0,0 +1,134 @@
+package padding_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/codahale/newplex/padding"
+)
+
+func TestPolicies(t *testing.T) {
+	for name, tc := range map[string]struct {
+		policy padding.Policy
+		want   map[int]int
+	}{
+		"pow2": {padding.PowerOfTwo, map[int]int{0: 0, 1: 1, 2: 2, 3: 4, 5: 8, 1000: 1024, 1024: 1024, 1025: 2048}},
+		"padme": {padding.Padme, map[int]int{
+			0: 0, 1: 1, 2: 2, 9: 10, 100: 104, 1000: 1024, 1025: 1088, 10_000: 10_240, 1_000_000: 1_015_808,
+		}},
+		"buckets": {padding.Buckets(1024, 256, 4096), map[int]int{
+			0: 256, 256: 256, 257: 1024, 4096: 4096, 4097: 8192, 10_000: 12_288,
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for n, want := range tc.want {
+				if got := tc.policy(n); got != want {
+					t.Errorf("policy(%d) = %d, want = %d", n, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("padme overhead", func(t *testing.T) {
+		for n := 1; n < 100_000; n++ {
+			if got := padding.Padme(n); got < n || float64(got-n)/float64(n) > 0.12 {
+				t.Fatalf("Padme(%d) = %d, want at most 12%% overhead", n, got)
+			}
+		}
+	})
+
+	t.Run("random", func(t *testing.T) {
+		policy := padding.Random(10)
+		seen := make(map[int]bool)
+		for range 1000 {
+			got := policy(100)
+			if got < 100 || got > 110 {
+				t.Fatalf("Random(10)(100) = %d, want between 100 and 110", got)
+			}
+			seen[got] = true
+		}
+
+		if got, want := len(seen), 11; got != want {
+			t.Errorf("len(seen) = %d, want = %d", got, want)
+		}
+	})
+}
+
+func TestParse(t *testing.T) {
+	for s, want := range map[string]map[int]int{
+		"pow2":              {5: 8},
+		"padme":             {9: 10},
+		"buckets:100,1000":  {5: 100, 500: 1000},
+		"random:0":          {5: 5},
+		"buckets:100, 1000": nil,
+		"buckets:":          nil,
+		"random:-1":         nil,
+		"random":            nil,
+		"pow3":              nil,
+	} {
+		t.Run(s, func(t *testing.T) {
+			policy, err := padding.Parse(s)
+			if want == nil {
+				if !errors.Is(err, padding.ErrInvalidPolicy) {
+					t.Errorf("Parse(%q) err = %v, want = %v", s, err, padding.ErrInvalidPolicy)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for n, want := range want {
+				if got := policy(n); got != want {
+					t.Errorf("Parse(%q)(%d) = %d, want = %d", s, n, got, want)
+				}
+			}
+		})
+	}
+
+	for _, s := range []string{"", "none"} {
+		if policy, err := padding.Parse(s); policy != nil || err != nil {
+			t.Errorf("Parse(%q) = %v/%v, want = nil/nil", s, policy, err)
+		}
+	}
+}
+
+func TestUnpad(t *testing.T) {
+	for _, b := range [][]byte{nil, []byte("hello"), {0x80, 0x00, 0x80}} {
+		for _, n := range []int{0, len(b) + 1, len(b) + 10} {
+			padded := padding.Pad(bytes.Clone(b), n)
+			if got, want := len(padded), max(n, len(b)+1); got != want {
+				t.Errorf("len(Pad(%x, %d)) = %d, want = %d", b, n, got, want)
+			}
+
+			got, err := padding.Unpad(padded)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, b) {
+				t.Errorf("Unpad(%x) = %x, want = %x", padded, got, b)
+			}
+		}
+	}
+
+	for _, b := range [][]byte{nil, {0x00, 0x00}, {0x80, 0x01}} {
+		if _, err := padding.Unpad(b); !errors.Is(err, padding.ErrInvalidPadding) {
+			t.Errorf("Unpad(%x) err = %v, want = %v", b, err, padding.ErrInvalidPadding)
+		}
+	}
+}
+
+func ExamplePadme() {
+	for _, n := range []int{100, 1000, 10_000, 100_000} {
+		fmt.Println(n, padding.Padme(n))
+	}
+	// Output:
+	// 100 104
+	// 1000 1024
+	// 10000 10240
+	// 100000 100352
+}
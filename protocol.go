@@ -5,8 +5,9 @@
 // processors at a 128-bit security level.
 //
 // On AMD64 and ARM64 architectures, newplex uses the AES-NI instruction set to achieve this level of performance. On
-// other architectures, or if the purego build tag is used, it uses a much-slower Go implementation with a bit-sliced,
-// constant-time AES round implementation.
+// other architectures, on processors which lack those instructions, or if the purego build tag is used, it uses a
+// much-slower Go implementation with a bit-sliced, constant-time AES round implementation. Use Implementation to
+// determine which is in use.
 //
 // [TupleHash]: https://www.nist.gov/publications/sha-3-derived-functions-cshake-kmac-tuplehash-and-parallelhash
 // [STROBE]: https://strobe.sourceforge.io
@@ -25,6 +26,7 @@ import (
 	"slices"
 
 	"github.com/codahale/newplex/internal/duplex"
+	"github.com/codahale/newplex/internal/simpira1024"
 )
 
 // TagSize is the number of bytes added to the plaintext by the Seal operation.
@@ -33,12 +35,59 @@ const TagSize = 16
 // ErrInvalidCiphertext is returned when the ciphertext is invalid or has been decrypted with the wrong key.
 var ErrInvalidCiphertext = errors.New("newplex: invalid ciphertext")
 
+// Implementation returns the name of the Simpira-1024 implementation in use: "amd64-aesni", "arm64-aes", or "generic".
+//
+// The generic implementation is used on processors without hardware AES support and is roughly three orders of
+// magnitude slower than the others.
+//
+// The parallel permutations used by Protocol.DeriveMany and digest.SumMany are only available on amd64 processors with
+// AVX-512 and VAES, which Implementation does not distinguish from other amd64 processors with AES-NI.
+func Implementation() string {
+	return simpira1024.Implementation()
+}
+
+// A Permutation identifies the cryptographic permutation underlying a Protocol.
+type Permutation int
+
+const (
+	// Simpira1024 is the Simpira-1024 permutation. It has a 1024-bit width and is the default permutation.
+	Simpira1024 Permutation = iota
+
+	// KeccakP1600 is the Keccak-p[1600,12] permutation, as used in TurboSHAKE and KangarooTwelve. It has a 1600-bit
+	// width and is well-suited for processors without hardware AES support.
+	KeccakP1600
+)
+
+// String returns the name of the permutation.
+func (perm Permutation) String() string {
+	switch perm {
+	case Simpira1024:
+		return "Simpira-1024"
+	case KeccakP1600:
+		return "Keccak-p[1600,12]"
+	default:
+		return fmt.Sprintf("Permutation(%d)", int(perm))
+	}
+}
+
+func (perm Permutation) duplex() duplex.Permutation {
+	switch perm {
+	case Simpira1024:
+		return duplex.Simpira1024
+	case KeccakP1600:
+		return duplex.KeccakP1600
+	default:
+		panic("newplex: unknown permutation")
+	}
+}
+
 // A Protocol is a stateful object providing fine-grained symmetric-key cryptographic services like hashing, message
 // authentication codes, pseudorandom functions, authenticated encryption, and more.
 //
 // Protocol instances are not concurrent-safe.
 type Protocol struct {
 	duplex    duplex.State
+	tracer    Tracer
 	streaming bool
 	cleared   bool
 }
@@ -53,9 +102,23 @@ func NewProtocol(domain string) *Protocol {
 	return &p
 }
 
+// NewProtocolWith creates a new Protocol with the given domain separation string and permutation.
+//
+// Protocols using different permutations are entirely independent: their outputs are unrelated even if they perform
+// the same sequence of operations with the same inputs. All parties to a protocol must use the same permutation.
+//
+// NewProtocolWith panics if the permutation is unknown.
+func NewProtocolWith(domain string, perm Permutation) *Protocol {
+	var p Protocol
+	p.duplex.Init(perm.duplex())
+	p.duplex.AbsorbHeader(opInit, domain)
+	return &p
+}
+
 // String returns a safe string representation of the protocol's state for debugging purposes.
 func (p *Protocol) String() string {
-	return fmt.Sprintf("Protocol(%x)", p.Clone().Derive("debug", nil, 8))
+	p.checkState()
+	return fmt.Sprintf("Protocol(%x)", p.fingerprint())
 }
 
 // Mix updates the protocol's state using the given label and input.
@@ -65,6 +128,7 @@ func (p *Protocol) Mix(label string, input []byte) {
 	p.checkState()
 	p.duplex.AbsorbHeader(opMix, label)
 	p.duplex.Absorb(input)
+	p.trace("Mix", opMix, label, len(input))
 }
 
 // Derive updates the protocol's state with the given label and output length and then generates n bytes of pseudorandom
@@ -83,9 +147,51 @@ func (p *Protocol) Derive(label string, dst []byte, n int) []byte {
 	ret, prf := sliceForAppend(dst, n)
 	p.duplex.Permute()
 	p.duplex.Squeeze(prf)
+	p.trace("Derive", opDerive, label, n)
 	return ret
 }
 
+// DeriveMany is equivalent to calling Derive with the given label and output length on each of the given protocols. It
+// appends each output to the corresponding element of dst and returns the resulting slices. If dst is nil, a new slice
+// is allocated.
+//
+// Where supported, the permutations of independent protocols are performed in parallel, providing much higher aggregate
+// throughput than sequential Derive calls for protocols with short transcripts (e.g., hashing many small messages).
+// Currently, this is only supported for Simpira-1024 protocols on amd64 processors with AVX-512 and VAES. On other
+// processors, including all arm64 processors and amd64 processors without VAES, and for Keccak-p[1600,12] protocols,
+// DeriveMany is no faster than sequential Derive calls.
+//
+// DeriveMany panics if n is negative, if dst is not nil and len(dst) != len(protocols), or if a streaming operation is
+// currently active on any of the protocols.
+func DeriveMany(protocols []*Protocol, label string, dst [][]byte, n int) [][]byte {
+	if n < 0 {
+		panic("invalid argument to DeriveMany: n cannot be negative")
+	}
+	if dst == nil {
+		dst = make([][]byte, len(protocols))
+	} else if len(dst) != len(protocols) {
+		panic("invalid argument to DeriveMany: mismatched slice lengths")
+	}
+
+	states := make([]*duplex.State, len(protocols))
+	for i, p := range protocols {
+		p.checkState()
+		p.duplex.AbsorbHeader(opDerive, label)
+		p.duplex.AbsorbLEB128(uint64(n))
+		states[i] = &p.duplex
+	}
+
+	duplex.PermuteMany(states)
+
+	for i, p := range protocols {
+		var prf []byte
+		dst[i], prf = sliceForAppend(dst[i], n)
+		p.duplex.Squeeze(prf)
+		p.trace("Derive", opDerive, label, n)
+	}
+	return dst
+}
+
 // Mask updates the protocol's state with the given label, then uses the state to encrypt the given plaintext. It
 // appends the ciphertext to dst and returns the resulting slice.
 //
@@ -104,6 +210,7 @@ func (p *Protocol) Mask(label string, dst, plaintext []byte) []byte {
 	p.duplex.AbsorbHeader(opCrypt, label)
 	p.duplex.Permute()
 	p.duplex.Encrypt(ciphertext, plaintext)
+	p.trace("Mask", opCrypt, label, len(plaintext))
 	return ret
 }
 
@@ -122,6 +229,7 @@ func (p *Protocol) Unmask(label string, dst, ciphertext []byte) []byte {
 	p.duplex.AbsorbHeader(opCrypt, label)
 	p.duplex.Permute()
 	p.duplex.Decrypt(plaintext, ciphertext)
+	p.trace("Unmask", opCrypt, label, len(ciphertext))
 	return ret
 }
 
@@ -145,6 +253,7 @@ func (p *Protocol) Seal(label string, dst, plaintext []byte) []byte {
 	p.duplex.Encrypt(ciphertext, plaintext)
 	p.duplex.Permute()
 	p.duplex.Squeeze(tag)
+	p.trace("Seal", opAuthCrypt, label, len(plaintext))
 	return ret
 }
 
@@ -178,6 +287,7 @@ func (p *Protocol) Open(label string, dst, ciphertextAndTag []byte) ([]byte, err
 	p.duplex.Decrypt(plaintext, ciphertext)
 	p.duplex.Permute()
 	p.duplex.Squeeze(expectedTag[:])
+	p.trace("Open", opAuthCrypt, label, len(plaintext))
 
 	if subtle.ConstantTimeCompare(receivedTag, expectedTag[:]) == 0 {
 		clear(plaintext)
@@ -207,6 +317,7 @@ func (p *Protocol) ForkN(label string, values ...[]byte) []*Protocol {
 
 	p.duplex.AbsorbHeader(opFork, label)
 	p.duplex.AbsorbByte(0)
+	p.trace("Fork", opFork, label, len(values))
 
 	return branches
 }
@@ -223,14 +334,17 @@ func (p *Protocol) Ratchet(label string) {
 	p.checkState()
 	p.duplex.AbsorbHeader(opRatchet, label)
 	p.duplex.Ratchet()
+	p.trace("Ratchet", opRatchet, label, 0)
 }
 
-// Clone returns a full clone of the receiver.
+// Clone returns a full clone of the receiver. The clone does not inherit the receiver's tracer.
 //
 // Clone panics if a streaming operation is currently active.
 func (p *Protocol) Clone() *Protocol {
 	p.checkState()
-	return new(*p)
+	clone := new(*p)
+	clone.tracer = nil
+	return clone
 }
 
 // Clear erases the protocol's state
@@ -266,6 +380,9 @@ func (p *Protocol) MarshalBinary() (data []byte, err error) {
 // UnmarshalBinary restores the protocol's state from the given binary representation. It implements
 // encoding.BinaryUnmarshaler.
 //
+// The binary representation must be of a protocol which uses the same permutation as p. To restore a protocol which
+// uses a permutation other than the default, call UnmarshalBinary on a protocol created with NewProtocolWith.
+//
 // UnmarshalBinary panics if a streaming operation is currently active.
 func (p *Protocol) UnmarshalBinary(data []byte) error {
 	p.checkState()
@@ -288,13 +405,15 @@ var (
 )
 
 const (
-	opInit      = 0x01 // Initialize a protocol with a domain separation string.
-	opMix       = 0x02 // Mix a labeled input value into the protocol's state.
-	opDerive    = 0x03 // Derive pseudorandom data from the protocol's state.
-	opCrypt     = 0x04 // Mask or decrypt an input value.
-	opAuthCrypt = 0x05 // Seal or open an input value.
-	opFork      = 0x06 // Fork a protocol into left and right branches.
-	opRatchet   = 0x07 // Ratchet a protocol's state to prevent rollback.
+	opInit       = 0x01 // Initialize a protocol with a domain separation string.
+	opMix        = 0x02 // Mix a labeled input value into the protocol's state.
+	opDerive     = 0x03 // Derive pseudorandom data from the protocol's state.
+	opCrypt      = 0x04 // Mask or decrypt an input value.
+	opAuthCrypt  = 0x05 // Seal or open an input value.
+	opFork       = 0x06 // Fork a protocol into left and right branches.
+	opRatchet    = 0x07 // Ratchet a protocol's state to prevent rollback.
+	opXOF        = 0x08 // Derive an unbounded stream of pseudorandom data from the protocol's state.
+	opAuthStream = 0x09 // Seal or open a stream of unknown length.
 )
 
 // sliceForAppend takes a slice and a requested number of bytes. It returns a slice with the contents of the given slice
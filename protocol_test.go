@@ -2,6 +2,7 @@ package newplex_test
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
 
 	"github.com/codahale/newplex"
@@ -15,6 +16,107 @@ func TestNewProtocol(t *testing.T) {
 	}
 }
 
+func TestNewProtocolWith(t *testing.T) {
+	t.Run("Simpira-1024", func(t *testing.T) {
+		p := newplex.NewProtocolWith("example", newplex.Simpira1024)
+		if got, want := p.Equal(newplex.NewProtocol("example")), 1; got != want {
+			t.Errorf("Equal() = %v, want = %v (Simpira-1024 should be the default)", got, want)
+		}
+	})
+
+	t.Run("Keccak-p[1600,12]", func(t *testing.T) {
+		p := newplex.NewProtocolWith("example", newplex.KeccakP1600)
+		if got, want := p.Equal(newplex.NewProtocol("example")), 0; got != want {
+			t.Errorf("Equal() = %v, want = %v (permutation separation failure)", got, want)
+		}
+
+		if got, want := p.String(), "Protocol(f3c6814c87d37c7a)"; got != want {
+			t.Errorf("String() = %s, want = %s", got, want)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		drbg := testdata.New("newplex keccak round trip")
+		plaintext := drbg.Data(1024)
+
+		p1 := newplex.NewProtocolWith("example", newplex.KeccakP1600)
+		p1.Mix("key", []byte("a secret key"))
+		ciphertext := p1.Seal("message", nil, plaintext)
+
+		p2 := newplex.NewProtocolWith("example", newplex.KeccakP1600)
+		p2.Mix("key", []byte("a secret key"))
+		got, err := p2.Open("message", nil, ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("Open() = %x, want = %x", got, plaintext)
+		}
+
+		p3 := newplex.NewProtocol("example")
+		p3.Mix("key", []byte("a secret key"))
+		if _, err := p3.Open("message", nil, ciphertext); err == nil {
+			t.Error("Open() with Simpira-1024 should have failed")
+		}
+	})
+
+	t.Run("marshal round trip", func(t *testing.T) {
+		p1 := newplex.NewProtocolWith("example", newplex.KeccakP1600)
+		p1.Mix("a thing", []byte("another thing"))
+
+		state, err := p1.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var simpira newplex.Protocol
+		if err := simpira.UnmarshalBinary(state); err == nil {
+			t.Error("UnmarshalBinary() into a Simpira-1024 protocol should have failed")
+		}
+
+		p2 := newplex.NewProtocolWith("other", newplex.KeccakP1600)
+		if err := p2.UnmarshalBinary(state); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := p2.Equal(p1), 1; got != want {
+			t.Errorf("Equal() = %v, want = %v (unmarshaled state should be equal)", got, want)
+		}
+
+		if got, want := p2.Derive("out", nil, 16), p1.Derive("out", nil, 16); !bytes.Equal(got, want) {
+			t.Errorf("Derive() = %x, want = %x", got, want)
+		}
+	})
+
+	t.Run("unknown permutation", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("NewProtocolWith() should have panicked")
+			}
+		}()
+		newplex.NewProtocolWith("example", newplex.Permutation(99))
+	})
+}
+
+func TestPermutation_String(t *testing.T) {
+	for perm, want := range map[newplex.Permutation]string{
+		newplex.Simpira1024:     "Simpira-1024",
+		newplex.KeccakP1600:     "Keccak-p[1600,12]",
+		newplex.Permutation(99): "Permutation(99)",
+	} {
+		if got := perm.String(); got != want {
+			t.Errorf("String() = %s, want = %s", got, want)
+		}
+	}
+}
+
+func TestImplementation(t *testing.T) {
+	if got := newplex.Implementation(); got == "" {
+		t.Error("Implementation() = \"\", want a non-empty name")
+	}
+}
+
 func TestProtocol_String(t *testing.T) {
 	p := newplex.NewProtocol("example")
 
@@ -252,6 +354,38 @@ func TestProtocol_Derive(t *testing.T) {
 	})
 }
 
+func TestDeriveMany(t *testing.T) {
+	for _, n := range []int{0, 1, 4, 9} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			drbg := testdata.New("newplex derive many")
+			protocols := make([]*newplex.Protocol, n)
+			want := make([][]byte, n)
+			for i := range protocols {
+				protocols[i] = newplex.NewProtocol("example")
+				protocols[i].Mix("input", drbg.Data(i*10))
+				want[i] = protocols[i].Clone().Derive("output", nil, 200)
+			}
+
+			got := newplex.DeriveMany(protocols, "output", nil, 200)
+			for i := range got {
+				if !bytes.Equal(got[i], want[i]) {
+					t.Errorf("DeriveMany()[%d] = %x, want = %x", i, got[i], want[i])
+				}
+			}
+		})
+	}
+
+	t.Run("mismatched lengths", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("The code did not panic")
+			}
+		}()
+
+		newplex.DeriveMany([]*newplex.Protocol{newplex.NewProtocol("example")}, "output", make([][]byte, 2), 16)
+	})
+}
+
 func TestProtocol_Mask(t *testing.T) {
 	t.Run("common prefixes", func(t *testing.T) {
 		short := make([]byte, 10)
@@ -6,6 +6,7 @@ import (
 	"io"
 
 	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/transcript"
 	"github.com/gtank/ristretto255"
 )
 
@@ -19,7 +20,7 @@ const Size = 64
 func Sign(domain string, d *ristretto255.Scalar, rand []byte, message io.Reader) ([]byte, error) {
 	// Initialize the protocol and mix in the signer's public key and the message.
 	p := newplex.NewProtocol(domain)
-	p.Mix("signer", ristretto255.NewIdentityElement().ScalarBaseMult(d).Bytes())
+	transcript.AppendElement(p, "signer", ristretto255.NewIdentityElement().ScalarBaseMult(d))
 	w := p.MixWriter("message", io.Discard)
 	_, err := io.Copy(w, message)
 	if err != nil {
@@ -32,26 +33,25 @@ func Sign(domain string, d *ristretto255.Scalar, rand []byte, message io.Reader)
 	// Fork the protocol into prover/verifier roles and mix both the signer's private key and the provided random data
 	// (if any) into the prover.
 	prover, verifier := p.Fork("role", []byte("prover"), []byte("verifier"))
-	prover.Mix("signer-private", d.Bytes())
+	transcript.AppendScalar(prover, "signer-private", d)
 	prover.Mix("hedged-rand", rand)
 
 	// Use the prover to derive a commitment scalar and commitment point which is guaranteed to be unique for the
 	// combination of signer and message. This eliminates the risk of private key recovery via nonce reuse, and the
 	// user-provided random data hedges the deterministic scheme against fault attacks.
-	k, _ := ristretto255.NewScalar().SetUniformBytes(prover.Derive("commitment", nil, 64))
+	k := transcript.ChallengeScalar(prover, "commitment")
 	r := ristretto255.NewIdentityElement().ScalarBaseMult(k)
-	rOut := r.Bytes()
 
 	// Mix the commitment point into the verifier.
-	verifier.Mix("commitment", rOut)
+	transcript.AppendElement(verifier, "commitment", r)
 
 	// Derive a challenge scalar from the verifier.
-	c, _ := ristretto255.NewScalar().SetUniformBytes(verifier.Derive("challenge", nil, 64))
+	c := transcript.ChallengeScalar(verifier, "challenge")
 
 	// Calculate the proof scalar s = k + d*c.
 	s := ristretto255.NewScalar().Multiply(d, c)
 	s = s.Add(s, k)
-	return append(rOut, s.Bytes()...), nil
+	return append(r.Bytes(), s.Bytes()...), nil
 }
 
 // Verify uses the given Ristretto255 public key and signature to verify the contents of the given reader. Returns true
@@ -66,7 +66,7 @@ func Verify(domain string, q *ristretto255.Element, sig []byte, message io.Reade
 
 	// Initialize the protocol and mix in the signer's public key and the message.
 	p := newplex.NewProtocol(domain)
-	p.Mix("signer", q.Bytes())
+	transcript.AppendElement(p, "signer", q)
 	w := p.MixWriter("message", io.Discard)
 	_, err := io.Copy(w, message)
 	if err != nil {
@@ -83,7 +83,7 @@ func Verify(domain string, q *ristretto255.Element, sig []byte, message io.Reade
 	verifier.Mix("commitment", sig[:32])
 
 	// Derive an expected challenge scalar from the signer's public key, the message, and the commitment point.
-	c, _ := ristretto255.NewScalar().SetUniformBytes(verifier.Derive("challenge", nil, 64))
+	c := transcript.ChallengeScalar(verifier, "challenge")
 
 	// Decode the proof scalar. If not canonically encoded, the signature is invalid.
 	s, _ := ristretto255.NewScalar().SetCanonicalBytes(sig[32:])
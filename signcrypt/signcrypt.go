@@ -5,6 +5,7 @@ import (
 	"crypto/subtle"
 
 	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/transcript"
 	"github.com/gtank/ristretto255"
 )
 
@@ -16,25 +17,25 @@ const Overhead = 32 + 32 + 32
 func Seal(domain string, dS *ristretto255.Scalar, qR *ristretto255.Element, rand, message []byte) []byte {
 	// Initialize the protocol and mix in the sender and receiver's public keys.
 	p := newplex.NewProtocol(domain)
-	p.Mix("receiver", qR.Bytes())
-	p.Mix("sender", ristretto255.NewIdentityElement().ScalarBaseMult(dS).Bytes())
+	transcript.AppendElement(p, "receiver", qR)
+	transcript.AppendElement(p, "sender", ristretto255.NewIdentityElement().ScalarBaseMult(dS))
 
 	// Fork the protocol into sender and receiver roles.
 	sender, receiver := p.Fork("role", []byte("sender"), []byte("receiver"))
 
 	// Mix the sender's private key, the user-supplied randomness, and the message into the sender. Use the sender to
 	// derive an ephemeral private key and commitment scalar which are unique to the inputs.
-	sender.Mix("sender-private", dS.Bytes())
+	transcript.AppendScalar(sender, "sender-private", dS)
 	sender.Mix("rand", rand)
 	sender.Mix("message", message)
-	dE, _ := ristretto255.NewScalar().SetUniformBytes(sender.Derive("ephemeral-private", nil, 64))
+	dE := transcript.ChallengeScalar(sender, "ephemeral-private")
 	qE := ristretto255.NewIdentityElement().ScalarBaseMult(dE)
-	k, _ := ristretto255.NewScalar().SetUniformBytes(sender.Derive("commitment", nil, 64))
+	k := transcript.ChallengeScalar(sender, "commitment")
 	r := ristretto255.NewIdentityElement().ScalarBaseMult(k)
 
 	// Mix the ephemeral public key and ECDH shared secret into the receiver.
-	receiver.Mix("ephemeral", qE.Bytes())
-	receiver.Mix("ecdh", ristretto255.NewIdentityElement().ScalarMult(dE, qR).Bytes())
+	transcript.AppendElement(receiver, "ephemeral", qE)
+	transcript.AppendElement(receiver, "ecdh", ristretto255.NewIdentityElement().ScalarMult(dE, qR))
 
 	// Mask the message.
 	ciphertext := receiver.Mask("message", qE.Bytes(), message)
@@ -44,7 +45,7 @@ func Seal(domain string, dS *ristretto255.Scalar, qR *ristretto255.Element, rand
 	sig := receiver.Mask("commitment", ciphertext, r.Bytes())
 
 	// Derive a challenge scalar from the signer's public key, the message, and the commitment point.
-	c, _ := ristretto255.NewScalar().SetUniformBytes(receiver.Derive("challenge", nil, 64))
+	c := transcript.ChallengeScalar(receiver, "challenge")
 
 	// Calculate the proof scalar s = k + d*c and mask it.
 	s := ristretto255.NewScalar().Multiply(dS, c)
@@ -61,8 +62,8 @@ func Open(domain string, dR *ristretto255.Scalar, qS *ristretto255.Element, ciph
 
 	// Initialize the protocol and mix in the sender and receiver's public keys.
 	p := newplex.NewProtocol(domain)
-	p.Mix("receiver", ristretto255.NewIdentityElement().ScalarBaseMult(dR).Bytes())
-	p.Mix("sender", qS.Bytes())
+	transcript.AppendElement(p, "receiver", ristretto255.NewIdentityElement().ScalarBaseMult(dR))
+	transcript.AppendElement(p, "sender", qS)
 
 	// Fork the protocol into sender and receiver roles.
 	_, receiver := p.Fork("role", []byte("sender"), []byte("receiver"))
@@ -75,7 +76,7 @@ func Open(domain string, dR *ristretto255.Scalar, qS *ristretto255.Element, ciph
 	}
 
 	// Mix in the ECDH shared secret.
-	receiver.Mix("ecdh", ristretto255.NewIdentityElement().ScalarMult(dR, qE).Bytes())
+	transcript.AppendElement(receiver, "ecdh", ristretto255.NewIdentityElement().ScalarMult(dR, qE))
 
 	// Unmask the message.
 	plaintext := receiver.Unmask("message", nil, ciphertext[32:len(ciphertext)-64])
@@ -84,7 +85,7 @@ func Open(domain string, dR *ristretto255.Scalar, qS *ristretto255.Element, ciph
 	receivedR := receiver.Unmask("commitment", nil, ciphertext[len(ciphertext)-64:len(ciphertext)-32])
 
 	// Derive an expected challenge scalar from the signer's public key, the message, and the commitment point.
-	expectedC, _ := ristretto255.NewScalar().SetUniformBytes(receiver.Derive("challenge", nil, 64))
+	expectedC := transcript.ChallengeScalar(receiver, "challenge")
 
 	// Unmask the proof scalar. If not canonically encoded, the signature is invalid.
 	s, _ := ristretto255.NewScalar().SetCanonicalBytes(receiver.Unmask("proof", nil, ciphertext[len(ciphertext)-32:]))
@@ -2,7 +2,10 @@ package newplex
 
 import (
 	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
 	"io"
+	"slices"
 )
 
 // MixWriter updates the protocol's state using the given label and whatever data is written to the wrapped io.Writer.
@@ -15,7 +18,7 @@ func (p *Protocol) MixWriter(label string, w io.Writer) *MixWriter {
 	p.checkState()
 	p.streaming = true
 	p.duplex.AbsorbHeader(opMix, label)
-	return &MixWriter{p: p, w: w, closed: false}
+	return &MixWriter{p: p, w: w, label: label, n: 0, closed: false}
 }
 
 // MixReader updates the protocol's state using the given label and whatever data is read from the wrapped io.Reader.
@@ -28,7 +31,7 @@ func (p *Protocol) MixReader(label string, r io.Reader) io.ReadCloser {
 	p.checkState()
 	p.streaming = true
 	p.duplex.AbsorbHeader(opMix, label)
-	return &mixReader{p: p, r: r, closed: false}
+	return &mixReader{p: p, r: r, label: label, n: 0, closed: false}
 }
 
 // MaskStream updates the protocol's state using the given label and returns a cipher.Stream which will mask any data
@@ -43,7 +46,7 @@ func (p *Protocol) MaskStream(label string) *CryptStream {
 	p.streaming = true
 	p.duplex.AbsorbHeader(opCrypt, label)
 	p.duplex.Permute()
-	return &CryptStream{p: p, f: p.duplex.Encrypt, closed: false}
+	return &CryptStream{p: p, f: p.duplex.Encrypt, op: "Mask", label: label, n: 0, closed: false}
 }
 
 // UnmaskStream updates the protocol's state using the given label and returns a cipher.Stream which will unmask any
@@ -59,13 +62,77 @@ func (p *Protocol) UnmaskStream(label string) *CryptStream {
 	p.streaming = true
 	p.duplex.AbsorbHeader(opCrypt, label)
 	p.duplex.Permute()
-	return &CryptStream{p: p, f: p.duplex.Decrypt, closed: false}
+	return &CryptStream{p: p, f: p.duplex.Decrypt, op: "Unmask", label: label, n: 0, closed: false}
+}
+
+// DeriveReader updates the protocol's state using the given label and returns an io.ReadCloser which will produce an
+// unbounded stream of pseudorandom data.
+//
+// Unlike Derive, DeriveReader does not require the output length to be known in advance. Consequently, the output of
+// DeriveReader is a prefix-closed stream: reading 10 bytes returns a prefix of the output from reading 16 bytes. Its
+// output is distinct from the output of any Derive operation with the same label.
+//
+// N.B.: The returned io.ReadCloser must be closed for the Derive operation to be complete. While the returned
+// io.ReadCloser is open, any other operation on the Protocol will panic.
+//
+// DeriveReader panics if a streaming operation is currently active.
+func (p *Protocol) DeriveReader(label string) io.ReadCloser {
+	p.checkState()
+	p.streaming = true
+	p.duplex.AbsorbHeader(opXOF, label)
+	p.duplex.Permute()
+	return &deriveReader{p: p, label: label, n: 0, closed: false}
+}
+
+// SealStream updates the protocol's state using the given label and returns an io.WriteCloser which will encrypt any
+// data written to it, writing the ciphertext to the wrapped io.Writer. When closed, it writes an authentication tag of
+// TagSize bytes to the wrapped io.Writer.
+//
+// Unlike Seal, SealStream does not require the plaintext length to be known in advance. Its ciphertexts are distinct
+// from those of Seal and Mask with the same label.
+//
+// N.B.: The returned io.WriteCloser must be closed for the Seal operation to be complete and the tag to be written.
+// While the returned io.WriteCloser is open, any other operation on the Protocol will panic. If a write to the wrapped
+// io.Writer fails or is short, the stream cannot be completed: Close returns the error, and the Protocol is cleared.
+//
+// SealStream panics if a streaming operation is currently active.
+func (p *Protocol) SealStream(label string, w io.Writer) io.WriteCloser {
+	p.checkState()
+	p.streaming = true
+	p.duplex.AbsorbHeader(opAuthStream, label)
+	p.duplex.Permute()
+	return &sealWriter{p: p, w: w, label: label, n: 0, closed: false}
+}
+
+// OpenStream updates the protocol's state using the given label and returns an io.ReadCloser which will decrypt a
+// stream produced by SealStream from the wrapped io.Reader, verifying the trailing authentication tag when the wrapped
+// io.Reader returns io.EOF.
+//
+// WARNING: The returned io.ReadCloser releases plaintext before it has been authenticated, holding back only the final
+// TagSize bytes of the stream. Callers MUST NOT act on the plaintext (e.g., parse it, execute it, or forward it) until
+// Read has returned io.EOF. If the stream has been modified or truncated, Read returns ErrInvalidCiphertext instead of
+// io.EOF, and all previously returned plaintext must be discarded. If the plaintext must be authenticated before it is
+// released, use Open or a chunked scheme like aestream instead.
+//
+// N.B.: The returned io.ReadCloser must be closed for the Open operation to be complete. While the returned
+// io.ReadCloser is open, any other operation on the Protocol will panic. If the stream has not been read to io.EOF and
+// authenticated, Close returns ErrInvalidCiphertext, and the Protocol is cleared.
+//
+// OpenStream panics if a streaming operation is currently active.
+func (p *Protocol) OpenStream(label string, r io.Reader) io.ReadCloser {
+	p.checkState()
+	p.streaming = true
+	p.duplex.AbsorbHeader(opAuthStream, label)
+	p.duplex.Permute()
+	return &openReader{p: p, r: r, label: label, n: 0, closed: false}
 }
 
 // MixWriter allows for the incremental processing of a stream of data into a single Mix operation on a protocol.
 type MixWriter struct {
 	p      *Protocol
 	w      io.Writer
+	label  string
+	n      int
 	closed bool
 }
 
@@ -74,12 +141,14 @@ type MixWriter struct {
 func (m *MixWriter) Branch() *Protocol {
 	p := *m.p // Using a copy instead of Clone to bypass the streaming flag.
 	p.streaming = false
+	p.tracer = nil
 	return &p
 }
 
 func (m *MixWriter) Write(p []byte) (n int, err error) {
 	n, err = m.w.Write(p)
 	m.p.duplex.Absorb(p[:n])
+	m.n += n
 	return n, err
 }
 
@@ -90,18 +159,22 @@ func (m *MixWriter) Close() error {
 	}
 	m.closed = true
 	m.p.streaming = false
+	m.p.trace("Mix", opMix, m.label, m.n)
 	return nil
 }
 
 type mixReader struct {
 	p      *Protocol
 	r      io.Reader
+	label  string
+	n      int
 	closed bool
 }
 
 func (m *mixReader) Read(p []byte) (n int, err error) {
 	n, err = m.r.Read(p)
 	m.p.duplex.Absorb(p[:n])
+	m.n += n
 	return n, err
 }
 
@@ -111,6 +184,179 @@ func (m *mixReader) Close() error {
 	}
 	m.closed = true
 	m.p.streaming = false
+	m.p.trace("Mix", opMix, m.label, m.n)
+	return nil
+}
+
+type deriveReader struct {
+	p      *Protocol
+	label  string
+	n      int
+	closed bool
+}
+
+func (d *deriveReader) Read(p []byte) (n int, err error) {
+	if d.closed {
+		return 0, errors.New("newplex: DeriveReader closed")
+	}
+	d.p.duplex.Squeeze(p)
+	d.n += len(p)
+	return len(p), nil
+}
+
+func (d *deriveReader) Close() error {
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	d.p.streaming = false
+	d.p.trace("DeriveReader", opXOF, d.label, d.n)
+	return nil
+}
+
+type sealWriter struct {
+	p      *Protocol
+	w      io.Writer
+	label  string
+	n      int
+	buf    []byte
+	err    error // sticky error from the wrapped io.Writer
+	closed bool
+}
+
+func (s *sealWriter) Write(p []byte) (n int, err error) {
+	if s.closed {
+		return 0, errors.New("newplex: SealStream closed")
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	s.buf = slices.Grow(s.buf[:0], len(p))[:len(p)]
+	s.p.duplex.Encrypt(s.buf, p)
+	s.n += len(p)
+
+	// The duplex has already encrypted all of p, so a short write leaves the stream unrecoverable.
+	n, err = s.w.Write(s.buf)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	s.err = err
+	return n, err
+}
+
+// Close ends the Seal operation, writes the authentication tag, and marks the underlying protocol as available for
+// other operations. If a write to the wrapped io.Writer failed, Close returns that error without writing the tag and
+// clears the underlying protocol.
+func (s *sealWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.p.streaming = false
+	if s.err != nil {
+		s.p.Clear()
+		return s.err
+	}
+
+	var tag [TagSize]byte
+	s.p.duplex.Permute()
+	s.p.duplex.Squeeze(tag[:])
+	s.p.trace("SealStream", opAuthStream, s.label, s.n)
+	_, err := s.w.Write(tag[:])
+	return err
+}
+
+type openReader struct {
+	p      *Protocol
+	r      io.Reader
+	label  string
+	n      int    // number of plaintext bytes released
+	buf    []byte // ciphertext buffer, the first held bytes of which are a potential tag
+	held   int    // number of bytes held back in buf
+	err    error  // sticky error; io.EOF once the tag has been verified
+	closed bool
+}
+
+func (o *openReader) Read(p []byte) (n int, err error) {
+	if o.err != nil {
+		return 0, o.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for empty := 0; empty < maxEmptyReads; {
+		// Read ciphertext into the buffer after any held-back bytes.
+		o.buf = slices.Grow(o.buf[:o.held], len(p))[:o.held+len(p)]
+		n, err = o.r.Read(o.buf[o.held:])
+		total := o.held + n
+		if n == 0 {
+			empty++
+		} else {
+			empty = 0
+		}
+
+		// At the end of the stream, the final TagSize bytes are the tag.
+		if errors.Is(err, io.EOF) {
+			return o.finish(p, total)
+		}
+
+		// Otherwise, release all but the final TagSize bytes, which may be the tag.
+		release := max(total-TagSize, 0)
+		o.p.duplex.Decrypt(p[:release], o.buf[:release])
+		o.n += release
+		o.held = copy(o.buf, o.buf[release:total])
+		if release > 0 || err != nil {
+			return release, err
+		}
+	}
+	return 0, io.ErrNoProgress
+}
+
+// maxEmptyReads is the number of consecutive reads returning no data and no error after which an openReader gives up,
+// as with bufio.Reader.
+const maxEmptyReads = 100
+
+func (o *openReader) finish(p []byte, total int) (int, error) {
+	if total < TagSize {
+		o.err = ErrInvalidCiphertext
+		return 0, o.err
+	}
+
+	n := total - TagSize
+	ciphertext, receivedTag := o.buf[:n], o.buf[n:total]
+	o.p.duplex.Decrypt(p[:n], ciphertext)
+	o.n += n
+
+	var expectedTag [TagSize]byte
+	o.p.duplex.Permute()
+	o.p.duplex.Squeeze(expectedTag[:])
+	if subtle.ConstantTimeCompare(receivedTag, expectedTag[:]) == 0 {
+		clear(p[:n])
+		o.err = ErrInvalidCiphertext
+		return 0, o.err
+	}
+
+	o.err = io.EOF
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// Close ends the Open operation and marks the underlying protocol as available for other operations. If the stream was
+// not authenticated, Close returns ErrInvalidCiphertext and clears the underlying protocol.
+func (o *openReader) Close() error {
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	o.p.streaming = false
+	o.p.trace("OpenStream", opAuthStream, o.label, o.n)
+	if !errors.Is(o.err, io.EOF) {
+		o.p.Clear()
+		return ErrInvalidCiphertext
+	}
 	return nil
 }
 
@@ -120,6 +366,9 @@ func (m *mixReader) Close() error {
 type CryptStream struct {
 	p      *Protocol
 	f      func(dst, src []byte)
+	op     string
+	label  string
+	n      int
 	closed bool
 }
 
@@ -133,6 +382,7 @@ type CryptStream struct {
 // Stream maintains state and does not reset at each XORKeyStream call.
 func (c *CryptStream) XORKeyStream(dst, src []byte) {
 	c.f(dst, src)
+	c.n += len(src)
 }
 
 // Close ends the Mask or Unmask operation and marks the underlying protocol as available for other operations.
@@ -142,11 +392,15 @@ func (c *CryptStream) Close() error {
 	}
 	c.closed = true
 	c.p.streaming = false
+	c.p.trace(c.op, opCrypt, c.label, c.n)
 	return nil
 }
 
 var (
 	_ io.WriteCloser = (*MixWriter)(nil)
 	_ io.ReadCloser  = (*mixReader)(nil)
+	_ io.ReadCloser  = (*deriveReader)(nil)
+	_ io.WriteCloser = (*sealWriter)(nil)
+	_ io.ReadCloser  = (*openReader)(nil)
 	_ cipher.Stream  = (*CryptStream)(nil)
 )
@@ -3,10 +3,13 @@ package newplex_test
 import (
 	"bytes"
 	"crypto/cipher"
+	"errors"
 	"io"
 	"testing"
+	"testing/iotest"
 
 	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/internal/testdata"
 )
 
 func TestProtocol_MixReader(t *testing.T) {
@@ -117,3 +120,347 @@ func TestProtocol_UnmaskStream(t *testing.T) {
 		t.Errorf("Equal() = %v, want %v (divergent protocol states)", got, want)
 	}
 }
+
+func TestProtocol_DeriveReader(t *testing.T) {
+	t.Run("prefix closed", func(t *testing.T) {
+		p1 := newplex.NewProtocol("example")
+		p1.Mix("key", []byte("onetwothree"))
+		r1 := p1.DeriveReader("stream")
+		short := make([]byte, 10)
+		if _, err := io.ReadFull(r1, short); err != nil {
+			t.Fatal(err)
+		}
+
+		p2 := newplex.NewProtocol("example")
+		p2.Mix("key", []byte("onetwothree"))
+		r2 := p2.DeriveReader("stream")
+		long := make([]byte, 1000)
+		for i := 0; i < len(long); i += 7 {
+			if _, err := io.ReadFull(r2, long[i:min(i+7, len(long))]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got, want := long[:len(short)], short; !bytes.Equal(got, want) {
+			t.Errorf("DeriveReader(1000)[:10] = %x, want = %x", got, want)
+		}
+	})
+
+	t.Run("distinct from Derive", func(t *testing.T) {
+		p1 := newplex.NewProtocol("example")
+		r := p1.DeriveReader("output")
+		got := make([]byte, 16)
+		if _, err := io.ReadFull(r, got); err != nil {
+			t.Fatal(err)
+		}
+
+		p2 := newplex.NewProtocol("example")
+		if want := p2.Derive("output", nil, 16); bytes.Equal(got, want) {
+			t.Errorf("DeriveReader(16) = Derive(16) = %x", got)
+		}
+	})
+
+	t.Run("state divergence", func(t *testing.T) {
+		p1 := newplex.NewProtocol("example")
+		r1 := p1.DeriveReader("output")
+		if _, err := io.ReadFull(r1, make([]byte, 10)); err != nil {
+			t.Fatal(err)
+		}
+		if err := r1.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		p2 := newplex.NewProtocol("example")
+		r2 := p2.DeriveReader("output")
+		if _, err := io.ReadFull(r2, make([]byte, 20)); err != nil {
+			t.Fatal(err)
+		}
+		if err := r2.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := p1.Equal(p2), 0; got != want {
+			t.Errorf("Equal() = %v, want %v (DeriveReader(10) and DeriveReader(20) states should differ)", got, want)
+		}
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("The code did not panic")
+			}
+		}()
+
+		p := newplex.NewProtocol("example")
+		_ = p.DeriveReader("output")
+		p.Mix("key", nil)
+	})
+
+	t.Run("read after close", func(t *testing.T) {
+		p := newplex.NewProtocol("example")
+		r := p.DeriveReader("output")
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := r.Read(make([]byte, 10)); err == nil {
+			t.Error("expected error reading from closed DeriveReader, got nil")
+		}
+	})
+}
+
+func TestProtocol_SealStream(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		message := bytes.Repeat([]byte("it's a message"), 100)
+
+		p1 := newplex.NewProtocol("example")
+		p1.Mix("key", []byte("it's a key"))
+		buf := bytes.NewBuffer(nil)
+		w := p1.SealStream("message", buf)
+		if _, err := io.CopyBuffer(w, bytes.NewReader(message), make([]byte, 7)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := buf.Len(), len(message)+newplex.TagSize; got != want {
+			t.Errorf("len(ciphertext) = %d, want = %d", got, want)
+		}
+
+		p2 := newplex.NewProtocol("example")
+		p2.Mix("key", []byte("it's a key"))
+		r := p2.OpenStream("message", iotest.HalfReader(bytes.NewReader(buf.Bytes())))
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if want := message; !bytes.Equal(got, want) {
+			t.Errorf("OpenStream(SealStream(msg)) = %x, want = %x", got, want)
+		}
+
+		if got, want := p1.Equal(p2), 1; got != want {
+			t.Errorf("Equal() = %v, want %v (divergent protocol states)", got, want)
+		}
+	})
+
+	t.Run("empty message", func(t *testing.T) {
+		p1 := newplex.NewProtocol("example")
+		buf := bytes.NewBuffer(nil)
+		w := p1.SealStream("message", buf)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		p2 := newplex.NewProtocol("example")
+		r := p2.OpenStream("message", bytes.NewReader(buf.Bytes()))
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 0 {
+			t.Errorf("OpenStream(SealStream(nil)) = %x, want empty", got)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("distinct from Seal", func(t *testing.T) {
+		p1 := newplex.NewProtocol("example")
+		buf := bytes.NewBuffer(nil)
+		w := p1.SealStream("message", buf)
+		if _, err := w.Write([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		p2 := newplex.NewProtocol("example")
+		if got, want := buf.Bytes(), p2.Seal("message", nil, []byte("hello")); bytes.Equal(got, want) {
+			t.Errorf("SealStream(msg) = Seal(msg) = %x", got)
+		}
+	})
+
+	t.Run("underlying writer error", func(t *testing.T) {
+		p := newplex.NewProtocol("example")
+		ew := &testdata.ErrWriter{Err: errors.New("write failed")}
+		w := p.SealStream("message", ew)
+
+		if _, err := w.Write([]byte("hello")); !errors.Is(err, ew.Err) {
+			t.Errorf("Write() err = %v, want = %v", err, ew.Err)
+		}
+
+		if _, err := w.Write([]byte("hello")); !errors.Is(err, ew.Err) {
+			t.Errorf("Write() err = %v, want = %v", err, ew.Err)
+		}
+
+		if err := w.Close(); !errors.Is(err, ew.Err) {
+			t.Errorf("Close() err = %v, want = %v", err, ew.Err)
+		}
+
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Mix() after a failed SealStream should have panicked")
+			}
+		}()
+		p.Mix("key", []byte("it's a key"))
+	})
+
+	t.Run("short write", func(t *testing.T) {
+		p := newplex.NewProtocol("example")
+		w := p.SealStream("message", shortWriter{})
+
+		if _, err := w.Write([]byte("hello")); !errors.Is(err, io.ErrShortWrite) {
+			t.Errorf("Write() err = %v, want = %v", err, io.ErrShortWrite)
+		}
+
+		if err := w.Close(); !errors.Is(err, io.ErrShortWrite) {
+			t.Errorf("Close() err = %v, want = %v", err, io.ErrShortWrite)
+		}
+	})
+}
+
+func TestProtocol_OpenStream(t *testing.T) {
+	seal := func(message []byte) []byte {
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		buf := bytes.NewBuffer(nil)
+		w := p.SealStream("message", buf)
+		if _, err := w.Write(message); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	open := func(ciphertext []byte) ([]byte, error) {
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		r := p.OpenStream("message", bytes.NewReader(ciphertext))
+		plaintext, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return plaintext, r.Close()
+	}
+
+	t.Run("modified ciphertext", func(t *testing.T) {
+		ciphertext := seal([]byte("it's a message"))
+		ciphertext[0] ^= 1
+
+		if _, err := open(ciphertext); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("expected ErrInvalidCiphertext, got %v", err)
+		}
+	})
+
+	t.Run("modified tag", func(t *testing.T) {
+		ciphertext := seal([]byte("it's a message"))
+		ciphertext[len(ciphertext)-1] ^= 1
+
+		if _, err := open(ciphertext); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("expected ErrInvalidCiphertext, got %v", err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		ciphertext := seal([]byte("it's a message"))
+
+		if _, err := open(ciphertext[:len(ciphertext)-1]); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("expected ErrInvalidCiphertext, got %v", err)
+		}
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		if _, err := open(make([]byte, newplex.TagSize-1)); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("expected ErrInvalidCiphertext, got %v", err)
+		}
+	})
+
+	t.Run("underlying reader error", func(t *testing.T) {
+		p := newplex.NewProtocol("example")
+		er := &testdata.ErrReader{Err: errors.New("read failed")}
+		r := p.OpenStream("message", er)
+
+		if _, err := r.Read(make([]byte, 10)); !errors.Is(err, er.Err) {
+			t.Errorf("expected %v, got %v", er.Err, err)
+		}
+	})
+
+	t.Run("no progress", func(t *testing.T) {
+		p := newplex.NewProtocol("example")
+		r := p.OpenStream("message", emptyReader{})
+
+		if _, err := r.Read(make([]byte, 10)); !errors.Is(err, io.ErrNoProgress) {
+			t.Errorf("expected %v, got %v", io.ErrNoProgress, err)
+		}
+	})
+
+	t.Run("one-byte reads", func(t *testing.T) {
+		message := []byte("it's a message")
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		r := p.OpenStream("message", iotest.OneByteReader(bytes.NewReader(seal(message))))
+
+		got, err := io.ReadAll(iotest.OneByteReader(r))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, message) {
+			t.Errorf("ReadAll() = %q, want = %q", got, message)
+		}
+	})
+
+	t.Run("early close", func(t *testing.T) {
+		p := newplex.NewProtocol("example")
+		r := p.OpenStream("message", bytes.NewReader(seal([]byte("it's a message"))))
+
+		if err := r.Close(); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("expected ErrInvalidCiphertext, got %v", err)
+		}
+	})
+
+	t.Run("use after failure", func(t *testing.T) {
+		ciphertext := seal([]byte("it's a message"))
+		ciphertext[0] ^= 1
+
+		p := newplex.NewProtocol("example")
+		p.Mix("key", []byte("it's a key"))
+		r := p.OpenStream("message", bytes.NewReader(ciphertext))
+		if _, err := io.ReadAll(r); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("expected ErrInvalidCiphertext, got %v", err)
+		}
+		if err := r.Close(); !errors.Is(err, newplex.ErrInvalidCiphertext) {
+			t.Errorf("expected ErrInvalidCiphertext, got %v", err)
+		}
+
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Derive() after a failed OpenStream should have panicked")
+			}
+		}()
+		p.Derive("output", nil, 16)
+	})
+}
+
+// shortWriter is an io.Writer which always writes one byte less than it is given and returns no error.
+type shortWriter struct{}
+
+func (shortWriter) Write(p []byte) (int, error) {
+	return max(len(p)-1, 0), nil
+}
+
+// emptyReader is an io.Reader which always returns no data and no error.
+type emptyReader struct{}
+
+func (emptyReader) Read([]byte) (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,131 @@
+package newplex
+
+import (
+	"fmt"
+)
+
+// A Tracer receives a record of each operation performed on a Protocol. It can be used to locate the point at which
+// two parties' protocols diverge without recording any inputs, outputs, or keys directly. See Protocol.SetTracer.
+type Tracer interface {
+	// Trace is called after each operation on the traced Protocol.
+	Trace(e TraceEvent)
+}
+
+// A TraceEvent describes a single operation performed on a Protocol. It contains no inputs or outputs of the operation,
+// only its metadata and a fingerprint of the protocol's state after the operation.
+type TraceEvent struct {
+	// Op is the name of the operation (e.g., "Mix" or "Seal").
+	Op string
+
+	// Opcode is the operation's opcode. Complementary operations (e.g., Seal and Open) share an opcode.
+	Opcode byte
+
+	// Label is the operation's label.
+	Label string
+
+	// Length is the length of the operation's data in bytes: the input for Mix, the output for Derive, and the plaintext
+	// for Mask, Unmask, Seal, and Open. For Fork, it is the number of branches. For Ratchet, it is zero.
+	Length int
+
+	// Fingerprint is a 64-bit fingerprint of the protocol's state after the operation. Two protocols with equal states
+	// have equal fingerprints; two protocols with different states have different fingerprints with overwhelming
+	// probability.
+	//
+	// A fingerprint is a deterministic function of every input to the protocol so far. If a protocol has absorbed only
+	// public or low-entropy inputs (e.g., an unkeyed protocol which mixes in a password or a PIN), anyone with its trace
+	// can test guesses for those inputs offline by recomputing the fingerprints, so traces of such protocols must be
+	// treated as being as sensitive as the secrets themselves.
+	Fingerprint [8]byte
+}
+
+// String returns a string representation of the event.
+func (e TraceEvent) String() string {
+	return fmt.Sprintf("%s(%q, %d) = %x", e.Op, e.Label, e.Length, e.Fingerprint)
+}
+
+// A TraceLog is a Tracer which records every event.
+type TraceLog []TraceEvent
+
+// Trace appends the event to the log.
+func (l *TraceLog) Trace(e TraceEvent) {
+	*l = append(*l, e)
+}
+
+// DiffTraces compares two traces of the same protocol (e.g., one recorded by each party) and returns the index of the
+// first event at which they diverge, along with a description of the divergence. If the traces are identical, it
+// returns -1 and an empty string.
+//
+// Events are compared by opcode, label, length, and fingerprint, so complementary operations like Seal and Open compare
+// as equal. If two events have the same metadata but different fingerprints, the data passed to that operation (e.g.,
+// the input to Mix or the ciphertext passed to Open) differed.
+func DiffTraces(a, b []TraceEvent) (int, string) {
+	for i := range max(len(a), len(b)) {
+		if i >= len(a) {
+			return i, fmt.Sprintf("event %d: %s missing from a", i, b[i])
+		}
+		if i >= len(b) {
+			return i, fmt.Sprintf("event %d: %s missing from b", i, a[i])
+		}
+
+		x, y := a[i], b[i]
+		switch {
+		case x.Opcode != y.Opcode:
+			return i, fmt.Sprintf("event %d: operations differ: %s vs %s", i, x, y)
+		case x.Label != y.Label:
+			return i, fmt.Sprintf("event %d: labels differ: %s vs %s", i, x, y)
+		case x.Length != y.Length:
+			return i, fmt.Sprintf("event %d: lengths differ: %s vs %s", i, x, y)
+		case x.Fingerprint != y.Fingerprint && i == 0:
+			return i, fmt.Sprintf("event %d: states differ: %s vs %s", i, x, y)
+		case x.Fingerprint != y.Fingerprint:
+			return i, fmt.Sprintf("event %d: data differs: %s vs %s", i, x, y)
+		}
+	}
+	return -1, ""
+}
+
+// SetTracer sets the protocol's tracer, which will receive an event after each subsequent operation. If t is nil,
+// tracing is disabled.
+//
+// SetTracer immediately sends a "Start" event containing a fingerprint of the protocol's current state, which allows
+// divergent domain separation strings or prior operations to be detected.
+//
+// Traces are not safe to log or share in general: their fingerprints allow offline guessing of any low-entropy secrets
+// the protocol absorbs. See TraceEvent.Fingerprint.
+//
+// Tracing requires an additional permutation per operation. Clones and branches of the protocol are not traced unless
+// SetTracer is called on them.
+//
+// SetTracer panics if a streaming operation is currently active.
+func (p *Protocol) SetTracer(t Tracer) {
+	p.checkState()
+	p.tracer = t
+	p.trace("Start", 0, "", 0)
+}
+
+// trace sends an event to the protocol's tracer, if any.
+func (p *Protocol) trace(op string, opcode byte, label string, n int) {
+	if p.tracer == nil {
+		return
+	}
+
+	p.tracer.Trace(TraceEvent{
+		Op:          op,
+		Opcode:      opcode,
+		Label:       label,
+		Length:      n,
+		Fingerprint: p.fingerprint(),
+	})
+}
+
+// fingerprint returns a 64-bit fingerprint of the protocol's state. It is equivalent to deriving 8 bytes from a clone of
+// the protocol with the label "debug".
+func (p *Protocol) fingerprint() [8]byte {
+	var fp [8]byte
+	d := p.duplex
+	d.AbsorbHeader(opDerive, "debug")
+	d.AbsorbLEB128(uint64(len(fp)))
+	d.Permute()
+	d.Squeeze(fp[:])
+	return fp
+}
@@ -0,0 +1,245 @@
+package newplex_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/codahale/newplex"
+)
+
+func TestProtocol_SetTracer(t *testing.T) {
+	t.Run("events", func(t *testing.T) {
+		var log newplex.TraceLog
+		p := newplex.NewProtocol("example")
+		p.SetTracer(&log)
+		p.Mix("key", []byte("a secret key"))
+		p.Derive("prf", nil, 32)
+		p.Mask("mask", nil, []byte("hello"))
+		p.Seal("seal", nil, []byte("hello world"))
+		p.Fork("fork", []byte("a"), []byte("b"))
+		p.Ratchet("ratchet")
+
+		var got []string
+		for _, e := range log {
+			got = append(got, fmt.Sprintf("%s/%s/%d", e.Op, e.Label, e.Opcode))
+		}
+		want := []string{"Start//0", "Mix/key/2", "Derive/prf/3", "Mask/mask/4", "Seal/seal/5", "Fork/fork/6",
+			"Ratchet/ratchet/7"}
+		if !slices.Equal(got, want) {
+			t.Errorf("events = %v, want = %v", got, want)
+		}
+
+		if got, want := fmt.Sprintf("Protocol(%x)", log[len(log)-1].Fingerprint), p.String(); got != want {
+			t.Errorf("Fingerprint = %s, want = %s", got, want)
+		}
+	})
+
+	t.Run("lengths", func(t *testing.T) {
+		var log newplex.TraceLog
+		p := newplex.NewProtocol("example")
+		p.SetTracer(&log)
+		p.Mix("key", make([]byte, 7))
+		p.Derive("prf", nil, 9)
+		p.Seal("seal", nil, make([]byte, 11))
+		p.ForkN("fork", nil, nil, nil)
+
+		var got []int
+		for _, e := range log {
+			got = append(got, e.Length)
+		}
+		if want := []int{0, 7, 9, 11, 3}; !slices.Equal(got, want) {
+			t.Errorf("lengths = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var log newplex.TraceLog
+		p := newplex.NewProtocol("example")
+		p.SetTracer(&log)
+		p.SetTracer(nil)
+		p.Mix("key", nil)
+
+		if got, want := len(log), 1; got != want {
+			t.Errorf("len(log) = %d, want = %d", got, want)
+		}
+	})
+
+	t.Run("clones are not traced", func(t *testing.T) {
+		var log newplex.TraceLog
+		p := newplex.NewProtocol("example")
+		p.SetTracer(&log)
+		c := p.Clone()
+		c.Mix("key", nil)
+		_ = p.String()
+
+		if got, want := len(log), 1; got != want {
+			t.Errorf("len(log) = %d, want = %d", got, want)
+		}
+	})
+
+	t.Run("streams", func(t *testing.T) {
+		var log, streamLog newplex.TraceLog
+		p1 := newplex.NewProtocol("example")
+		p1.SetTracer(&log)
+		p1.Mix("mix", []byte("hello world"))
+		p1.Mask("mask", nil, []byte("hello world"))
+
+		p2 := newplex.NewProtocol("example")
+		p2.SetTracer(&streamLog)
+		w := p2.MixWriter("mix", io.Discard)
+		_, _ = w.Write([]byte("hello "))
+		_, _ = w.Write([]byte("world"))
+		_ = w.Close()
+		s := p2.MaskStream("mask")
+		buf := []byte("hello world")
+		s.XORKeyStream(buf, buf)
+		_ = s.Close()
+
+		if i, diff := newplex.DiffTraces(log, streamLog); i != -1 {
+			t.Errorf("DiffTraces() = %d, %q, want = -1", i, diff)
+		}
+	})
+
+	t.Run("seal and open", func(t *testing.T) {
+		var sealLog, openLog newplex.TraceLog
+		sender := newplex.NewProtocol("example")
+		sender.SetTracer(&sealLog)
+		sender.Mix("key", []byte("a secret key"))
+		ciphertext := sender.Seal("message", nil, []byte("hello world"))
+
+		var buf bytes.Buffer
+		w := sender.SealStream("stream", &buf)
+		_, _ = w.Write([]byte("streamed"))
+		_ = w.Close()
+
+		receiver := newplex.NewProtocol("example")
+		receiver.SetTracer(&openLog)
+		receiver.Mix("key", []byte("a secret key"))
+		if _, err := receiver.Open("message", nil, ciphertext); err != nil {
+			t.Fatal(err)
+		}
+
+		r := receiver.OpenStream("stream", &buf)
+		if _, err := io.ReadAll(r); err != nil {
+			t.Fatal(err)
+		}
+		_ = r.Close()
+
+		if i, diff := newplex.DiffTraces(sealLog, openLog); i != -1 {
+			t.Errorf("DiffTraces() = %d, %q, want = -1", i, diff)
+		}
+	})
+}
+
+func TestDiffTraces(t *testing.T) {
+	trace := func(domain string, f func(p *newplex.Protocol)) newplex.TraceLog {
+		var log newplex.TraceLog
+		p := newplex.NewProtocol(domain)
+		p.SetTracer(&log)
+		f(p)
+		return log
+	}
+
+	base := func(p *newplex.Protocol) {
+		p.Mix("key", []byte("a secret key"))
+		p.Mix("nonce", []byte("a nonce"))
+		p.Derive("output", nil, 16)
+	}
+
+	tests := []struct {
+		name  string
+		b     newplex.TraceLog
+		index int
+		diff  string
+	}{
+		{
+			name:  "identical",
+			b:     trace("example", base),
+			index: -1,
+		},
+		{
+			name:  "domain",
+			b:     trace("other", base),
+			index: 0,
+			diff:  "states differ",
+		},
+		{
+			name: "data",
+			b: trace("example", func(p *newplex.Protocol) {
+				p.Mix("key", []byte("a secret key"))
+				p.Mix("nonce", []byte("A nonce"))
+				p.Derive("output", nil, 16)
+			}),
+			index: 2,
+			diff:  "data differs",
+		},
+		{
+			name: "label",
+			b: trace("example", func(p *newplex.Protocol) {
+				p.Mix("key", []byte("a secret key"))
+				p.Mix("iv", []byte("a nonce"))
+				p.Derive("output", nil, 16)
+			}),
+			index: 2,
+			diff:  "labels differ",
+		},
+		{
+			name: "length",
+			b: trace("example", func(p *newplex.Protocol) {
+				p.Mix("key", []byte("a secret key"))
+				p.Mix("nonce", []byte("a nonce"))
+				p.Derive("output", nil, 32)
+			}),
+			index: 3,
+			diff:  "lengths differ",
+		},
+		{
+			name: "operation",
+			b: trace("example", func(p *newplex.Protocol) {
+				p.Mix("key", []byte("a secret key"))
+				p.Mix("nonce", []byte("a nonce"))
+				p.Ratchet("output")
+			}),
+			index: 3,
+			diff:  "operations differ",
+		},
+		{
+			name: "missing",
+			b: trace("example", func(p *newplex.Protocol) {
+				p.Mix("key", []byte("a secret key"))
+				p.Mix("nonce", []byte("a nonce"))
+			}),
+			index: 3,
+			diff:  "missing from b",
+		},
+	}
+
+	a := trace("example", base)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, diff := newplex.DiffTraces(a, tt.b)
+			if got, want := index, tt.index; got != want {
+				t.Errorf("DiffTraces() = %d, want = %d", got, want)
+			}
+
+			if !strings.Contains(diff, tt.diff) {
+				t.Errorf("DiffTraces() = %q, want = %q", diff, tt.diff)
+			}
+		})
+	}
+
+	t.Run("missing from a", func(t *testing.T) {
+		index, diff := newplex.DiffTraces(a[:1], a)
+		if got, want := index, 1; got != want {
+			t.Errorf("DiffTraces() = %d, want = %d", got, want)
+		}
+
+		if got, want := diff, `event 1: Mix("key", 12) = `; !strings.HasPrefix(got, want) {
+			t.Errorf("DiffTraces() = %q, want = %q...", got, want)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package transcript_test
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/transcript"
+	"github.com/gtank/ristretto255"
+)
+
+// A non-interactive Schnorr proof of knowledge of the discrete logarithm of a public key.
+func Example() {
+	seed := make([]byte, 64)
+	_, _ = rand.Read(seed)
+	x, _ := ristretto255.NewScalar().SetUniformBytes(seed)
+	y := ristretto255.NewIdentityElement().ScalarBaseMult(x)
+
+	// The prover commits to a nonce, derives a challenge, and calculates a response.
+	prove := func(x *ristretto255.Scalar, y *ristretto255.Element, hedge []byte) (*ristretto255.Element, *ristretto255.Scalar) {
+		p := newplex.NewProtocol("com.example.dlog")
+		transcript.AppendElement(p, "public-key", y)
+		k := transcript.WitnessScalar(p, "nonce", x.Bytes(), hedge)
+		r := ristretto255.NewIdentityElement().ScalarBaseMult(k)
+		transcript.AppendElement(p, "commitment", r)
+		c := transcript.ChallengeScalar(p, "challenge")
+		s := ristretto255.NewScalar().Multiply(c, x)
+		return r, s.Add(s, k)
+	}
+
+	// The verifier re-derives the challenge from the same transcript and checks that [s]G = R + [c]Y.
+	verify := func(y *ristretto255.Element, r *ristretto255.Element, s *ristretto255.Scalar) bool {
+		p := newplex.NewProtocol("com.example.dlog")
+		transcript.AppendElement(p, "public-key", y)
+		transcript.AppendElement(p, "commitment", r)
+		c := transcript.ChallengeScalar(p, "challenge")
+		lhs := ristretto255.NewIdentityElement().ScalarBaseMult(s)
+		rhs := ristretto255.NewIdentityElement().ScalarMult(c, y)
+		return lhs.Equal(rhs.Add(rhs, r)) == 1
+	}
+
+	hedge := make([]byte, 64)
+	_, _ = rand.Read(hedge)
+	r, s := prove(x, y, hedge)
+	fmt.Println(verify(y, r, s))
+
+	// Output:
+	// true
+}
@@ -0,0 +1,109 @@
+package transcript_test
+
+import (
+	"testing"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/internal/testdata"
+	"github.com/codahale/newplex/transcript"
+	"github.com/gtank/ristretto255"
+)
+
+func TestAppendElement(t *testing.T) {
+	_, q := testdata.New("newplex transcript").KeyPair()
+
+	p1 := newplex.NewProtocol("example")
+	transcript.AppendElement(p1, "element", q)
+
+	p2 := newplex.NewProtocol("example")
+	p2.Mix("element", q.Bytes())
+
+	if got, want := p1.Equal(p2), 1; got != want {
+		t.Errorf("Equal() = %v, want = %v", got, want)
+	}
+}
+
+func TestAppendScalar(t *testing.T) {
+	d, _ := testdata.New("newplex transcript").KeyPair()
+
+	p1 := newplex.NewProtocol("example")
+	transcript.AppendScalar(p1, "scalar", d)
+
+	p2 := newplex.NewProtocol("example")
+	p2.Mix("scalar", d.Bytes())
+
+	if got, want := p1.Equal(p2), 1; got != want {
+		t.Errorf("Equal() = %v, want = %v", got, want)
+	}
+}
+
+func TestChallengeScalar(t *testing.T) {
+	p1 := newplex.NewProtocol("example")
+	got := transcript.ChallengeScalar(p1, "challenge")
+
+	p2 := newplex.NewProtocol("example")
+	want, _ := ristretto255.NewScalar().SetUniformBytes(p2.Derive("challenge", nil, 64))
+
+	if got.Equal(want) != 1 {
+		t.Errorf("ChallengeScalar() = %x, want = %x", got.Bytes(), want.Bytes())
+	}
+
+	if got, want := p1.Equal(p2), 1; got != want {
+		t.Errorf("Equal() = %v, want = %v", got, want)
+	}
+}
+
+func TestChallengeElement(t *testing.T) {
+	p1 := newplex.NewProtocol("example")
+	got := transcript.ChallengeElement(p1, "challenge")
+
+	p2 := newplex.NewProtocol("example")
+	want, _ := ristretto255.NewIdentityElement().SetUniformBytes(p2.Derive("challenge", nil, 64))
+
+	if got.Equal(want) != 1 {
+		t.Errorf("ChallengeElement() = %x, want = %x", got.Bytes(), want.Bytes())
+	}
+
+	if got, want := p1.Equal(p2), 1; got != want {
+		t.Errorf("Equal() = %v, want = %v", got, want)
+	}
+}
+
+func TestWitnessScalar(t *testing.T) {
+	drbg := testdata.New("newplex transcript")
+	d, _ := drbg.KeyPair()
+	secret := d.Bytes()
+	rand := drbg.Data(64)
+
+	t.Run("transcript unmodified", func(t *testing.T) {
+		p := newplex.NewProtocol("example")
+		transcript.WitnessScalar(p, "nonce", secret, rand)
+
+		if got, want := p.Equal(newplex.NewProtocol("example")), 1; got != want {
+			t.Errorf("Equal() = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("deterministic", func(t *testing.T) {
+		k1 := transcript.WitnessScalar(newplex.NewProtocol("example"), "nonce", secret, nil)
+		k2 := transcript.WitnessScalar(newplex.NewProtocol("example"), "nonce", secret, nil)
+		if k1.Equal(k2) != 1 {
+			t.Errorf("WitnessScalar() = %x, want = %x", k1.Bytes(), k2.Bytes())
+		}
+	})
+
+	t.Run("distinct", func(t *testing.T) {
+		base := transcript.WitnessScalar(newplex.NewProtocol("example"), "nonce", secret, rand)
+		for name, k := range map[string]*ristretto255.Scalar{
+			"transcript": transcript.WitnessScalar(newplex.NewProtocol("other"), "nonce", secret, rand),
+			"label":      transcript.WitnessScalar(newplex.NewProtocol("example"), "other", secret, rand),
+			"secret":     transcript.WitnessScalar(newplex.NewProtocol("example"), "nonce", rand, rand),
+			"rand":       transcript.WitnessScalar(newplex.NewProtocol("example"), "nonce", secret, nil),
+			"challenge":  transcript.ChallengeScalar(newplex.NewProtocol("example"), "nonce"),
+		} {
+			if base.Equal(k) == 1 {
+				t.Errorf("WitnessScalar() = ChallengeScalar() with different %s", name)
+			}
+		}
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"slices"
 
 	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/transcript"
 	"github.com/gtank/ristretto255"
 )
 
@@ -16,32 +17,32 @@ const ProofSize = 32 + 32 + 32
 func Prove(domain string, d *ristretto255.Scalar, rand, m []byte, n int) (prf, proof []byte) {
 	// Hash the input to a point on the curve.
 	p := newplex.NewProtocol(domain)
-	p.Mix("generator", ristretto255.NewGeneratorElement().Bytes())
-	p.Mix("prover", ristretto255.NewIdentityElement().ScalarBaseMult(d).Bytes())
+	transcript.AppendElement(p, "generator", ristretto255.NewGeneratorElement())
+	transcript.AppendElement(p, "prover", ristretto255.NewIdentityElement().ScalarBaseMult(d))
 	p.Mix("input", m)
-	h, _ := ristretto255.NewIdentityElement().SetUniformBytes(p.Derive("point", nil, 64))
+	h := transcript.ChallengeElement(p, "point")
 
 	// Calculate gamma and the PRF output.
 	gamma := ristretto255.NewIdentityElement().ScalarMult(d, h)
-	p.Mix("gamma", gamma.Bytes())
+	transcript.AppendElement(p, "gamma", gamma)
 	prf = p.Derive("prf", nil, n)
 
 	// Fork the protocol into prover and verifier roles.
 	prover, verifier := p.Fork("role", []byte("prover"), []byte("verifier"))
 
 	// Calculate a hedged nonce k.
-	prover.Mix("prover-private", d.Bytes())
+	transcript.AppendScalar(prover, "prover-private", d)
 	prover.Mix("rand", rand)
-	k, _ := ristretto255.NewScalar().SetUniformBytes(prover.Derive("commitment", nil, 64))
+	k := transcript.ChallengeScalar(prover, "commitment")
 
 	// Calculate the commitment points.
 	u := ristretto255.NewIdentityElement().ScalarBaseMult(k)
 	v := ristretto255.NewIdentityElement().ScalarMult(k, h)
-	verifier.Mix("commitment-u", u.Bytes())
-	verifier.Mix("commitment-v", v.Bytes())
+	transcript.AppendElement(verifier, "commitment-u", u)
+	transcript.AppendElement(verifier, "commitment-v", v)
 
 	// Calculate a challenge and a response.
-	c, _ := ristretto255.NewScalar().SetUniformBytes(verifier.Derive("challenge", nil, 64))
+	c := transcript.ChallengeScalar(verifier, "challenge")
 	s := ristretto255.NewScalar().Multiply(c, d)
 	s = s.Add(s, k)
 
@@ -67,10 +68,10 @@ func Verify(domain string, q *ristretto255.Element, m, proof []byte, n int) (val
 
 	// Hash the input to a point on the curve.
 	p := newplex.NewProtocol(domain)
-	p.Mix("generator", ristretto255.NewGeneratorElement().Bytes())
-	p.Mix("prover", q.Bytes())
+	transcript.AppendElement(p, "generator", ristretto255.NewGeneratorElement())
+	transcript.AppendElement(p, "prover", q)
 	p.Mix("input", m)
-	h, _ := ristretto255.NewIdentityElement().SetUniformBytes(p.Derive("point", nil, 64))
+	h := transcript.ChallengeElement(p, "point")
 
 	// Mix in gamma and calculate the PRF output.
 	p.Mix("gamma", proofGamma)
@@ -84,11 +85,11 @@ func Verify(domain string, q *ristretto255.Element, m, proof []byte, n int) (val
 	// Fork the protocol into prover and verifier roles.
 	_, verifier := p.Fork("role", []byte("prover"), []byte("verifier"))
 
-	verifier.Mix("commitment-u", u.Bytes())
-	verifier.Mix("commitment-v", v.Bytes())
+	transcript.AppendElement(verifier, "commitment-u", u)
+	transcript.AppendElement(verifier, "commitment-v", v)
 
 	// Calculate a challenge and a response.
-	expectedC, _ := ristretto255.NewScalar().SetUniformBytes(verifier.Derive("challenge", nil, 64))
+	expectedC := transcript.ChallengeScalar(verifier, "challenge")
 	if expectedC.Equal(c) == 0 {
 		return false, nil
 	}
@@ -0,0 +1,163 @@
+package zkp
+
+import (
+	"encoding/binary"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/transcript"
+	"github.com/gtank/ristretto255"
+)
+
+// OrProofSize returns the length in bytes of a proof that at least one of the given statements is true.
+func OrProofSize(branches ...*Statement) int {
+	n := 32 * (len(branches) - 1)
+	for _, s := range branches {
+		n += s.ProofSize()
+	}
+	return n
+}
+
+// ProveOr generates a proof that the prover knows a witness for at least one of the given statements without revealing
+// which, using the given protocol as the Fiat–Shamir transcript. The witness must be a witness for branches[index]. The
+// optional random data hedges the deterministic nonce derivation against fault attacks.
+//
+// The proofs for the other branches are simulated using the technique of Cramer, Damgård, and Schoenmakers: the
+// prover chooses their challenges and responses in advance, and the challenge for the true branch is the difference
+// between the protocol's challenge and the sum of the simulated challenges.
+//
+// ProveOr is not constant-time with respect to index. Returns ErrInvalidWitness if the witness does not satisfy
+// branches[index].
+//
+// ProveOr panics if index is out of range.
+func ProveOr(p *newplex.Protocol, branches []*Statement, index int, witness []*ristretto255.Scalar, rand []byte) ([]byte, error) {
+	if index < 0 || index >= len(branches) {
+		panic("zkp: branch index out of range")
+	}
+
+	if !branches[index].satisfiedBy(witness) {
+		return nil, ErrInvalidWitness
+	}
+
+	bindOr(p, branches)
+
+	// Derive the nonces for the true branch and the challenges and responses for the simulated branches.
+	prover := p.Clone()
+	prover.Mix("branch", binary.AppendUvarint(nil, uint64(index)))
+	for _, x := range witness {
+		transcript.AppendScalar(prover, "witness", x)
+	}
+	prover.Mix("hedged-rand", rand)
+
+	var k []*ristretto255.Scalar
+	cs := make([]*ristretto255.Scalar, len(branches))
+	zs := make([][]*ristretto255.Scalar, len(branches))
+	commitments := make([][]*ristretto255.Element, len(branches))
+	for b, s := range branches {
+		if b == index {
+			k = make([]*ristretto255.Scalar, len(s.scalars))
+			for i := range k {
+				k[i] = transcript.ChallengeScalar(prover, "nonce")
+			}
+			commitments[b] = s.commitments(k)
+			continue
+		}
+
+		cs[b] = transcript.ChallengeScalar(prover, "simulated-challenge")
+		zs[b] = make([]*ristretto255.Scalar, len(s.scalars))
+		for i := range zs[b] {
+			zs[b][i] = transcript.ChallengeScalar(prover, "simulated-response")
+		}
+		commitments[b] = make([]*ristretto255.Element, len(s.equations))
+		for j, eq := range s.equations {
+			commitments[b][j] = s.evaluate(eq, zs[b], cs[b])
+		}
+	}
+
+	for _, rs := range commitments {
+		for _, r := range rs {
+			transcript.AppendElement(p, "commitment", r)
+		}
+	}
+
+	// The true branch's challenge is whatever remains of the protocol's challenge.
+	cs[index] = transcript.ChallengeScalar(p, "challenge")
+	for b, c := range cs {
+		if b != index {
+			cs[index].Subtract(cs[index], c)
+		}
+	}
+	zs[index] = responses(k, witness, cs[index])
+
+	proof := make([]byte, 0, OrProofSize(branches...))
+	for b := range branches {
+		for _, r := range commitments[b] {
+			proof = append(proof, r.Bytes()...)
+		}
+		for _, z := range zs[b] {
+			proof = append(proof, z.Bytes()...)
+		}
+	}
+	for _, c := range cs[:len(cs)-1] {
+		proof = append(proof, c.Bytes()...)
+	}
+	return proof, nil
+}
+
+// VerifyOr returns true if the given proof is a valid proof that at least one of the given statements is true, using
+// the given protocol as the Fiat–Shamir transcript.
+func VerifyOr(p *newplex.Protocol, branches []*Statement, proof []byte) bool {
+	if len(branches) == 0 || len(proof) != OrProofSize(branches...) {
+		return false
+	}
+
+	rs := make([][]*ristretto255.Element, len(branches))
+	zs := make([][]*ristretto255.Scalar, len(branches))
+	for b, s := range branches {
+		var ok bool
+		rs[b], zs[b], ok = s.parse(proof[:s.ProofSize()])
+		if !ok {
+			return false
+		}
+		proof = proof[s.ProofSize():]
+	}
+
+	cs := make([]*ristretto255.Scalar, len(branches))
+	for b := range cs[:len(cs)-1] {
+		cs[b], _ = ristretto255.NewScalar().SetCanonicalBytes(proof[:32])
+		if cs[b] == nil {
+			return false
+		}
+		proof = proof[32:]
+	}
+
+	bindOr(p, branches)
+	for _, r := range rs {
+		for _, ri := range r {
+			transcript.AppendElement(p, "commitment", ri)
+		}
+	}
+
+	// The final branch's challenge is whatever remains of the protocol's challenge.
+	last := len(cs) - 1
+	cs[last] = transcript.ChallengeScalar(p, "challenge")
+	for _, c := range cs[:last] {
+		cs[last].Subtract(cs[last], c)
+	}
+
+	for b, s := range branches {
+		for j, eq := range s.equations {
+			if s.evaluate(eq, zs[b], cs[b]).Equal(rs[b][j]) != 1 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// bindOr mixes the number of branches and each branch's statement into the protocol.
+func bindOr(p *newplex.Protocol, branches []*Statement) {
+	p.Mix("or", binary.AppendUvarint(nil, uint64(len(branches))))
+	for _, s := range branches {
+		s.bind(p)
+	}
+}
@@ -0,0 +1,383 @@
+// Package zkp implements non-interactive zero-knowledge proofs of linear relations over Ristretto255 using Newplex.
+//
+// A Statement declares a set of equations, each of which asserts that a public element is a linear combination of
+// public elements with secret scalar coefficients (e.g., Y = [x]G or C = [m]G + [r]H). Scalars may appear in several
+// equations, which allows for proofs of discrete log equality and other conjunctions. Statements can be combined with
+// And, and proofs of disjunctions can be created with ProveOr.
+//
+// Proofs are sigma protocols made non-interactive via the Fiat–Shamir transform, using a Protocol as the transcript.
+// Every public element, the structure of the statement, and every commitment are mixed into the protocol before the
+// challenge is derived, so proofs are bound to the protocol's entire transcript. Proofs consist of one commitment per
+// equation and one response per secret scalar, which allows them to be verified in batches with BatchVerify.
+package zkp
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/transcript"
+	"github.com/gtank/ristretto255"
+)
+
+// ErrInvalidWitness is returned when a witness does not satisfy the statement being proven.
+var ErrInvalidWitness = errors.New("zkp: invalid witness")
+
+// A ScalarVar is a secret scalar variable in a Statement.
+type ScalarVar int
+
+// An ElementVar is a public element variable in a Statement.
+type ElementVar int
+
+// A Term is the product of a secret scalar and a public element in an equation.
+type Term struct {
+	Scalar  ScalarVar
+	Element ElementVar
+}
+
+// A Statement is a set of linear relations over Ristretto255 between public elements and secret scalars.
+//
+// The zero value is an empty statement, which is trivially true.
+type Statement struct {
+	scalars      []string
+	elementNames []string
+	elements     []*ristretto255.Element
+	equations    []equation
+}
+
+type equation struct {
+	lhs   ElementVar
+	terms []Term
+}
+
+// Scalar declares a new secret scalar variable with the given name.
+func (s *Statement) Scalar(name string) ScalarVar {
+	s.scalars = append(s.scalars, name)
+	return ScalarVar(len(s.scalars) - 1)
+}
+
+// Element declares a new public element variable with the given name and value.
+func (s *Statement) Element(name string, e *ristretto255.Element) ElementVar {
+	s.elementNames = append(s.elementNames, name)
+	s.elements = append(s.elements, e)
+	return ElementVar(len(s.elements) - 1)
+}
+
+// Constrain adds an equation to the statement which asserts that the left-hand element is equal to the sum of the given
+// terms.
+//
+// Constrain panics if no terms are given or if any variable was not declared by this statement.
+func (s *Statement) Constrain(lhs ElementVar, terms ...Term) {
+	if len(terms) == 0 {
+		panic("zkp: equation has no terms")
+	}
+	s.checkElement(lhs)
+	for _, t := range terms {
+		s.checkElement(t.Element)
+		if t.Scalar < 0 || int(t.Scalar) >= len(s.scalars) {
+			panic("zkp: undeclared scalar variable")
+		}
+	}
+	s.equations = append(s.equations, equation{lhs: lhs, terms: terms})
+}
+
+// checkElement panics if the element variable was not declared by this statement.
+func (s *Statement) checkElement(e ElementVar) {
+	if e < 0 || int(e) >= len(s.elements) {
+		panic("zkp: undeclared element variable")
+	}
+}
+
+// Scalars returns the number of secret scalars in the statement. A witness for the statement must have this many
+// scalars, in the order in which they were declared.
+func (s *Statement) Scalars() int {
+	return len(s.scalars)
+}
+
+// ProofSize returns the length of a proof of the statement in bytes.
+func (s *Statement) ProofSize() int {
+	return 32 * (len(s.equations) + len(s.scalars))
+}
+
+// DLog returns a statement asserting knowledge of the discrete logarithm x of y with respect to g (i.e., y = [x]g).
+func DLog(g, y *ristretto255.Element) *Statement {
+	var s Statement
+	x := s.Scalar("x")
+	gv, yv := s.Element("g", g), s.Element("y", y)
+	s.Constrain(yv, Term{x, gv})
+	return &s
+}
+
+// DLEQ returns a statement asserting knowledge of a scalar x which is the discrete logarithm of both y with respect to g
+// and z with respect to h (i.e., y = [x]g and z = [x]h).
+func DLEQ(g, y, h, z *ristretto255.Element) *Statement {
+	var s Statement
+	x := s.Scalar("x")
+	gv, yv := s.Element("g", g), s.Element("y", y)
+	hv, zv := s.Element("h", h), s.Element("z", z)
+	s.Constrain(yv, Term{x, gv})
+	s.Constrain(zv, Term{x, hv})
+	return &s
+}
+
+// Representation returns a statement asserting knowledge of scalars x_0..x_n-1 such that y = [x_0]generators[0] + ... +
+// [x_n-1]generators[n-1] (e.g., the opening of a Pedersen commitment).
+//
+// Representation panics if no generators are given.
+func Representation(y *ristretto255.Element, generators ...*ristretto255.Element) *Statement {
+	var s Statement
+	yv := s.Element("y", y)
+	terms := make([]Term, len(generators))
+	for i, g := range generators {
+		terms[i] = Term{s.Scalar("x"), s.Element("g", g)}
+	}
+	s.Constrain(yv, terms...)
+	return &s
+}
+
+// And returns a statement asserting that all the given statements are true. The variables of each statement remain
+// distinct, and a witness for the combined statement is the concatenation of witnesses for each statement, in order.
+// To share a secret scalar between equations, declare them in a single Statement instead.
+func And(statements ...*Statement) *Statement {
+	var s Statement
+	for _, st := range statements {
+		scalarOffset, elementOffset := ScalarVar(len(s.scalars)), ElementVar(len(s.elements))
+		s.scalars = append(s.scalars, st.scalars...)
+		s.elementNames = append(s.elementNames, st.elementNames...)
+		s.elements = append(s.elements, st.elements...)
+		for _, eq := range st.equations {
+			terms := make([]Term, len(eq.terms))
+			for i, t := range eq.terms {
+				terms[i] = Term{t.Scalar + scalarOffset, t.Element + elementOffset}
+			}
+			s.equations = append(s.equations, equation{lhs: eq.lhs + elementOffset, terms: terms})
+		}
+	}
+	return &s
+}
+
+// Prove generates a proof that the prover knows a witness for the given statement, using the given protocol as the
+// Fiat–Shamir transcript. The witness must contain one scalar for each of the statement's secret scalars, in the order
+// in which they were declared. The optional random data hedges the deterministic nonce derivation against fault
+// attacks.
+//
+// Returns ErrInvalidWitness if the witness does not satisfy the statement.
+func Prove(p *newplex.Protocol, s *Statement, witness []*ristretto255.Scalar, rand []byte) ([]byte, error) {
+	if !s.satisfiedBy(witness) {
+		return nil, ErrInvalidWitness
+	}
+
+	s.bind(p)
+	k := nonces(p, witness, rand)
+	proof := make([]byte, 0, s.ProofSize())
+	for _, r := range s.commitments(k) {
+		transcript.AppendElement(p, "commitment", r)
+		proof = append(proof, r.Bytes()...)
+	}
+
+	c := transcript.ChallengeScalar(p, "challenge")
+	for _, z := range responses(k, witness, c) {
+		proof = append(proof, z.Bytes()...)
+	}
+	return proof, nil
+}
+
+// Verify returns true if the given proof is a valid proof of the given statement, using the given protocol as the
+// Fiat–Shamir transcript.
+func Verify(p *newplex.Protocol, s *Statement, proof []byte) bool {
+	r, z, ok := s.parse(proof)
+	if !ok {
+		return false
+	}
+
+	s.bind(p)
+	for _, ri := range r {
+		transcript.AppendElement(p, "commitment", ri)
+	}
+	c := transcript.ChallengeScalar(p, "challenge")
+
+	for i, eq := range s.equations {
+		if s.evaluate(eq, z, c).Equal(r[i]) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// BatchVerify returns true if, for each i, proofs[i] is a valid proof of statements[i] using protocols[i] as the
+// Fiat–Shamir transcript. It is equivalent to calling Verify on each proof, but checks a random linear combination of
+// every equation of every proof with a single multi-scalar multiplication, which is faster for large batches. If
+// BatchVerify returns false, use Verify to determine which proofs are invalid.
+//
+// If any proof is malformed, BatchVerify returns false without modifying any protocol. Otherwise, every protocol is
+// updated as it would be by Verify, regardless of whether the batch is valid.
+//
+// BatchVerify panics if the lengths of the slices are not equal.
+func BatchVerify(protocols []*newplex.Protocol, statements []*Statement, proofs [][]byte) bool {
+	if len(protocols) != len(statements) || len(protocols) != len(proofs) {
+		panic("zkp: mismatched slice lengths")
+	}
+
+	// Parse every proof before updating any protocol.
+	rs := make([][]*ristretto255.Element, len(proofs))
+	zs := make([][]*ristretto255.Scalar, len(proofs))
+	for i, s := range statements {
+		var ok bool
+		rs[i], zs[i], ok = s.parse(proofs[i])
+		if !ok {
+			return false
+		}
+	}
+
+	// The random weights are derived from every proof's challenge, commitments, and responses, so they cannot be
+	// predicted by a prover.
+	weights := newplex.NewProtocol("newplex.zkp.batch")
+	var scalars []*ristretto255.Scalar
+	var elements []*ristretto255.Element
+	for i, s := range statements {
+		r, z := rs[i], zs[i]
+		p := protocols[i]
+		s.bind(p)
+		for _, ri := range r {
+			transcript.AppendElement(p, "commitment", ri)
+		}
+		c := transcript.ChallengeScalar(p, "challenge")
+		transcript.AppendScalar(weights, "challenge", c)
+		weights.Mix("proof", proofs[i])
+
+		// For each equation, accumulate w * (sum([z]P) - [c]Y - R), which must sum to the identity element.
+		negC := ristretto255.NewScalar().Negate(c)
+		for j, eq := range s.equations {
+			w := transcript.ChallengeScalar(weights, "weight")
+			for _, t := range eq.terms {
+				scalars = append(scalars, ristretto255.NewScalar().Multiply(w, z[t.Scalar]))
+				elements = append(elements, s.elements[t.Element])
+			}
+			scalars = append(scalars, ristretto255.NewScalar().Multiply(w, negC), ristretto255.NewScalar().Negate(w))
+			elements = append(elements, s.elements[eq.lhs], r[j])
+		}
+	}
+
+	if len(scalars) == 0 {
+		return true
+	}
+	sum := ristretto255.NewIdentityElement().VarTimeMultiScalarMult(scalars, elements)
+	return sum.Equal(ristretto255.NewIdentityElement()) == 1
+}
+
+// bind mixes the statement's structure and public elements into the protocol.
+func (s *Statement) bind(p *newplex.Protocol) {
+	shape := binary.AppendUvarint(nil, uint64(len(s.scalars)))
+	shape = binary.AppendUvarint(shape, uint64(len(s.elements)))
+	shape = binary.AppendUvarint(shape, uint64(len(s.equations)))
+	for _, eq := range s.equations {
+		shape = binary.AppendUvarint(shape, uint64(eq.lhs))
+		shape = binary.AppendUvarint(shape, uint64(len(eq.terms)))
+		for _, t := range eq.terms {
+			shape = binary.AppendUvarint(shape, uint64(t.Scalar))
+			shape = binary.AppendUvarint(shape, uint64(t.Element))
+		}
+	}
+	p.Mix("statement", shape)
+
+	for i, e := range s.elements {
+		transcript.AppendElement(p, s.elementNames[i], e)
+	}
+}
+
+// satisfiedBy returns true if the given witness satisfies every equation of the statement.
+func (s *Statement) satisfiedBy(witness []*ristretto255.Scalar) bool {
+	if len(witness) != len(s.scalars) {
+		return false
+	}
+
+	for _, eq := range s.equations {
+		if s.combine(eq, witness).Equal(s.elements[eq.lhs]) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// commitments returns the commitment for each equation given the nonces k.
+func (s *Statement) commitments(k []*ristretto255.Scalar) []*ristretto255.Element {
+	r := make([]*ristretto255.Element, len(s.equations))
+	for i, eq := range s.equations {
+		r[i] = s.combine(eq, k)
+	}
+	return r
+}
+
+// combine returns the right-hand side of the equation for the given scalar values in constant time.
+func (s *Statement) combine(eq equation, values []*ristretto255.Scalar) *ristretto255.Element {
+	sum := ristretto255.NewIdentityElement()
+	for _, t := range eq.terms {
+		sum.Add(sum, ristretto255.NewIdentityElement().ScalarMult(values[t.Scalar], s.elements[t.Element]))
+	}
+	return sum
+}
+
+// evaluate returns the expected commitment for the equation given the responses z and challenge c: sum([z]P) - [c]Y.
+func (s *Statement) evaluate(eq equation, z []*ristretto255.Scalar, c *ristretto255.Scalar) *ristretto255.Element {
+	scalars := make([]*ristretto255.Scalar, 0, len(eq.terms)+1)
+	elements := make([]*ristretto255.Element, 0, len(eq.terms)+1)
+	for _, t := range eq.terms {
+		scalars = append(scalars, z[t.Scalar])
+		elements = append(elements, s.elements[t.Element])
+	}
+	scalars = append(scalars, ristretto255.NewScalar().Negate(c))
+	elements = append(elements, s.elements[eq.lhs])
+	return ristretto255.NewIdentityElement().VarTimeMultiScalarMult(scalars, elements)
+}
+
+// parse decodes a proof into its commitments and responses.
+func (s *Statement) parse(proof []byte) (r []*ristretto255.Element, z []*ristretto255.Scalar, ok bool) {
+	if len(proof) != s.ProofSize() {
+		return nil, nil, false
+	}
+
+	r = make([]*ristretto255.Element, len(s.equations))
+	for i := range r {
+		r[i], _ = ristretto255.NewIdentityElement().SetCanonicalBytes(proof[:32])
+		if r[i] == nil {
+			return nil, nil, false
+		}
+		proof = proof[32:]
+	}
+
+	z = make([]*ristretto255.Scalar, len(s.scalars))
+	for i := range z {
+		z[i], _ = ristretto255.NewScalar().SetCanonicalBytes(proof[:32])
+		if z[i] == nil {
+			return nil, nil, false
+		}
+		proof = proof[32:]
+	}
+	return r, z, true
+}
+
+// nonces derives a secret nonce for each scalar of the witness from the protocol's transcript, the witness, and the
+// random data, without modifying the protocol.
+func nonces(p *newplex.Protocol, witness []*ristretto255.Scalar, rand []byte) []*ristretto255.Scalar {
+	prover := p.Clone()
+	for _, x := range witness {
+		transcript.AppendScalar(prover, "witness", x)
+	}
+	prover.Mix("hedged-rand", rand)
+
+	k := make([]*ristretto255.Scalar, len(witness))
+	for i := range k {
+		k[i] = transcript.ChallengeScalar(prover, "nonce")
+	}
+	return k
+}
+
+// responses returns the response z_i = k_i + c*x_i for each secret scalar.
+func responses(k, witness []*ristretto255.Scalar, c *ristretto255.Scalar) []*ristretto255.Scalar {
+	z := make([]*ristretto255.Scalar, len(witness))
+	for i, x := range witness {
+		z[i] = ristretto255.NewScalar().Multiply(c, x)
+		z[i].Add(z[i], k[i])
+	}
+	return z
+}
@@ -0,0 +1,416 @@
+package zkp_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/codahale/newplex"
+	"github.com/codahale/newplex/internal/testdata"
+	"github.com/codahale/newplex/zkp"
+	"github.com/gtank/ristretto255"
+)
+
+type testCase struct {
+	name      string
+	statement *zkp.Statement
+	witness   []*ristretto255.Scalar
+	size      int
+}
+
+func testCases(drbg *testdata.DRBG) []testCase {
+	g := ristretto255.NewGeneratorElement()
+	h := element(drbg)
+	x, y := drbg.KeyPair()
+	m, r := scalar(drbg), scalar(drbg)
+	pedersen := ristretto255.NewIdentityElement().VarTimeMultiScalarMult([]*ristretto255.Scalar{m, r}, []*ristretto255.Element{g, h})
+
+	// A custom statement: knowledge of an opening (m, r) of a Pedersen commitment and of x, with x shared with a
+	// second equation.
+	var custom zkp.Statement
+	cm, cr, cx := custom.Scalar("m"), custom.Scalar("r"), custom.Scalar("x")
+	cg, ch := custom.Element("g", g), custom.Element("h", h)
+	cc, cy := custom.Element("c", pedersen), custom.Element("y", y)
+	cz := custom.Element("z", ristretto255.NewIdentityElement().ScalarMult(x, h))
+	custom.Constrain(cc, zkp.Term{Scalar: cm, Element: cg}, zkp.Term{Scalar: cr, Element: ch})
+	custom.Constrain(cy, zkp.Term{Scalar: cx, Element: cg})
+	custom.Constrain(cz, zkp.Term{Scalar: cx, Element: ch})
+
+	return []testCase{
+		{
+			name:      "dlog",
+			statement: zkp.DLog(g, y),
+			witness:   []*ristretto255.Scalar{x},
+			size:      64,
+		},
+		{
+			name:      "dleq",
+			statement: zkp.DLEQ(g, y, h, ristretto255.NewIdentityElement().ScalarMult(x, h)),
+			witness:   []*ristretto255.Scalar{x},
+			size:      96,
+		},
+		{
+			name:      "representation",
+			statement: zkp.Representation(pedersen, g, h),
+			witness:   []*ristretto255.Scalar{m, r},
+			size:      96,
+		},
+		{
+			name:      "and",
+			statement: zkp.And(zkp.DLog(g, y), zkp.Representation(pedersen, g, h)),
+			witness:   []*ristretto255.Scalar{x, m, r},
+			size:      160,
+		},
+		{
+			name:      "custom",
+			statement: &custom,
+			witness:   []*ristretto255.Scalar{m, r, x},
+			size:      192,
+		},
+	}
+}
+
+func TestProve(t *testing.T) {
+	drbg := testdata.New("newplex zkp")
+	for _, tc := range testCases(drbg) {
+		t.Run(tc.name, func(t *testing.T) {
+			proof, err := zkp.Prove(newplex.NewProtocol("zkp"), tc.statement, tc.witness, drbg.Data(32))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := len(proof), tc.size; got != want {
+				t.Errorf("len(proof) = %d, want = %d", got, want)
+			}
+
+			if got, want := len(proof), tc.statement.ProofSize(); got != want {
+				t.Errorf("len(proof) = %d, want = %d", got, want)
+			}
+
+			if !zkp.Verify(newplex.NewProtocol("zkp"), tc.statement, proof) {
+				t.Error("Verify() = false, want = true")
+			}
+
+			if zkp.Verify(newplex.NewProtocol("other"), tc.statement, proof) {
+				t.Error("Verify() with a different transcript = true, want = false")
+			}
+
+			for i := range proof {
+				bad := append([]byte(nil), proof...)
+				bad[i] ^= 1
+				if zkp.Verify(newplex.NewProtocol("zkp"), tc.statement, bad) {
+					t.Errorf("Verify() with bit flipped at %d = true, want = false", i)
+				}
+			}
+
+			if zkp.Verify(newplex.NewProtocol("zkp"), tc.statement, proof[:len(proof)-1]) {
+				t.Error("Verify() with a truncated proof = true, want = false")
+			}
+		})
+	}
+
+	t.Run("invalid witness", func(t *testing.T) {
+		_, y := drbg.KeyPair()
+		_, err := zkp.Prove(newplex.NewProtocol("zkp"), zkp.DLog(ristretto255.NewGeneratorElement(), y),
+			[]*ristretto255.Scalar{scalar(drbg)}, nil)
+		if got, want := err, zkp.ErrInvalidWitness; !errors.Is(got, want) {
+			t.Errorf("Prove() err = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("wrong witness length", func(t *testing.T) {
+		x, y := drbg.KeyPair()
+		_, err := zkp.Prove(newplex.NewProtocol("zkp"), zkp.DLog(ristretto255.NewGeneratorElement(), y),
+			[]*ristretto255.Scalar{x, x}, nil)
+		if got, want := err, zkp.ErrInvalidWitness; !errors.Is(got, want) {
+			t.Errorf("Prove() err = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("wrong statement", func(t *testing.T) {
+		x, y := drbg.KeyPair()
+		_, y2 := drbg.KeyPair()
+		g := ristretto255.NewGeneratorElement()
+		proof, err := zkp.Prove(newplex.NewProtocol("zkp"), zkp.DLog(g, y), []*ristretto255.Scalar{x}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if zkp.Verify(newplex.NewProtocol("zkp"), zkp.DLog(g, y2), proof) {
+			t.Error("Verify() with a different statement = true, want = false")
+		}
+	})
+
+	t.Run("transcript", func(t *testing.T) {
+		x, y := drbg.KeyPair()
+		g := ristretto255.NewGeneratorElement()
+		prover := newplex.NewProtocol("zkp")
+		proof, err := zkp.Prove(prover, zkp.DLog(g, y), []*ristretto255.Scalar{x}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		verifier := newplex.NewProtocol("zkp")
+		if !zkp.Verify(verifier, zkp.DLog(g, y), proof) {
+			t.Fatal("Verify() = false, want = true")
+		}
+
+		if got, want := verifier.Equal(prover), 1; got != want {
+			t.Errorf("Equal() = %d, want = %d", got, want)
+		}
+	})
+}
+
+func TestStatement_Constrain(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(s *zkp.Statement)
+	}{
+		{"no terms", func(s *zkp.Statement) {
+			s.Constrain(s.Element("y", ristretto255.NewGeneratorElement()))
+		}},
+		{"undeclared element", func(s *zkp.Statement) {
+			s.Constrain(zkp.ElementVar(3), zkp.Term{Scalar: s.Scalar("x"), Element: 0})
+		}},
+		{"undeclared scalar", func(s *zkp.Statement) {
+			y := s.Element("y", ristretto255.NewGeneratorElement())
+			s.Constrain(y, zkp.Term{Scalar: 1, Element: y})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("Constrain() did not panic")
+				}
+			}()
+			tt.f(new(zkp.Statement))
+		})
+	}
+}
+
+func TestBatchVerify(t *testing.T) {
+	drbg := testdata.New("newplex zkp batch")
+	cases := testCases(drbg)
+
+	protocols := func() []*newplex.Protocol {
+		ps := make([]*newplex.Protocol, len(cases))
+		for i := range ps {
+			ps[i] = newplex.NewProtocol("zkp")
+		}
+		return ps
+	}
+
+	statements := make([]*zkp.Statement, len(cases))
+	proofs := make([][]byte, len(cases))
+	for i, tc := range cases {
+		statements[i] = tc.statement
+		var err error
+		proofs[i], err = zkp.Prove(newplex.NewProtocol("zkp"), tc.statement, tc.witness, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		if !zkp.BatchVerify(protocols(), statements, proofs) {
+			t.Error("BatchVerify() = false, want = true")
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if !zkp.BatchVerify(nil, nil, nil) {
+			t.Error("BatchVerify() = false, want = true")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for i := range proofs {
+			bad := append([][]byte(nil), proofs...)
+			bad[i] = append([]byte(nil), proofs[i]...)
+			bad[i][len(bad[i])-1] ^= 1
+
+			if zkp.BatchVerify(protocols(), statements, bad) {
+				t.Errorf("BatchVerify() with proof %d modified = true, want = false", i)
+			}
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		bad := append([][]byte(nil), proofs...)
+		bad[len(bad)-1] = bad[len(bad)-1][1:]
+
+		ps := protocols()
+		if zkp.BatchVerify(ps, statements, bad) {
+			t.Error("BatchVerify() with a malformed proof = true, want = false")
+		}
+
+		for i, p := range ps {
+			if got, want := p.Equal(newplex.NewProtocol("zkp")), 1; got != want {
+				t.Errorf("protocols[%d].Equal(NewProtocol()) = %d, want = %d", i, got, want)
+			}
+		}
+	})
+
+	t.Run("swapped", func(t *testing.T) {
+		bad := append([][]byte(nil), proofs...)
+		bad[1], bad[2] = bad[2], bad[1]
+		if zkp.BatchVerify(protocols(), statements, bad) {
+			t.Error("BatchVerify() with swapped proofs = true, want = false")
+		}
+	})
+
+	t.Run("mismatched lengths", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("BatchVerify() did not panic")
+			}
+		}()
+		zkp.BatchVerify(protocols(), statements[1:], proofs)
+	})
+}
+
+func TestProveOr(t *testing.T) {
+	drbg := testdata.New("newplex zkp or")
+	g := ristretto255.NewGeneratorElement()
+	x0, y0 := drbg.KeyPair()
+	x1, y1 := drbg.KeyPair()
+	_, y2 := drbg.KeyPair()
+	h := element(drbg)
+	branches := []*zkp.Statement{
+		zkp.DLog(g, y0),
+		zkp.DLEQ(g, y1, h, ristretto255.NewIdentityElement().ScalarMult(x1, h)),
+		zkp.DLog(g, y2),
+	}
+	witnesses := [][]*ristretto255.Scalar{{x0}, {x1}}
+
+	for index, witness := range witnesses {
+		proof, err := zkp.ProveOr(newplex.NewProtocol("zkp"), branches, index, witness, drbg.Data(32))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := len(proof), zkp.OrProofSize(branches...); got != want {
+			t.Errorf("len(proof) = %d, want = %d", got, want)
+		}
+
+		if !zkp.VerifyOr(newplex.NewProtocol("zkp"), branches, proof) {
+			t.Errorf("VerifyOr() for branch %d = false, want = true", index)
+		}
+
+		if zkp.VerifyOr(newplex.NewProtocol("other"), branches, proof) {
+			t.Errorf("VerifyOr() for branch %d with a different transcript = true, want = false", index)
+		}
+
+		for i := range proof {
+			bad := append([]byte(nil), proof...)
+			bad[i] ^= 1
+			if zkp.VerifyOr(newplex.NewProtocol("zkp"), branches, bad) {
+				t.Errorf("VerifyOr() for branch %d with bit flipped at %d = true, want = false", index, i)
+			}
+		}
+
+		if zkp.VerifyOr(newplex.NewProtocol("zkp"), branches[:2], proof) {
+			t.Errorf("VerifyOr() for branch %d with fewer branches = true, want = false", index)
+		}
+	}
+
+	t.Run("invalid witness", func(t *testing.T) {
+		_, err := zkp.ProveOr(newplex.NewProtocol("zkp"), branches, 2, []*ristretto255.Scalar{x0}, nil)
+		if got, want := err, zkp.ErrInvalidWitness; !errors.Is(got, want) {
+			t.Errorf("ProveOr() err = %v, want = %v", got, want)
+		}
+	})
+
+	t.Run("invalid index", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("ProveOr() did not panic")
+			}
+		}()
+		_, _ = zkp.ProveOr(newplex.NewProtocol("zkp"), branches, 3, []*ristretto255.Scalar{x0}, nil)
+	})
+
+	t.Run("no branches", func(t *testing.T) {
+		if zkp.VerifyOr(newplex.NewProtocol("zkp"), nil, nil) {
+			t.Error("VerifyOr() with no branches = true, want = false")
+		}
+	})
+}
+
+func BenchmarkVerify(b *testing.B) {
+	drbg := testdata.New("newplex zkp bench")
+	x, y := drbg.KeyPair()
+	statement := zkp.DLog(ristretto255.NewGeneratorElement(), y)
+	proof, err := zkp.Prove(newplex.NewProtocol("zkp"), statement, []*ristretto255.Scalar{x}, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		zkp.Verify(newplex.NewProtocol("zkp"), statement, proof)
+	}
+}
+
+func BenchmarkBatchVerify(b *testing.B) {
+	drbg := testdata.New("newplex zkp bench")
+	const n = 64
+	statements := make([]*zkp.Statement, n)
+	proofs := make([][]byte, n)
+	for i := range n {
+		x, y := drbg.KeyPair()
+		statements[i] = zkp.DLog(ristretto255.NewGeneratorElement(), y)
+		var err error
+		proofs[i], err = zkp.Prove(newplex.NewProtocol("zkp"), statements[i], []*ristretto255.Scalar{x}, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		protocols := make([]*newplex.Protocol, n)
+		for i := range protocols {
+			protocols[i] = newplex.NewProtocol("zkp")
+		}
+		zkp.BatchVerify(protocols, statements, proofs)
+	}
+}
+
+func Example() {
+	drbg := testdata.New("newplex zkp example")
+	x, y := drbg.KeyPair()
+
+	// The prover shows that the discrete log of Y with respect to G is equal to the discrete log of Z with respect to
+	// H, without revealing it.
+	g := ristretto255.NewGeneratorElement()
+	h, _ := ristretto255.NewIdentityElement().SetUniformBytes(drbg.Data(64))
+	z := ristretto255.NewIdentityElement().ScalarMult(x, h)
+
+	// Both parties bind the proof to the same context.
+	prover := newplex.NewProtocol("com.example.dleq")
+	prover.Mix("context", []byte("an example"))
+	proof, err := zkp.Prove(prover, zkp.DLEQ(g, y, h, z), []*ristretto255.Scalar{x}, drbg.Data(64))
+	if err != nil {
+		panic(err)
+	}
+
+	verifier := newplex.NewProtocol("com.example.dleq")
+	verifier.Mix("context", []byte("an example"))
+	fmt.Println(zkp.Verify(verifier, zkp.DLEQ(g, y, h, z), proof))
+
+	// Output:
+	// true
+}
+
+func scalar(drbg *testdata.DRBG) *ristretto255.Scalar {
+	s, _ := ristretto255.NewScalar().SetUniformBytes(drbg.Data(64))
+	return s
+}
+
+func element(drbg *testdata.DRBG) *ristretto255.Element {
+	e, _ := ristretto255.NewIdentityElement().SetUniformBytes(drbg.Data(64))
+	return e
+}